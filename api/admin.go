@@ -3,8 +3,9 @@ package api
 import (
 	"alpha2/crawler"
 	"alpha2/crawler/pmf"
-	"alpha2/jobs"
+	"alpha2/store"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,7 +17,6 @@ import (
 	"github.com/reugn/go-quartz/quartz"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
-	"gorm.io/gorm"
 )
 
 type FundHouse struct {
@@ -33,8 +33,12 @@ type FundHouse struct {
 	LastUpdated  string             `json:"last_updated"`
 }
 
-func getFundHouseList(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getFundHouseList(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	perPage := r.URL.Query().Get("per_page")
 	if perPage == "" {
 		perPage = "100"
@@ -44,21 +48,6 @@ func getFundHouseList(w http.ResponseWriter, r *http.Request) {
 		page = "1"
 	}
 
-	var fundHouseIDs []uint64
-	isUnverified := r.URL.Query().Get("unverified") == "true"
-	if isUnverified {
-		funds := []*crawler.Fund{}
-		err := db.Model(&crawler.Fund{}).Where("type = ? and (other_data = 'null' or other_data -> 'label' is null)", "PMF").Preload("FundManagers").Find(&funds).Error
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		lo.ForEach(funds, func(fund *crawler.Fund, _ int) {
-			fundHouseIDs = append(fundHouseIDs, fund.FundManagers[0].ID)
-		})
-	}
-
 	// Convert perPage and page to integers
 	perPageInt, err := strconv.Atoi(perPage)
 	if err != nil {
@@ -71,14 +60,26 @@ func getFundHouseList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var fundManagers []crawler.FundManager
-	tx := db.Find(&crawler.FundManager{}).Limit(perPageInt).Offset((pageInt - 1) * perPageInt)
+	filter := store.FundHouseFilter{
+		Limit:  perPageInt,
+		Offset: (pageInt - 1) * perPageInt,
+	}
+	if format != "" {
+		// Exports hold every matching fund house, not one page.
+		filter.Limit, filter.Offset = 0, 0
+	}
 	if r.URL.Query().Has("id") {
-		tx = tx.Where("id = ?", r.URL.Query().Get("id"))
-	} else if isUnverified {
-		tx = tx.Where("id in ?", fundHouseIDs)
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+		filter.IDs = []uint64{id}
+	} else {
+		filter.Unverified = r.URL.Query().Get("unverified") == "true"
 	}
-	err = tx.Find(&fundManagers).Error
+
+	fundManagers, err := h.store.FundHouses.List(r.Context(), filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -86,12 +87,24 @@ func getFundHouseList(w http.ResponseWriter, r *http.Request) {
 	fundHouseList := make([]FundHouse, len(fundManagers))
 	for i, fundManager := range fundManagers {
 		fundHouseList[i] = FundHouse{
-			Name:        fundManager.RegistrationName(),
+			Name:        fundManager.RegistrationName,
 			ID:          strconv.FormatUint(fundManager.ID, 10),
-			DisplayName: fundManager.OtherData["display_name"],
-			LogoUrl:     fundManager.OtherData["logo_url"],
-			Description: fundManager.OtherData["description"],
+			DisplayName: fundManager.DisplayName,
+			LogoUrl:     fundManager.LogoURL,
+			Description: fundManager.Description,
+		}
+	}
+
+	if format != "" {
+		table := &ExportTable{
+			Name:    "fund-houses",
+			Headers: []string{"ID", "Name", "Display Name", "Description", "Logo URL"},
+		}
+		for i, fh := range fundHouseList {
+			table.Rows = append(table.Rows, []any{float64(fundManagers[i].ID), fh.Name, fh.DisplayName, fh.Description, fh.LogoUrl})
 		}
+		writeExport(w, r, format, table)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -103,48 +116,54 @@ func getFundHouseList(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getFundHouse(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
-
+func (h *HTTPHandlers) getFundHouse(w http.ResponseWriter, r *http.Request) {
 	if chi.URLParam(r, "ID") == "" && chi.URLParam(r, "slug") == "" {
 		http.Error(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 
-	var fundManager crawler.FundManager
-	tx := db.Model(&crawler.FundManager{}).Preload("Managers")
+	var fundManager *crawler.FundManager
+	var err error
 	if chi.URLParam(r, "ID") != "" {
-		tx.Where("id = ?", chi.URLParam(r, "ID"))
+		id, perr := strconv.ParseUint(chi.URLParam(r, "ID"), 10, 64)
+		if perr != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		fundManager, err = h.store.FundHouses.Get(r.Context(), id)
 	} else {
-		tx.Where("other_data->>'slug' = ?", chi.URLParam(r, "slug")).Preload("Funds")
+		fundManager, err = h.store.FundHouses.GetBySlug(r.Context(), chi.URLParam(r, "slug"))
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "fund house not found", http.StatusNotFound)
+		return
 	}
-	err := tx.Find(&fundManager).Error
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	var lastUpdated string
 	if len(fundManager.Funds) != 0 {
-		var report crawler.FundReport
-		db.Model(&crawler.FundReport{}).
-			Order("report_date DESC").
-			Where("fund_id in ?", lo.Map(fundManager.Funds, func(fund *crawler.Fund, _ int) uint64 {
-				return (fund.ID)
-			})).
-			First(&report)
-
-		if report.ReportDate != nil {
+		report, err := h.store.Reports.LatestForFunds(r.Context(), lo.Map(fundManager.Funds, func(fund *crawler.Fund, _ int) uint64 {
+			return (fund.ID)
+		}))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if report != nil && report.ReportDate != nil {
 			lastUpdated = report.ReportDate.Format(time.RFC3339)
 		}
 	}
 
 	fundHouse := FundHouse{
-		Name:         fundManager.RegistrationName(),
+		Name:         fundManager.RegistrationName,
 		ID:           strconv.FormatUint(fundManager.ID, 10),
-		DisplayName:  fundManager.OtherData["display_name"],
-		LogoUrl:      fundManager.OtherData["logo_url"],
-		Description:  fundManager.OtherData["description"],
-		Slug:         fundManager.OtherData["slug"],
+		DisplayName:  fundManager.DisplayName,
+		LogoUrl:      fundManager.LogoURL,
+		Description:  fundManager.Description,
+		Slug:         fundManager.Slug,
 		Managers:     fundManager.Managers,
 		AUM:          fundManager.TotalAUM,
 		TotalClients: fundManager.TotalNoOfClient,
@@ -161,7 +180,7 @@ func getFundHouse(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func updateFundHouse(w http.ResponseWriter, r *http.Request) {
+func (h *HTTPHandlers) updateFundHouse(w http.ResponseWriter, r *http.Request) {
 	var fundHouse FundHouse
 	err := json.NewDecoder(r.Body).Decode(&fundHouse)
 	if err != nil {
@@ -169,41 +188,35 @@ func updateFundHouse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := crawler.Conn()
-	fundManager := crawler.FundManager{}
-	err = db.Model(&crawler.FundManager{}).Preload("Managers").First(&fundManager, fundHouse.ID).Error
+	id, err := strconv.ParseUint(fundHouse.ID, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
 
-	fundManager.OtherData["display_name"] = fundHouse.DisplayName
+	var logoImageID uint
 	if fundHouse.LogoUrl != "" {
-		fundManager.OtherData["logo_url"] = fundHouse.LogoUrl
-	}
-	fundManager.OtherData["description"] = fundHouse.Description
-	fundManager.OtherData["slug"] = fundHouse.Slug
-
-	err = db.Transaction(func(tx *gorm.DB) error {
-		err = tx.Save(&fundManager).Error
+		logoImageID, err = imageIDFromURL(fundHouse.LogoUrl)
 		if err != nil {
-			return err
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+	}
 
-		for _, manager := range fundHouse.Managers {
-			if err := tx.Save(manager).Error; err != nil {
-				return err
-			}
-		}
+	fundManager, err := h.store.FundHouses.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 
-		if fundHouse.LogoUrl != "" {
-			err = MarkImageAsUsed(fundHouse.LogoUrl, tx)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	fundManager.DisplayName = fundHouse.DisplayName
+	if fundHouse.LogoUrl != "" {
+		fundManager.LogoURL = fundHouse.LogoUrl
+	}
+	fundManager.Description = fundHouse.Description
+	fundManager.Slug = fundHouse.Slug
+
+	err = h.store.FundHouses.Update(r.Context(), fundManager, fundHouse.Managers, logoImageID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -212,8 +225,7 @@ func updateFundHouse(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) uploadHandler(w http.ResponseWriter, r *http.Request) {
 	r.ParseMultipartForm(10 << 20) // 10 MB max
 
 	file, header, err := r.FormFile("image")
@@ -237,7 +249,7 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		UploadedAt:  time.Now(),
 	}
 
-	if err := db.Create(&image).Error; err != nil {
+	if err := h.store.Images.Create(r.Context(), &image); err != nil {
 		http.Error(w, "Failed to save image: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -246,12 +258,15 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "/image?id=%d", image.ID)
 }
 
-func getImageHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	var image crawler.Image
+func (h *HTTPHandlers) getImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
 
-	db := crawler.Conn()
-	if err := db.First(&image, id).Error; err != nil {
+	image, err := h.store.Images.Get(r.Context(), uint(id))
+	if err != nil {
 		http.Error(w, "Image not found", http.StatusNotFound)
 		return
 	}
@@ -260,23 +275,19 @@ func getImageHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(image.Content)
 }
 
-func MarkImageAsUsed(url string, db *gorm.DB) error {
+// imageIDFromURL returns the image ID from a logo URL returned by uploadHandler.
+func imageIDFromURL(url string) (uint, error) {
 	// get query param from	fundHouse.LogoUrl
 	var imgId string
 	if strings.Contains(url, "?") {
 		imgId = strings.TrimPrefix(url, "/image?id=")
 	}
 
-	id, err := strconv.Atoi(imgId)
+	id, err := strconv.ParseUint(imgId, 10, 64)
 	if err != nil {
-		return fmt.Errorf("invalid image ID: %v", imgId)
-	}
-	var image crawler.Image
-	if err := db.First(&image, id).Error; err != nil {
-		return err
+		return 0, fmt.Errorf("invalid image ID: %v", imgId)
 	}
-	image.IsUnused = false
-	return db.Save(&image).Error
+	return uint(id), nil
 }
 
 type Fund struct {
@@ -287,16 +298,25 @@ type Fund struct {
 	MergedWith  *string `json:"merged_with"`
 }
 
-func getFundsListByFundHouse(w http.ResponseWriter, r *http.Request) {
+func (h *HTTPHandlers) getFundsListByFundHouse(w http.ResponseWriter, r *http.Request) {
 	if chi.URLParam(r, "fund_house_id") == "" {
 		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
 		return
 	}
 	fundHouseID := chi.URLParam(r, "fund_house_id")
-	db := crawler.Conn()
+	fundHouseIDUint64, err := strconv.ParseUint(fundHouseID, 10, 64)
+	if err != nil {
+		http.Error(w, "error during parsing fund house ID", http.StatusBadRequest)
+		return
+	}
 
-	fund := &crawler.FundManager{}
-	err := db.Model(&crawler.FundManager{}).Where("id = ?", fundHouseID).Preload("Funds").First(fund).Error
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.store.FundHouses.GetWithFunds(r.Context(), fundHouseIDUint64)
 	if err != nil {
 		http.Error(w, "error during fetching funds", http.StatusBadRequest)
 		return
@@ -306,18 +326,12 @@ func getFundsListByFundHouse(w http.ResponseWriter, r *http.Request) {
 	for i, fund := range fund.Funds {
 
 		var mergedWith *string
-		if fund.OtherData["original_id"] != "" {
-			mergedWith = lo.ToPtr(fund.OtherData["original_id"])
-			mergeID, err := strconv.ParseUint(*mergedWith, 10, 64)
-			if err != nil {
-				http.Error(w, "error during parsing merged fund ID", http.StatusBadRequest)
-				return
-			}
-
+		if fund.OriginalID != nil {
+			mergeID := *fund.OriginalID
 			if mergeID != fund.ID {
 
-				mergedFund := &crawler.Fund{}
-				if err = db.Find(&mergedFund, mergeID).Error; err != nil {
+				mergedFund, err := h.store.Funds.Get(r.Context(), mergeID)
+				if err != nil {
 					http.Error(w, "error during fetching merged fund", http.StatusBadRequest)
 					return
 				}
@@ -331,12 +345,24 @@ func getFundsListByFundHouse(w http.ResponseWriter, r *http.Request) {
 		apiData[i] = Fund{
 			Name:        fund.Name,
 			ID:          strconv.FormatUint(fund.ID, 10),
-			DisplayName: fund.OptDisplayName(),
+			DisplayName: fund.Label,
 			IsHidden:    fund.IsHidden,
 			MergedWith:  mergedWith,
 		}
 	}
 
+	if format != "" {
+		table := &ExportTable{
+			Name:    fmt.Sprintf("fund-house-%s-funds", fundHouseID),
+			Headers: []string{"ID", "Name", "Display Name", "Hidden", "Merged With"},
+		}
+		for i, f := range apiData {
+			table.Rows = append(table.Rows, []any{float64(fund.Funds[i].ID), f.Name, f.DisplayName, f.IsHidden, lo.FromPtr(f.MergedWith)})
+		}
+		writeExport(w, r, format, table)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(apiData)
@@ -347,24 +373,26 @@ func getFundsListByFundHouse(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func reFetchReport(w http.ResponseWriter, r *http.Request) {
+func (h *HTTPHandlers) reFetchReport(w http.ResponseWriter, r *http.Request) {
 	if chi.URLParam(r, "fund_house_id") == "" {
 		log.Error().Msg("fund_house_id is required")
 		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
 		return
 	}
-	fundHouseID := chi.URLParam(r, "fund_house_id")
-	db := crawler.Conn()
+	fundHouseID, err := strconv.ParseUint(chi.URLParam(r, "fund_house_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "error during parsing fund house ID", http.StatusBadRequest)
+		return
+	}
 
-	fundHouse := &crawler.FundManager{}
-	err := db.Model(&crawler.FundManager{}).Where("id = ?", fundHouseID).First(fundHouse).Error
+	fundHouse, err := h.store.FundHouses.Get(r.Context(), fundHouseID)
 	if err != nil {
 		log.Error().Err(err).Msg("error during fetching fund house")
 		http.Error(w, "error during fetching fund house", http.StatusBadRequest)
 		return
 	}
 
-	UID := fundHouse.OtherData["UID"]
+	UID := fundHouse.UID
 	data := struct {
 		StartDate string `json:"from"`
 		EndDate   string `json:"to"`
@@ -408,7 +436,7 @@ func reFetchReport(w http.ResponseWriter, r *http.Request) {
 		randJobID := lo.RandomString(10, []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"))
 		jd := quartz.NewJobDetail(job, quartz.NewJobKeyWithGroup(randJobID, "CrawlPMFFunds"))
 		t := quartz.NewRunOnceTrigger(time.Second * 5)
-		err = jobs.Scheduler.ScheduleJob(jd, t)
+		err = h.scheduler.ScheduleJob(jd, t)
 		if err != nil {
 			log.Error().Err(err).Msg("Error while refetch scheduling job")
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -421,128 +449,91 @@ func reFetchReport(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func unmergeFund(w http.ResponseWriter, r *http.Request) {
-
-	db := crawler.Conn()
-
-	fundID := chi.URLParam(r, "fund_id")
-	if fundID == "" {
-		http.Error(w, "fund_id is required", http.StatusBadRequest)
-		return
-	}
-
-	fundHouseID := chi.URLParam(r, "fund_house_id")
-	if fundHouseID == "" {
-		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
-		return
-	}
-
-	err := db.Model(&crawler.FundReport{}).Where("other_data ->> 'merged_id' = ?", fundID).Delete(&crawler.FundReport{}).Error
+func (h *HTTPHandlers) unmergeFund(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
 	if err != nil {
-		http.Error(w, "error during deleting reports", http.StatusInternalServerError)
-		return
-	}
-
-	fund := &crawler.Fund{}
-	err = db.Model(&crawler.Fund{}).Where("id = ?", fundID).Find(fund).Error
-	if err != nil {
-		http.Error(w, "error during fetching fund", http.StatusBadRequest)
+		http.Error(w, "fund_id is required", http.StatusBadRequest)
 		return
 	}
 
-	delete(fund.OtherData, "original_id")
-	fund.IsHidden = false
-	err = db.Save(fund).Error
+	fundHouseID, err := strconv.ParseUint(chi.URLParam(r, "fund_house_id"), 10, 64)
 	if err != nil {
-		http.Error(w, "error during saving fund", http.StatusInternalServerError)
+		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
 		return
 	}
 
-	fundHouseIDUint64, err := strconv.ParseUint(fundHouseID, 10, 64)
+	event, err := h.store.Merges.Unmerge(r.Context(), fundID, adminName(r))
 	if err != nil {
-		http.Error(w, "error during parsing fund house ID", http.StatusBadRequest)
+		mergeError(w, err)
 		return
 	}
 
-	err = pmf.ScheduleDataConsistencyJobIsNotPresent(fundHouseIDUint64)
+	err = pmf.ScheduleDataConsistencyJobIsNotPresent(r.Context(), h.store, fundHouseID)
 	if err != nil {
 		http.Error(w, "error during scheduling data consistency job", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
 }
 
-func mergeFund(w http.ResponseWriter, r *http.Request) {
-
-	db := crawler.Conn()
-
-	fundID := chi.URLParam(r, "fund_id")
-	if fundID == "" {
+func (h *HTTPHandlers) mergeFund(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
 		http.Error(w, "fund_id is required", http.StatusBadRequest)
 		return
 	}
 
-	mergeFundID := chi.URLParam(r, "merge_fund_id")
-	if fundID == "" {
-		http.Error(w, "merge_fund_id is required", http.StatusBadRequest)
-		return
-	}
-
-	fundHouseID := chi.URLParam(r, "fund_house_id")
-	if fundHouseID == "" {
-		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
-		return
-	}
-
-	fund := &crawler.Fund{}
-	err := db.Model(&crawler.Fund{}).Where("id = ?", fundID).Find(fund).Error
+	mergeFundID, err := strconv.ParseUint(chi.URLParam(r, "merge_fund_id"), 10, 64)
 	if err != nil {
-		http.Error(w, "error during fetching fund", http.StatusBadRequest)
-		return
-	}
-	mergefund := &crawler.Fund{}
-	err = db.Model(&crawler.Fund{}).Where("id = ?", mergeFundID).Find(mergefund).Error
-	if err != nil {
-		http.Error(w, "error during fetching fund", http.StatusBadRequest)
+		http.Error(w, "merge_fund_id is required", http.StatusBadRequest)
 		return
 	}
 
-	fund.OtherData["original_id"] = mergeFundID
-	fund.OtherData["label"] = mergefund.OtherData["label"]
-	fund.IsHidden = true
-	err = db.Save(fund).Error
+	fundHouseID, err := strconv.ParseUint(chi.URLParam(r, "fund_house_id"), 10, 64)
 	if err != nil {
-		http.Error(w, "error during saving fund", http.StatusInternalServerError)
+		http.Error(w, "fund_house_id is required", http.StatusBadRequest)
 		return
 	}
 
-	fundHouseIDUint64, err := strconv.ParseUint(fundHouseID, 10, 64)
+	event, err := h.store.Merges.Commit(r.Context(), fundID, mergeFundID, adminName(r))
 	if err != nil {
-		http.Error(w, "error during parsing fund house ID", http.StatusBadRequest)
+		mergeError(w, err)
 		return
 	}
 
-	err = pmf.ScheduleDataConsistencyJobIsNotPresent(fundHouseIDUint64)
+	err = pmf.ScheduleDataConsistencyJobIsNotPresent(r.Context(), h.store, fundHouseID)
 	if err != nil {
 		http.Error(w, "error during scheduling data consistency job", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
 }
 
-func hideFund(w http.ResponseWriter, r *http.Request) {
-
-	db := crawler.Conn()
+// mergeError writes the response for an error of the merge service.
+func mergeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "fund not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrInvalidMerge), errors.Is(err, store.ErrAlreadyMerged), errors.Is(err, store.ErrNotMerged):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error().Err(err).Msg("Error while merging funds")
+		http.Error(w, "error during merging fund", http.StatusInternalServerError)
+	}
+}
 
-	fundID := chi.URLParam(r, "fund_id")
-	if fundID == "" {
+func (h *HTTPHandlers) hideFund(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
 		http.Error(w, "fund_id is required", http.StatusBadRequest)
 		return
 	}
 
-	err := db.Model(&crawler.Fund{}).Where("id = ?", fundID).Update("is_hidden", true).Error
+	err = h.store.Funds.SetHidden(r.Context(), fundID, true)
 	if err != nil {
 		http.Error(w, "error during hiding fund", http.StatusInternalServerError)
 		return
@@ -551,17 +542,14 @@ func hideFund(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func unhideFund(w http.ResponseWriter, r *http.Request) {
-
-	db := crawler.Conn()
-
-	fundID := chi.URLParam(r, "fund_id")
-	if fundID == "" {
+func (h *HTTPHandlers) unhideFund(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
 		http.Error(w, "fund_id is required", http.StatusBadRequest)
 		return
 	}
 
-	err := db.Model(&crawler.Fund{}).Where("id = ?", fundID).Update("is_hidden", false).Error
+	err = h.store.Funds.SetHidden(r.Context(), fundID, false)
 	if err != nil {
 		http.Error(w, "error during hiding fund", http.StatusInternalServerError)
 		return
@@ -1,8 +1,8 @@
 package api
 
 import (
-	"alpha2/crawler"
 	"alpha2/jobs"
+	"alpha2/store"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -29,15 +29,14 @@ func init() {
 	middleware.DefaultLogger = middleware.RequestLogger(&LogFormatter{})
 }
 
-func RunServer() {
-
-	db := crawler.Conn()
-	jobs.Init()
+// RunServer serves the HTTP API from st and starts the scheduler. jobs.Init must have
+// been called first.
+func RunServer(st *store.Store) {
 
 	if viper.GetBool("jobs.enable") {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		jobs.Scheduler.Start(ctx)
+		jobs.Start(ctx)
 		log.Info().Msg("Jobs are enabled. Scheduler started.")
 	} else {
 		log.Info().Msg("Jobs are disabled. Set jobs.enable to true to enable job scheduling.")
@@ -45,12 +44,15 @@ func RunServer() {
 	}
 
 	// Create HTTP handlers
-	handlers := NewHTTPHandlers(jobs.Scheduler, db)
+	handlers := NewHTTPHandlers(jobs.Scheduler, st)
 
 	// Create a new Chi router
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	if timeout := viper.GetDuration("server.timeout"); timeout > 0 {
+		r.Use(middleware.Timeout(timeout))
+	}
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{viper.GetString("server.origins")}, // Allow your Next.js frontend
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
@@ -63,17 +65,19 @@ func RunServer() {
 	r.Group(func(r chi.Router) {
 		r.Get("/jobs/upcoming", handlers.GetUpcomingJobs)
 		r.Get("/jobs/completed", handlers.GetCompletedJobs)
-		// r.Post("/jobs", handlers.AddJob)
+		r.Get("/jobs/failed", handlers.GetFailedJobs)
 
-		r.Get("/funds", getAllFunds)
-		r.Get("/funds/explore", getExplorePMSData)
-		r.Get("/fund/{fundID}/trailing-returns", getTrailingReturns)
+		r.Get("/funds", handlers.getAllFunds)
+		r.Get("/funds/explore", handlers.getExplorePMSData)
+		r.Get("/fund/{fundID}/trailing-returns", handlers.getTrailingReturns)
 		// r.Get("/funds/impact", getImpactData)
 		// r.Get("/fund/{fundID}/rolling-returns", getRollingReturns)
-		r.Get("/fund/{fundID}/discrete-returns", getDiscreteReturns)
-		r.Get("/image", getImageHandler)
-		r.Get("/fund-house/{slug}", getFundHouse)
-		r.Get("/fund-house/aum/{slug}", getAUMChart)
+		r.Get("/fund/{fundID}/discrete-returns", handlers.getDiscreteReturns)
+		r.Get("/image", handlers.getImageHandler)
+		r.Get("/fund-house/{slug}", handlers.getFundHouse)
+		r.Get("/fund-house/aum/{slug}", handlers.getAUMChart)
+		r.Get("/collections", handlers.ListCollections)
+		r.Get("/collections/{slug}", handlers.GetCollection)
 	})
 
 	r.Group(func(r chi.Router) {
@@ -119,18 +123,56 @@ func RunServer() {
 		r.Use(jwtauth.Verifier(tokenAuth))
 		r.Use(jwtauth.Authenticator(tokenAuth))
 
-		r.Get("/admin/fund-house", getFundHouseList)
-		r.Get("/admin/fund-house/{ID}", getFundHouse)
-		r.Patch("/admin/fund-house", updateFundHouse)
-
-		r.Get("/admin/fund-house/{fund_house_id}/funds", getFundsListByFundHouse)
-		r.Post("/admin/fund-house/{fund_house_id}/action/refetch-reports", reFetchReport)
-		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/hide", hideFund)
-		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/unhide", unhideFund)
-		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/unmerge", unmergeFund)
-		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/merge/{merge_fund_id}", mergeFund)
-
-		r.Post("/upload", uploadHandler)
+		r.Get("/admin/fund-house", handlers.getFundHouseList)
+		r.Get("/admin/fund-house/{ID}", handlers.getFundHouse)
+		r.Patch("/admin/fund-house", handlers.updateFundHouse)
+
+		r.Get("/admin/fund-house/{fund_house_id}/funds", handlers.getFundsListByFundHouse)
+		r.Post("/admin/fund-house/{fund_house_id}/action/refetch-reports", handlers.reFetchReport)
+		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/hide", handlers.hideFund)
+		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/unhide", handlers.unhideFund)
+		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/unmerge", handlers.unmergeFund)
+		r.Post("/admin/fund-house/{fund_house_id}/fund/{fund_id}/action/merge/{merge_fund_id}", handlers.mergeFund)
+		r.Post("/admin/fund-house/{fund_house_id}/action/suggest-duplicates", handlers.SuggestDuplicates)
+		r.Get("/admin/duplicates", handlers.ListDuplicates)
+		r.Post("/admin/duplicates/{id}/action/accept", handlers.AcceptDuplicate)
+		r.Post("/admin/duplicates/{id}/action/reject", handlers.RejectDuplicate)
+
+		r.Put("/admin/fund/{fund_id}/label", handlers.SetFundLabel)
+		r.Get("/admin/fund/{fund_id}/label-changes", handlers.ListLabelChanges)
+		r.Get("/admin/funds/unlabelled", handlers.ListUnlabelledFunds)
+		r.Get("/admin/labels/export", handlers.ExportLabels)
+		r.Post("/admin/labels/import", handlers.ImportLabels)
+
+		r.Get("/admin/fund/{fund_id}/reports", handlers.ListReportCandidates)
+		r.Get("/admin/fund/{fund_id}/revisions", handlers.ListReportRevisions)
+		r.Get("/admin/restatements", handlers.ListRestatements)
+		r.Get("/admin/fund/{fund_id}/merges", handlers.ListMerges)
+		r.Get("/admin/fund/{fund_id}/merge-preview/{merge_fund_id}", handlers.PreviewMerge)
+		r.Post("/admin/fund/{fund_id}/report-uploads", handlers.UploadReports)
+		r.Get("/admin/report-uploads", handlers.ListReportUploads)
+		r.Get("/admin/report-uploads/{id}", handlers.GetReportUpload)
+		r.Post("/admin/report-uploads/{id}/action/approve", handlers.ApproveReportUpload)
+		r.Post("/admin/report-uploads/{id}/action/reject", handlers.RejectReportUpload)
+
+		r.Get("/admin/collections", handlers.ListCollections)
+		r.Post("/admin/collections", handlers.CreateCollection)
+		r.Get("/admin/collections/{id}", handlers.AdminGetCollection)
+		r.Put("/admin/collections/{id}", handlers.UpdateCollection)
+		r.Delete("/admin/collections/{id}", handlers.DeleteCollection)
+
+		r.Get("/admin/jobs/dead", handlers.ListDeadJobs)
+		r.Post("/admin/jobs/dead/action/replay", handlers.ReplayDeadJobs)
+		r.Post("/admin/jobs/dead/{id}/action/replay", handlers.ReplayDeadJob)
+		r.Get("/admin/jobs", handlers.ListJobs)
+		r.Post("/admin/jobs", handlers.CreateJob)
+		r.Get("/admin/jobs/{key}/events", handlers.JobEvents)
+		r.Post("/admin/jobs/{key}/action/trigger", handlers.TriggerJob)
+		r.Delete("/admin/jobs/{key}", handlers.CancelJob)
+		r.Post("/admin/jobs/group/{group}/action/pause", handlers.PauseJobGroup)
+		r.Post("/admin/jobs/group/{group}/action/resume", handlers.ResumeJobGroup)
+
+		r.Post("/upload", handlers.uploadHandler)
 	})
 
 	// Start the HTTP server
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"alpha2/crawler"
+	"alpha2/store"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// collectionRequest is the body of the admin collection endpoints. The slug is
+// derived from the name when empty.
+type collectionRequest struct {
+	Name        string   `json:"name"`
+	Slug        string   `json:"slug"`
+	Description string   `json:"description"`
+	FundIDs     []uint64 `json:"fund_ids"`
+}
+
+func (c *collectionRequest) collection(id uint64) (*crawler.Collection, error) {
+	collection := &crawler.Collection{
+		ID:          id,
+		Name:        c.Name,
+		Slug:        c.Slug,
+		Description: c.Description,
+		FundIDs:     c.FundIDs,
+	}
+	collection.Normalize()
+	return collection, collection.Validate()
+}
+
+// collectionFund is a fund of a collection as shown publicly.
+type collectionFund struct {
+	ID      uint64 `json:"id"`
+	Name    string `json:"schemeName"`
+	Manager string `json:"manager"`
+	Slug    string `json:"slug"`
+}
+
+// ListCollections returns every collection with the IDs of its funds.
+func (h *HTTPHandlers) ListCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.store.Collections.List(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, collections)
+}
+
+// GetCollection returns the collection with the slug and its visible funds in
+// order. A fund merged into another is shown as the one it was merged into.
+func (h *HTTPHandlers) GetCollection(w http.ResponseWriter, r *http.Request) {
+	collection, err := h.store.Collections.GetBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		renderCollectionError(w, r, err)
+		return
+	}
+	funds, err := h.collectionFunds(r, collection.FundIDs)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, struct {
+		*crawler.Collection
+		Funds []collectionFund `json:"funds"`
+	}{collection, funds})
+}
+
+func (h *HTTPHandlers) collectionFunds(r *http.Request, ids []uint64) ([]collectionFund, error) {
+	listed, err := h.store.Funds.ListByIDs(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+	var originalIDs []uint64
+	for _, fund := range listed {
+		if fund.OriginalID != nil {
+			originalIDs = append(originalIDs, *fund.OriginalID)
+		}
+	}
+	originals, err := h.store.Funds.ListByIDs(r.Context(), originalIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]crawler.Fund, len(listed)+len(originals))
+	for _, fund := range append(listed, originals...) {
+		byID[fund.ID] = fund
+	}
+
+	funds := []collectionFund{}
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		fund, ok := byID[id]
+		if ok && fund.OriginalID != nil {
+			fund, ok = byID[*fund.OriginalID]
+		}
+		if !ok || fund.IsHidden || seen[fund.ID] {
+			continue
+		}
+		seen[fund.ID] = true
+		shown := collectionFund{ID: fund.ID, Name: ToTitleCase(fund.DisplayName())}
+		if len(fund.FundManagers) > 0 {
+			shown.Manager = ToTitleCase(fund.FundManagers[0].RegistrationName)
+			shown.Slug = fund.FundManagers[0].Slug
+		}
+		funds = append(funds, shown)
+	}
+	return funds, nil
+}
+
+// AdminGetCollection returns a collection by ID.
+func (h *HTTPHandlers) AdminGetCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid collection id")))
+		return
+	}
+	collection, err := h.store.Collections.Get(r.Context(), id)
+	if err != nil {
+		renderCollectionError(w, r, err)
+		return
+	}
+	render.JSON(w, r, collection)
+}
+
+// CreateCollection creates a collection from the JSON body.
+func (h *HTTPHandlers) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	req := &collectionRequest{}
+	if err := render.DecodeJSON(r.Body, req); err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	collection, err := req.collection(0)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if err := h.store.Collections.Create(r.Context(), collection); err != nil {
+		renderCollectionError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, collection)
+}
+
+// UpdateCollection replaces a collection, its funds included, with the JSON body.
+func (h *HTTPHandlers) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid collection id")))
+		return
+	}
+	req := &collectionRequest{}
+	if err := render.DecodeJSON(r.Body, req); err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	collection, err := req.collection(id)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if err := h.store.Collections.Update(r.Context(), collection); err != nil {
+		renderCollectionError(w, r, err)
+		return
+	}
+	render.JSON(w, r, collection)
+}
+
+// DeleteCollection deletes a collection.
+func (h *HTTPHandlers) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid collection id")))
+		return
+	}
+	if err := h.store.Collections.Delete(r.Context(), id); err != nil {
+		renderCollectionError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func renderCollectionError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		render.Render(w, r, ErrNotFound(errors.New("collection not found")))
+	case errors.Is(err, store.ErrSlugTaken):
+		render.Render(w, r, ErrConflict(err))
+	case errors.Is(err, store.ErrUnknownFund):
+		render.Render(w, r, ErrBadRequest(err))
+	default:
+		render.Render(w, r, ErrInternalServerError(err))
+	}
+}
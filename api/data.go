@@ -2,20 +2,18 @@ package api
 
 import (
 	"alpha2/crawler"
-	"encoding/csv"
+	"alpha2/store"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
-	"github.com/samber/lo"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type LineGraphData struct {
@@ -30,8 +28,7 @@ type AUMChart struct {
 }
 
 // Handler to get trailing returns
-func getTrailingReturns(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getTrailingReturns(w http.ResponseWriter, r *http.Request) {
 	fundIDStr := chi.URLParam(r, "fundID")
 	fundID, err := strconv.ParseUint(fundIDStr, 10, 64)
 	if err != nil {
@@ -62,22 +59,32 @@ func getTrailingReturns(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var reports []*crawler.FundReport
-	err = db.
-		Raw(`
-        SELECT DISTINCT ON (fund_id, DATE_TRUNC('month', report_date)) *
-        FROM fund_reports
-        WHERE fund_id = ?
-          AND report_date BETWEEN ? AND ?
-        ORDER BY fund_id, DATE_TRUNC('month', report_date), report_date DESC
-    `, fundID, startTime, endTime).
-		Scan(&reports).Error
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, err := h.store.Reports.MonthEnd(r.Context(), fundID, startTime, endTime)
 	if err != nil {
 		http.Error(w, "Error fetching reports", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
 	apiData := convertData(reports)
+	if format != "" {
+		table := &ExportTable{
+			Name:    fmt.Sprintf("fund-%d-trailing-returns", fundID),
+			Headers: []string{"Report Date", "Amount", "Returns"},
+			From:    startTime,
+			To:      endTime,
+		}
+		for _, d := range apiData {
+			table.Rows = append(table.Rows, []any{d.ReportDate, d.Amount, d.Returns})
+		}
+		writeExport(w, r, format, table)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(apiData); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
@@ -120,20 +127,25 @@ func convertData(reports []*crawler.FundReport) []*LineGraphData {
 }
 
 // Handler to get discrete returns
-func getDiscreteReturns(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getDiscreteReturns(w http.ResponseWriter, r *http.Request) {
 	fundID := chi.URLParam(r, "fundID")
+	id, err := strconv.ParseUint(fundID, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid fund ID", http.StatusBadRequest)
+		return
+	}
 	var reports []crawler.FundReport
 
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	period := r.URL.Query().Get("period")
 	if period == "Y" {
 		month := time.Now().AddDate(0, -1, 0).Month()
-		err := db.Raw(`
-        	SELECT DISTINCT ON (EXTRACT(YEAR FROM report_date)) *
-        	FROM fund_reports
-        	WHERE EXTRACT(MONTH FROM report_date) = ? AND fund_id = ?
-        	ORDER BY EXTRACT(YEAR FROM report_date), report_date DESC;
-    	`, month, fundID).Scan(&reports).Error
+		reports, err = h.store.Reports.ForMonthEachYear(r.Context(), id, month)
 		if err != nil {
 			http.Error(w, "Error fetching reports", http.StatusInternalServerError)
 			return
@@ -150,20 +162,14 @@ func getDiscreteReturns(w http.ResponseWriter, r *http.Request) {
 		for qStart := endDate.AddDate(0, -3, 0); quarterCount < limit; qStart = qStart.AddDate(0, -3, 0) {
 			qEnd := qStart.AddDate(0, 3, 0)
 
-			var report crawler.FundReport
-			err := db.
-				Where("fund_id = ?", fundID).
-				Where("report_date >= ? AND report_date < ?", qStart, qEnd).
-				Order("report_date DESC").
-				First(&report).Error
-
-			if err != nil && err != gorm.ErrRecordNotFound {
+			report, err := h.store.Reports.LatestBetween(r.Context(), id, qStart, qEnd)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
 				http.Error(w, "Error fetching reports", http.StatusInternalServerError)
 				return
 			}
 
 			if err == nil {
-				reports = append(reports, report)
+				reports = append(reports, *report)
 				quarterCount++
 			}
 
@@ -187,6 +193,21 @@ func getDiscreteReturns(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if format != "" {
+		table := &ExportTable{
+			Name:    fmt.Sprintf("fund-%s-discrete-returns", fundID),
+			Headers: []string{"Report Date", "1Y Return", "2Y Return", "3Y Return", "4Y Return", "5Y Return"},
+		}
+		for _, report := range reports {
+			table.Rows = append(table.Rows, []any{report.ReportDate, report.Yr1Returns, report.Yr2Returns, report.Yr3Returns, report.Yr4Returns, report.Yr5Returns})
+			if report.ReportDate != nil && report.ReportDate.After(table.To) {
+				table.To = *report.ReportDate
+			}
+		}
+		writeExport(w, r, format, table)
+		return
+	}
+
 	resp := map[string]interface{}{
 		"fund_id":          fundID,
 		"discrete_returns": discreteReturns,
@@ -199,8 +220,7 @@ func getDiscreteReturns(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func getAllFunds(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getAllFunds(w http.ResponseWriter, r *http.Request) {
 	var apiFunds []struct {
 		ID      uint64 `json:"id"`
 		Name    string `json:"name"`
@@ -214,21 +234,8 @@ func getAllFunds(w http.ResponseWriter, r *http.Request) {
 		ftype = "PMF"
 	}
 
-	var tx *gorm.DB
-	if ftype == "PMF" {
-		tx = db.Model(&crawler.Fund{}).Preload("FundManagers").Where("type = 'PMF' and  other_data != 'null' and is_hidden = false")
-		if fundname != "" {
-			tx = tx.Order(clause.OrderBy{
-				Expression: clause.Expr{SQL: "similarity(other_data->>'label', ?) DESC", Vars: []any{fundname}},
-			})
-		}
-	} else {
-		tx = db.Model(&crawler.Fund{}).Where("type = 'MF' and is_hidden = false")
-		if fundname != "" {
-			tx = tx.Order(clause.OrderBy{
-				Expression: clause.Expr{SQL: "similarity(name, ?) DESC", Vars: []any{fundname}},
-			})
-		}
+	if ftype != "PMF" && ftype != "AIF" {
+		ftype = "MF"
 	}
 
 	if perPage == "" {
@@ -239,11 +246,12 @@ func getAllFunds(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid per_page value", http.StatusBadRequest)
 		return
 	}
-	tx = tx.Limit(perPageInt)
-	// Select only ID and Name from Fund table
-	funds := []crawler.Fund{}
-	if err := tx.
-		Find(&funds).Error; err != nil {
+	funds, err := h.store.Funds.Search(r.Context(), store.FundSearch{
+		Type:  ftype,
+		Name:  fundname,
+		Limit: perPageInt,
+	})
+	if err != nil {
 		http.Error(w, "Error fetching funds", http.StatusInternalServerError)
 		return
 	}
@@ -251,7 +259,7 @@ func getAllFunds(w http.ResponseWriter, r *http.Request) {
 	for _, fund := range funds {
 		var manager string
 		if len(fund.FundManagers) > 0 {
-			manager = fund.FundManagers[0].OtherData["RegistrationName"]
+			manager = fund.FundManagers[0].RegistrationName
 		} else {
 			manager = "Mutual Fund"
 		}
@@ -275,6 +283,15 @@ func getAllFunds(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// exploreType returns the fund type of the explore page: AIF when asked for, PMF
+// otherwise.
+func exploreType(r *http.Request) string {
+	if strings.EqualFold(r.URL.Query().Get("type"), "AIF") {
+		return "AIF"
+	}
+	return "PMF"
+}
+
 func ToTitleCase(s string) string {
 	s = strings.ReplaceAll(s, "\n", "")
 
@@ -285,8 +302,7 @@ func ToTitleCase(s string) string {
 	return strings.ToUpper(string(words[0])) + words[1:]
 }
 
-func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 	var resp struct {
 		Data []struct {
 			ID      uint64   `json:"id"`
@@ -318,6 +334,12 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 		Total int64 `json:"total"`
 	}
 
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	fundname := r.URL.Query().Get("search")
 	perPage := r.URL.Query().Get("per_page")
 	page := r.URL.Query().Get("page")
@@ -332,8 +354,6 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 		isDesc = true
 	}
 
-	tx := db.Model(&crawler.FundReport{})
-
 	if perPage == "" {
 		perPage = "50"
 	}
@@ -355,136 +375,58 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 	firstDayLastMonth := time.Date(now.Year(), now.Month()-2, 1, 0, 0, 0, 0, now.Location())
 	lastDayLastMonth := firstDayLastMonth.AddDate(0, 1, -1)
 
-	tx = tx.Joins("JOIN funds ON funds.id = fund_reports.fund_id").
-		Where("report_date BETWEEN ? AND ?", firstDayLastMonth, lastDayLastMonth).
-		Where("funds.name != '' and  funds.type = 'PMF' and funds.is_hidden = false")
+	query := store.ExploreQuery{
+		Type:   exploreType(r),
+		From:   firstDayLastMonth,
+		To:     lastDayLastMonth,
+		Search: fundname,
+		SortBy: orderby,
+		Desc:   isDesc,
+		Limit:  perPageInt,
+		Offset: pageInt,
+	}
+	if format != "" {
+		// Exports hold every matching fund, not one page.
+		query.Limit, query.Offset = 0, 0
+	}
+	// filter is the slug of a collection, or its name as the explore page sends. An
+	// unknown collection lists no funds, as a filter missing from filters.csv did.
 	filter := r.URL.Query().Get("filter")
+	listed := true
 	if filter != "" && filter != "All Funds" {
-		tx.Where("funds.other_data != 'null' and funds.other_data->>'label' in ?", getFundsByFilter(filter))
+		collection, err := h.store.Collections.GetBySlug(r.Context(), crawler.Slugify(filter))
+		if errors.Is(err, store.ErrNotFound) {
+			log.Warn().Str("filter", filter).Msg("No collection for explore filter")
+			listed = false
+		} else if err != nil {
+			http.Error(w, "Error fetching collection", http.StatusInternalServerError)
+			return
+		} else {
+			query.CollectionID = collection.ID
+		}
 	}
 	fundHouseID := r.URL.Query().Get("fund_house_id")
 	if fundHouseID != "" {
-		var funds []crawler.FundXFundManagers
-		id, _ := strconv.ParseUint(fundHouseID, 10, 64)
-		db.Model(&crawler.FundXFundManagers{}).Where(&crawler.FundXFundManagers{
-			FundManagerID: id,
-		}).Find(&funds)
-		tx.Where("funds.id in ?", lo.Map(funds, func(fund crawler.FundXFundManagers, _ int) uint64 {
-			return fund.FundID
-		}))
-	}
-
-	if fundname != "" {
-		tx.Where("similarity(funds.name, ?) > 0.1", fundname)
-	}
-
-	switch orderby {
-	case "aum":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "aum"},
-				Desc:   isDesc,
-			}},
-		})
-	case "threeMonth":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.month3_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.month3_returns IS NOT NULL")
-	case "sixMonth":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.month6_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.month6_returns IS NOT NULL")
-	case "oneYear":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.yr1_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.yr1_returns IS NOT NULL")
-	case "twoYear":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.yr2_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.yr2_returns IS NOT NULL")
-	case "threeYear":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.yr3_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.yr3_returns IS NOT NULL")
-	case "fiveYear":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.yr5_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.yr5_returns IS NOT NULL")
-	case "ytd":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "fund_reports.over_all_returns"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("fund_reports.over_all_returns IS NOT NULL")
-	case "sharpeRatio":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "funds.sharpe_ratio3_yrs"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("funds.sharpe_ratio3_yrs IS NOT NULL")
-	case "maxDrawdown":
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "funds.max_drawdown3_yrs"},
-				Desc:   isDesc,
-			}},
-		})
-		tx = tx.Where("funds.max_drawdown3_yrs IS NOT NULL")
-	default:
-		tx = tx.Order(clause.OrderBy{
-			Columns: []clause.OrderByColumn{{
-				Column: clause.Column{Name: "funds.name"},
-				Desc:   isDesc,
-			}},
-		})
-
+		query.FundHouseID, _ = strconv.ParseUint(fundHouseID, 10, 64)
 	}
 
-	reports := []crawler.FundReport{}
-	if err := tx.Session(&gorm.Session{}).Limit(perPageInt).Offset(pageInt).Find(&reports).Error; err != nil {
-		http.Error(w, "Error fetching reports", http.StatusInternalServerError)
-		return
+	var reports []crawler.FundReport
+	var total int64
+	if listed {
+		reports, total, err = h.store.Reports.Explore(r.Context(), query)
+		if err != nil {
+			http.Error(w, "Error fetching reports", http.StatusInternalServerError)
+			return
+		}
 	}
+	resp.Total = total
 
-	funds := []crawler.Fund{}
 	fundIDs := make([]uint64, len(reports))
 	for i, report := range reports {
 		fundIDs[i] = report.FundID
 	}
-	if err := db.Model(&crawler.Fund{}).Where("id in ?", fundIDs).Preload("FundManagers").Find(&funds).Error; err != nil {
-		http.Error(w, "Error fetching funds", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Count(&resp.Total).Error; err != nil {
+	funds, err := h.store.Funds.ListByIDs(r.Context(), fundIDs)
+	if err != nil {
 		http.Error(w, "Error fetching funds", http.StatusInternalServerError)
 		return
 	}
@@ -497,7 +439,7 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 		}
-		if report == nil || report.AUM() == nil || *report.AUM() < 25 {
+		if report == nil || report.AUM == nil || *report.AUM < 25 {
 			continue
 		}
 
@@ -511,7 +453,7 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 
 		var manager string
 		if len(fund.FundManagers) != 0 {
-			manager = ToTitleCase(fund.FundManagers[0].RegistrationName())
+			manager = ToTitleCase(fund.FundManagers[0].RegistrationName)
 		}
 
 		if fund.MaxDrawdown3Yrs != nil {
@@ -522,7 +464,7 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 
 		var fundManagerSlug string
 		if len(fund.FundManagers) > 0 {
-			fundManagerSlug = fund.FundManagers[0].OtherData["slug"]
+			fundManagerSlug = fund.FundManagers[0].Slug
 		}
 
 		resp.Data = append(resp.Data, struct {
@@ -551,7 +493,7 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 			ID:         fund.ID,
 			Name:       ToTitleCase(fund.DisplayName()),
 			Manager:    manager,
-			AUM:        Round(report.AUM()),
+			AUM:        Round(report.AUM),
 			OneMonth:   Round(report.Month1Returns),
 			ThreeMonth: Round(report.Month3Returns),
 			SixMonth:   Round(report.Month6Returns),
@@ -573,6 +515,23 @@ func getExplorePMSData(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if format != "" {
+		table := &ExportTable{
+			Name: "pms-explore",
+			Headers: []string{"ID", "Scheme Name", "Manager", "AUM", "1M", "3M", "6M", "1Y", "2Y", "3Y", "4Y", "5Y",
+				"Last Year", "Second Last Year", "Third Last Year", "Fourth Last Year", "Fifth Last Year", "Fund House Slug"},
+			From: query.From,
+			To:   query.To,
+		}
+		for _, d := range resp.Data {
+			table.Rows = append(table.Rows, []any{float64(d.ID), d.Name, d.Manager, d.AUM, d.OneMonth, d.ThreeMonth, d.SixMonth,
+				d.OneYear, d.TwoYear, d.ThreeYear, d.FourYear, d.FiveYear,
+				d.LastYear, d.SecondLastYear, d.ThirdLastYear, d.FourthLastYear, d.FifthLastYear, d.Slug})
+		}
+		writeExport(w, r, format, table)
+		return
+	}
+
 	// Respond with JSON
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -611,50 +570,21 @@ func Round(num *float64) *float64 {
 
 }
 
-func getFundsByFilter(filter string) []string {
-	file, err := os.Open("./static/filters.csv")
-	if err != nil {
-		log.Error().Err(err).Msg("Error opening file")
-	}
-	defer file.Close()
-
-	// Create a new CSV reader
-	reader := csv.NewReader(file)
-
-	// Read all records
-	records, err := reader.ReadAll()
-	if err != nil {
-		log.Error().Err(err).Msg("Error opening file")
-	}
-
-	funds := make([]string, 0)
-	lo.ForEach(records, func(rec []string, i int) {
-		if len(rec) < 2 {
-			return
-		}
-		if rec[1] == filter {
-			funds = append(funds, rec[0])
-		}
-	})
-
-	return funds
-}
-
-func getAUMChart(w http.ResponseWriter, r *http.Request) {
-	db := crawler.Conn()
+func (h *HTTPHandlers) getAUMChart(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 	if slug == "" {
 		http.Error(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 
-	var fundManager crawler.FundManager
-	err := db.Model(&crawler.FundManager{}).Where("other_data->>'slug' = ?", slug).Select("id").Preload("Funds").First(&fundManager).Error
+	fundManager, err := h.store.FundHouses.GetBySlug(r.Context(), slug)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, "Fund manager not found", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "Error fetching fund manager", http.StatusInternalServerError)
+		return
 	}
 
 	var data []AUMChart
@@ -667,14 +597,14 @@ func getAUMChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.Model(&crawler.FundReport{}).Select("id", "report_date", "other_data->>'AUM' as aum").Where(&crawler.FundReport{
-		FundID: fundManager.Funds[0].ID,
-	}).Order("report_date").Find(&data).Error
-
+	reports, err := h.store.Reports.AUMSeries(r.Context(), fundManager.Funds[0].ID)
 	if err != nil {
 		http.Error(w, "Error fetching AUM data", http.StatusInternalServerError)
 		return
 	}
+	for _, report := range reports {
+		data = append(data, AUMChart{ReportDate: report.ReportDate, AUM: report.AUM})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
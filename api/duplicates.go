@@ -0,0 +1,111 @@
+package api
+
+import (
+	"alpha2/crawler"
+	"alpha2/crawler/pmf"
+	"alpha2/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+)
+
+// SuggestDuplicates scores the pairs of funds of a fund house and queues the likely
+// duplicates for review. The min_score query parameter, from 0 to 1, overrides the
+// default threshold.
+func (h *HTTPHandlers) SuggestDuplicates(w http.ResponseWriter, r *http.Request) {
+	fundHouseID, err := strconv.ParseUint(chi.URLParam(r, "fund_house_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_house_id")))
+		return
+	}
+	minScore := crawler.DefaultDuplicateScore
+	if param := r.URL.Query().Get("min_score"); param != "" {
+		if minScore, err = strconv.ParseFloat(param, 64); err != nil || minScore < 0 || minScore > 1 {
+			render.Render(w, r, ErrBadRequest(fmt.Errorf("invalid min_score %q", param)))
+			return
+		}
+	}
+
+	suggestions, err := h.store.Duplicates.Suggest(r.Context(), fundHouseID, minScore)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, suggestions)
+}
+
+// ListDuplicates returns the duplicate suggestions with the status given by the
+// status query parameter, pending by default or all for "all", best first.
+func (h *HTTPHandlers) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = crawler.SuggestionPending
+	case "all":
+		status = ""
+	case crawler.SuggestionPending, crawler.SuggestionAccepted, crawler.SuggestionRejected:
+	default:
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("unknown status %q", status)))
+		return
+	}
+
+	suggestions, err := h.store.Duplicates.List(r.Context(), status)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, suggestions)
+}
+
+// AcceptDuplicate merges the duplicate of a pending suggestion into its fund and
+// queues the consistency job of the fund's fund houses.
+func (h *HTTPHandlers) AcceptDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid suggestion id")))
+		return
+	}
+	suggestion, err := h.store.Duplicates.Accept(r.Context(), id, adminName(r))
+	if err != nil {
+		renderDuplicateError(w, r, err)
+		return
+	}
+
+	if err := pmf.ScheduleDataConsistencyForFund(r.Context(), h.store, suggestion.FundID); err != nil {
+		log.Error().Err(err).Uint64("fund_id", suggestion.FundID).Msg("Error while scheduling PMSDataConsistency job")
+	}
+
+	render.JSON(w, r, suggestion)
+}
+
+// RejectDuplicate marks a pending suggestion as not duplicates, so the pair is not
+// suggested again.
+func (h *HTTPHandlers) RejectDuplicate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid suggestion id")))
+		return
+	}
+	suggestion, err := h.store.Duplicates.Reject(r.Context(), id, adminName(r))
+	if err != nil {
+		renderDuplicateError(w, r, err)
+		return
+	}
+	render.JSON(w, r, suggestion)
+}
+
+func renderDuplicateError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		render.Render(w, r, ErrNotFound(errors.New("suggestion not found")))
+	case errors.Is(err, store.ErrDecided), errors.Is(err, store.ErrAlreadyMerged), errors.Is(err, store.ErrInvalidMerge):
+		render.Render(w, r, ErrConflict(err))
+	default:
+		render.Render(w, r, ErrInternalServerError(err))
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	exportFormatCSV  = "csv"
+	exportFormatXLSX = "xlsx"
+)
+
+// ExportTable is a tabular view of an API response that can be written as CSV or XLSX.
+// Row values should be string, float64, *float64, int, int64, bool or *time.Time so
+// that the workbook gets typed cells; IDs are written as float64.
+type ExportTable struct {
+	Name    string
+	Headers []string
+	Rows    [][]any
+	// From and To bound the report dates of the data when it covers a period. To is
+	// the as_of date in the metadata.
+	From, To time.Time
+}
+
+// exportFormat returns the requested export format, or "" for the default JSON response.
+func exportFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "", "json":
+		return "", nil
+	case exportFormatCSV, exportFormatXLSX:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported format %q (use csv or xlsx)", format)
+}
+
+// writeExport writes the table in the given format as an attachment. The file is
+// built before anything is written, so a failure is still reported as an error.
+func writeExport(w http.ResponseWriter, r *http.Request, format string, table *ExportTable) {
+	filename := fmt.Sprintf("%s-%s.%s", table.Name, time.Now().Format(time.DateOnly), format)
+	meta := exportMetadata(r, table)
+	var buf bytes.Buffer
+	var contentType string
+	var err error
+	switch format {
+	case exportFormatCSV:
+		contentType = "text/csv"
+		err = writeCSV(&buf, table, meta)
+	case exportFormatXLSX:
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		var f *excelize.File
+		if f, err = buildWorkbook(table, meta); err == nil {
+			defer f.Close()
+			err = f.Write(&buf)
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Str("export", table.Name).Msg("Error building export")
+		http.Error(w, "Error exporting data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Error().Err(err).Str("export", table.Name).Msg("Error writing export")
+	}
+}
+
+// writeCSV writes the metadata as "# key: value" comment lines, then the table.
+func writeCSV(w io.Writer, table *ExportTable, meta [][2]string) error {
+	for _, kv := range meta {
+		value := strings.Join(strings.Fields(kv[1]), " ")
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", kv[0], value); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(table.Headers); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			rec[i] = csvValue(v)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case *float64:
+		if val == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*val, 'f', -1, 64)
+	case *time.Time:
+		if val == nil {
+			return ""
+		}
+		return val.Format(time.DateOnly)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// buildWorkbook creates a workbook with a data sheet and a metadata sheet.
+func buildWorkbook(table *ExportTable, meta [][2]string) (*excelize.File, error) {
+	f := excelize.NewFile()
+	sheet := "Data"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	header := make([]any, len(table.Headers))
+	for i, h := range table.Headers {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return nil, err
+	}
+
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, row := range table.Rows {
+		for j, v := range row {
+			cell, err := excelize.CoordinatesToCellName(j+1, i+2)
+			if err != nil {
+				return nil, err
+			}
+			switch val := v.(type) {
+			case nil:
+				continue
+			case *float64:
+				if val == nil {
+					continue
+				}
+				err = f.SetCellFloat(sheet, cell, *val, -1, 64)
+			case float64:
+				err = f.SetCellFloat(sheet, cell, val, -1, 64)
+			case *time.Time:
+				if val == nil {
+					continue
+				}
+				if err = f.SetCellValue(sheet, cell, *val); err == nil {
+					err = f.SetCellStyle(sheet, cell, cell, dateStyle)
+				}
+			default:
+				err = f.SetCellValue(sheet, cell, val)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := f.NewSheet("Metadata"); err != nil {
+		return nil, err
+	}
+	for i, kv := range meta {
+		row := []any{kv[0], kv[1]}
+		if err := f.SetSheetRow("Metadata", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// exportMetadata describes the export: the period of the data, when it was
+// generated and the filters applied.
+func exportMetadata(r *http.Request, table *ExportTable) [][2]string {
+	var meta [][2]string
+	if !table.From.IsZero() {
+		meta = append(meta, [2]string{"from", table.From.Format(time.DateOnly)})
+	}
+	if !table.To.IsZero() {
+		meta = append(meta, [2]string{"as_of", table.To.Format(time.DateOnly)})
+	}
+	meta = append(meta,
+		[2]string{"generated_at", time.Now().Format(time.RFC3339)},
+		[2]string{"path", r.URL.Path},
+	)
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k == "format" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		meta = append(meta, [2]string{k, strings.Join(query[k], ",")})
+	}
+	return meta
+}
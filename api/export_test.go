@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"alpha2/crawler"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestBuildWorkbook(t *testing.T) {
+	ret := 12.5
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	table := &ExportTable{
+		Name:    "test",
+		Headers: []string{"Report Date", "Returns", "Name"},
+		Rows: [][]any{
+			{&date, &ret, "Fund A"},
+			{nil, (*float64)(nil), "Fund B"},
+		},
+	}
+
+	f, err := buildWorkbook(table, [][2]string{{"as_of", "2025-01-01"}})
+	if err != nil {
+		t.Fatalf("buildWorkbook() error = %v", err)
+	}
+	defer f.Close()
+
+	if got, _ := f.GetCellValue("Data", "A1"); got != "Report Date" {
+		t.Errorf("header A1 = %q, want %q", got, "Report Date")
+	}
+	if typ, _ := f.GetCellType("Data", "B2"); typ != excelize.CellTypeNumber && typ != excelize.CellTypeUnset {
+		t.Errorf("B2 cell type = %v, want number", typ)
+	}
+	if got, _ := f.GetCellValue("Data", "B2", excelize.Options{RawCellValue: true}); got != "12.5" {
+		t.Errorf("B2 = %q, want %q", got, "12.5")
+	}
+	if got, _ := f.GetCellValue("Data", "B3"); got != "" {
+		t.Errorf("B3 = %q, want empty for nil value", got)
+	}
+	if got, _ := f.GetCellValue("Metadata", "A1"); got != "as_of" {
+		t.Errorf("Metadata A1 = %q, want %q", got, "as_of")
+	}
+}
+
+func TestCsvValue(t *testing.T) {
+	v := 1.25
+	date := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{name: "nil", in: nil, want: ""},
+		{name: "nil float pointer", in: (*float64)(nil), want: ""},
+		{name: "float pointer", in: &v, want: "1.25"},
+		{name: "date", in: &date, want: "2025-03-01"},
+		{name: "bool", in: true, want: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvValue(tt.in); got != tt.want {
+				t.Errorf("csvValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSVLabelsRoundTrip(t *testing.T) {
+	table := &ExportTable{
+		Name:    "fund-labels",
+		Headers: []string{"ID", "Name", "Label", "Canonical"},
+		Rows:    [][]any{{float64(5141), "Fund A", "Growth", "1"}},
+	}
+	var buf bytes.Buffer
+	meta := [][2]string{{"as_of", "2025-01-31"}, {"search", "multi\nline"}}
+	if err := writeCSV(&buf, table, meta); err != nil {
+		t.Fatalf("writeCSV() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# as_of: 2025-01-31\n# search: multi line\nID,") {
+		t.Errorf("writeCSV() = %q, want metadata lines before the header", buf.String())
+	}
+
+	rows, err := crawler.ReadLabelRows(&buf)
+	if err != nil {
+		t.Fatalf("ReadLabelRows() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].FundID != 5141 || rows[0].Label != "Growth" || !rows[0].Canonical {
+		t.Errorf("ReadLabelRows() = %+v, want fund 5141 labelled Growth", rows)
+	}
+}
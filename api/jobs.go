@@ -2,49 +2,76 @@ package api
 
 import (
 	"alpha2/jobs"
+	"alpha2/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/reugn/go-quartz/quartz"
-	"gorm.io/gorm"
+	"github.com/rs/zerolog/log"
 )
 
-// JobRequest represents the request body for adding a job.
+// JobRequest represents the request body for creating a job.
 type JobRequest struct {
-	Name     string `json:"name"`
-	Group    string `json:"group"`
-	Data     string `json:"data"`
-	NextRun  int64  `json:"next_run"` // Unix timestamp
-	Priority int    `json:"priority"`
+	Group   string          `json:"group"`
+	Name    string          `json:"name"`    // Defaults to a name derived from the time
+	Payload json.RawMessage `json:"payload"` // Fields of the job, validated against its group
+	RunAt   *time.Time      `json:"run_at"`  // Defaults to now
 }
 
 // JobResponse represents the response for a job.
 type JobResponse struct {
 	ID       uint   `json:"id"`
+	Key      string `json:"key"`
 	Name     string `json:"name"`
 	Group    string `json:"group"`
 	Data     string `json:"data"`
+	Payload  string `json:"payload"` // Fields of the job, as sent to CreateJob
 	NextRun  int64  `json:"next_run"`
 	Priority int    `json:"priority"`
 	Status   string `json:"status"`
 }
 
+// JobRunResponse represents one recorded execution attempt of a job.
+type JobRunResponse struct {
+	ID          uint      `json:"id"`
+	Key         string    `json:"key"`
+	Name        string    `json:"name"`
+	Group       string    `json:"group"`
+	Description string    `json:"description"`
+	Attempt     int       `json:"attempt"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+	Stage       string    `json:"stage,omitempty"`
+	Done        int64     `json:"done"`
+	Total       int64     `json:"total"`
+}
+
 // HTTPHandlers contains the handlers for the HTTP routes.
 type HTTPHandlers struct {
 	scheduler quartz.Scheduler
-	db        *gorm.DB
+	store     *store.Store
 }
 
 // NewHTTPHandlers initializes the HTTP handlers.
-func NewHTTPHandlers(scheduler quartz.Scheduler, db *gorm.DB) *HTTPHandlers {
-	return &HTTPHandlers{scheduler: scheduler, db: db}
+func NewHTTPHandlers(scheduler quartz.Scheduler, st *store.Store) *HTTPHandlers {
+	return &HTTPHandlers{scheduler: scheduler, store: st}
 }
 
 // GetUpcomingJobs returns a list of upcoming jobs.
 func (h *HTTPHandlers) GetUpcomingJobs(w http.ResponseWriter, r *http.Request) {
-	var jobs []jobs.ScheduledJob
-	if err := h.db.Where("nxt_run_time > ?", time.Now().UnixNano()).Find(&jobs).Error; err != nil {
+	jobs, err := h.store.Jobs.Upcoming(r.Context(), time.Now())
+	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
@@ -55,7 +82,8 @@ func (h *HTTPHandlers) GetUpcomingJobs(w http.ResponseWriter, r *http.Request) {
 			ID:      job.ID,
 			Name:    job.JobName,
 			Group:   job.JobGroup,
-			Data:    job.JobData,
+			Data:    job.JobDescription,
+			Payload: job.JobData,
 			NextRun: job.NextRunTime(),
 			Status:  job.JobStatus,
 		})
@@ -64,8 +92,17 @@ func (h *HTTPHandlers) GetUpcomingJobs(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, jobResponses)
 }
 
-// GetCompletedJobs returns a list of completed jobs filtered by timeframe and group name.
+// GetCompletedJobs returns the successful job runs filtered by timeframe and group name.
 func (h *HTTPHandlers) GetCompletedJobs(w http.ResponseWriter, r *http.Request) {
+	h.getJobRuns(w, r, jobs.RunCompleted)
+}
+
+// GetFailedJobs returns the failed job runs filtered by timeframe and group name.
+func (h *HTTPHandlers) GetFailedJobs(w http.ResponseWriter, r *http.Request) {
+	h.getJobRuns(w, r, jobs.RunFailed)
+}
+
+func (h *HTTPHandlers) getJobRuns(w http.ResponseWriter, r *http.Request, outcome string) {
 	// Parse query parameters
 	groupName := r.URL.Query().Get("group")
 	startTime := r.URL.Query().Get("start_time")
@@ -80,86 +117,411 @@ func (h *HTTPHandlers) GetCompletedJobs(w http.ResponseWriter, r *http.Request)
 		end, _ = time.Parse(time.RFC3339, endTime)
 	}
 
-	// Build the query
-	query := h.db.Where("job_status = ?", "completed")
-	if groupName != "" {
-		query = query.Where("job_group = ?", groupName)
+	runs, err := h.store.Jobs.Runs(r.Context(), store.RunFilter{
+		Group:   groupName,
+		Outcome: outcome,
+		Start:   start,
+		End:     end,
+	})
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
 	}
-	if startTime != "" {
-		query = query.Where("nxt_run_time >= ?", start.UnixNano())
+
+	runResponses := make([]JobRunResponse, 0, len(runs))
+	for _, run := range runs {
+		runResponses = append(runResponses, JobRunResponse{
+			ID:          run.ID,
+			Key:         run.JobKey,
+			Name:        run.JobName,
+			Group:       run.JobGroup,
+			Description: run.JobDescription,
+			Attempt:     run.Attempt,
+			StartedAt:   run.StartedAt,
+			FinishedAt:  run.FinishedAt,
+			DurationMs:  run.DurationMs,
+			Outcome:     run.Outcome,
+			Error:       run.Error,
+			Stage:       run.Stage,
+			Done:        run.ProgressDone,
+			Total:       run.ProgressTotal,
+		})
 	}
-	if endTime != "" {
-		query = query.Where("nxt_run_time <= ?", end.UnixNano())
+
+	render.JSON(w, r, runResponses)
+}
+
+func newJobResponse(job jobs.ScheduledJob) JobResponse {
+	return JobResponse{
+		ID:      job.ID,
+		Key:     job.JobKey,
+		Name:    job.JobName,
+		Group:   job.JobGroup,
+		Data:    job.JobDescription,
+		Payload: job.JobData,
+		NextRun: job.NextRunTime(),
+		Status:  job.JobStatus,
 	}
+}
 
-	// Fetch completed jobs
-	var jobs []jobs.ScheduledJob
-	if err := query.Find(&jobs).Error; err != nil {
-		render.Render(w, r, ErrInternalServerError(err))
+// ListJobs returns the queued jobs, optionally filtered by group and status.
+func (h *HTTPHandlers) ListJobs(w http.ResponseWriter, r *http.Request) {
+	filter := store.JobFilter{
+		Group:  r.URL.Query().Get("group"),
+		Status: r.URL.Query().Get("status"),
+	}
+	switch filter.Status {
+	case "", jobs.StatusScheduled, jobs.StatusRunning, jobs.StatusPaused, jobs.StatusBlocked:
+	default:
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("unknown status %q", filter.Status)))
 		return
 	}
 
-	jobResponses := make([]JobResponse, 0, len(jobs))
-	for _, job := range jobs {
-		jobResponses = append(jobResponses, JobResponse{
-			ID:      job.ID,
-			Name:    job.JobName,
-			Group:   job.JobGroup,
-			Data:    job.JobData,
-			NextRun: job.NextRunTime(),
-			Status:  job.JobStatus,
-		})
+	queued, err := h.store.Jobs.List(r.Context(), filter)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
 	}
 
+	jobResponses := make([]JobResponse, 0, len(queued))
+	for _, job := range queued {
+		jobResponses = append(jobResponses, newJobResponse(job))
+	}
 	render.JSON(w, r, jobResponses)
 }
 
-// AddJob adds a new job with a specified group name.
-func (h *HTTPHandlers) AddJob(w http.ResponseWriter, r *http.Request) {
+// CreateJob schedules a job of any registered group to run once.
+func (h *HTTPHandlers) CreateJob(w http.ResponseWriter, r *http.Request) {
 	var req JobRequest
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
 		render.Render(w, r, ErrBadRequest(err))
 		return
 	}
 
-	// Create a new job
-	job := quartz.NewJobDetailWithOptions(jobs.GetJob(req.Group), quartz.NewJobKeyWithGroup(req.Name, req.Group),
+	job, err := jobs.NewJob(req.Group, req.Payload)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("admin-%d", time.Now().UnixNano())
+	}
+	var delay time.Duration
+	if req.RunAt != nil {
+		delay = max(time.Until(*req.RunAt), 0)
+	}
+
+	jobDetail := quartz.NewJobDetailWithOptions(job, quartz.NewJobKeyWithGroup(req.Name, req.Group),
 		&quartz.JobDetailOptions{
 			MaxRetries:    10,
 			RetryInterval: time.Minute * 5,
 			Replace:       false,
 			Suspended:     false,
 		})
-	trigger := quartz.NewRunOnceTrigger(time.Second * 1)
+	// The queue persists the job; it must not be inserted again here.
+	if err := h.scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(delay)); err != nil {
+		if errors.Is(err, quartz.ErrJobAlreadyExists) {
+			render.Render(w, r, ErrConflict(err))
+			return
+		}
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
 
-	// Schedule the job
-	if err := h.scheduler.ScheduleJob(job, trigger); err != nil {
+	key := jobDetail.JobKey().String()
+	queued, err := h.store.Jobs.List(r.Context(), store.JobFilter{Key: key})
+	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
+	if len(queued) == 0 {
+		// Already run by a scheduler; its latest run tells how it went.
+		response := JobResponse{Key: key, Name: req.Name, Group: req.Group}
+		run, err := h.store.Jobs.LatestRun(r.Context(), key)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			render.Render(w, r, ErrInternalServerError(err))
+			return
+		}
+		if run != nil {
+			response.Status = run.Outcome
+		}
+		render.Status(r, http.StatusAccepted)
+		render.JSON(w, r, response)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, newJobResponse(queued[0]))
+}
+
+// jobKeyParam returns the key URL parameter unescaped. Keys may contain slashes, such
+// as the AIF registration numbers in crawl keys, which clients send as %2F.
+func jobKeyParam(r *http.Request) (string, error) {
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil {
+		return "", errors.New("invalid key")
+	}
+	return key, nil
+}
 
-	// Save the job to the database
-	scheduledJob := jobs.ScheduledJob{
-		JobKey:     job.JobKey().String(),
-		JobName:    req.Name,
-		JobGroup:   req.Group,
-		JobData:    req.Data,
-		NxtRunTime: req.NextRun,
-		JobStatus:  "scheduled",
+// TriggerJob makes a scheduled job due immediately.
+func (h *HTTPHandlers) TriggerJob(w http.ResponseWriter, r *http.Request) {
+	key, err := jobKeyParam(r)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if err := h.store.Jobs.TriggerNow(r.Context(), key); err != nil {
+		h.renderJobError(w, r, err)
+		return
 	}
-	if err := h.db.Create(&scheduledJob).Error; err != nil {
+	jobs.Wake()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CancelJob removes a job that has not started running.
+func (h *HTTPHandlers) CancelJob(w http.ResponseWriter, r *http.Request) {
+	key, err := jobKeyParam(r)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if err := h.store.Jobs.Cancel(r.Context(), key); err != nil {
+		h.renderJobError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PauseJobGroup stops the jobs of a group from running until it is resumed.
+func (h *HTTPHandlers) PauseJobGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Jobs.PauseGroup(r.Context(), chi.URLParam(r, "group")); err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	render.JSON(w, r, JobResponse{
-		ID:      scheduledJob.ID,
-		Name:    scheduledJob.JobName,
-		Group:   scheduledJob.JobGroup,
-		Data:    scheduledJob.JobData,
-		NextRun: scheduledJob.NextRunTime(),
-		Status:  scheduledJob.JobStatus,
-	})
+// ResumeJobGroup lets the jobs of a paused group run again. Jobs that became due while
+// paused run immediately.
+func (h *HTTPHandlers) ResumeJobGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Jobs.ResumeGroup(r.Context(), chi.URLParam(r, "group")); err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	jobs.Wake()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JobEvent is sent on the event stream of a job whenever its status or progress
+// changes.
+type JobEvent struct {
+	Key     string `json:"key"`
+	Status  string `json:"status,omitempty"` // Status of the queued job; empty once it left the queue
+	Attempt int    `json:"attempt,omitempty"`
+	Outcome string `json:"outcome,omitempty"` // Outcome of the latest run
+	Error   string `json:"error,omitempty"`
+	Stage   string `json:"stage,omitempty"`
+	Done    int64  `json:"done"`
+	Total   int64  `json:"total"`
+}
+
+const (
+	jobEventsInterval  = time.Second
+	jobEventsKeepAlive = 15 * time.Second
+)
+
+// JobEvents streams the status and progress of a job as Server-Sent Events. A
+// "progress" event is sent on every change and a final "done" event once the job
+// left the queue. The job may run on another instance, so its state is read from
+// the database. Clients reconnect when the server timeout ends the stream.
+func (h *HTTPHandlers) JobEvents(w http.ResponseWriter, r *http.Request) {
+	key, err := jobKeyParam(r)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		render.Render(w, r, ErrInternalServerError(errors.New("streaming is not supported")))
+		return
+	}
+
+	event, finished, err := h.jobEvent(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(errors.New("no job with this key")))
+			return
+		}
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(jobEventsInterval)
+	defer ticker.Stop()
+	var last *JobEvent
+	sent := time.Now()
+	for {
+		switch {
+		case finished:
+			writeEvent(w, "done", event)
+			flusher.Flush()
+			return
+		case last == nil || *event != *last:
+			writeEvent(w, "progress", event)
+			last, sent = event, time.Now()
+		case time.Since(sent) >= jobEventsKeepAlive:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			sent = time.Now()
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		next, done, err := h.jobEvent(r.Context(), key)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Error().Err(err).Str("key", key).Msg("Error while reading job events")
+			}
+			return
+		}
+		event, finished = next, done
+	}
+}
+
+// jobEvent returns the current state of the job with the given key and whether it
+// left the queue, or ErrNotFound if the job is neither queued nor has run.
+func (h *HTTPHandlers) jobEvent(ctx context.Context, key string) (*JobEvent, bool, error) {
+	queued, err := h.store.Jobs.List(ctx, store.JobFilter{Key: key})
+	if err != nil {
+		return nil, false, err
+	}
+	run, err := h.store.Jobs.LatestRun(ctx, key)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, false, err
+	}
+	if len(queued) == 0 && run == nil {
+		return nil, false, store.ErrNotFound
+	}
+
+	event := &JobEvent{Key: key}
+	if len(queued) > 0 {
+		event.Status = queued[0].JobStatus
+	}
+	if run != nil {
+		event.Attempt = run.Attempt
+		event.Outcome = run.Outcome
+		event.Error = run.Error
+		event.Stage = run.Stage
+		event.Done = run.ProgressDone
+		event.Total = run.ProgressTotal
+	}
+	return event, len(queued) == 0 && run.Outcome != jobs.RunRunning, nil
+}
+
+func writeEvent(w http.ResponseWriter, name string, event *JobEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
+
+// DeadJobResponse represents a job that exhausted its retries.
+type DeadJobResponse struct {
+	ID       uint      `json:"id"`
+	Key      string    `json:"key"`
+	Name     string    `json:"name"`
+	Group    string    `json:"group"`
+	Data     string    `json:"data"`
+	Payload  string    `json:"payload"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// ListDeadJobs returns the jobs that exhausted their retries, optionally filtered by group.
+func (h *HTTPHandlers) ListDeadJobs(w http.ResponseWriter, r *http.Request) {
+	dead, err := h.store.Jobs.Dead(r.Context(), r.URL.Query().Get("group"))
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	deadResponses := make([]DeadJobResponse, 0, len(dead))
+	for _, job := range dead {
+		deadResponses = append(deadResponses, DeadJobResponse{
+			ID:       job.ID,
+			Key:      job.JobKey,
+			Name:     job.JobName,
+			Group:    job.JobGroup,
+			Data:     job.JobDescription,
+			Payload:  job.JobData,
+			Attempts: job.Attempts,
+			Error:    job.Error,
+			FailedAt: job.FailedAt,
+		})
+	}
+	render.JSON(w, r, deadResponses)
+}
+
+// ReplayDeadJob queues a dead job to run again.
+func (h *HTTPHandlers) ReplayDeadJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	dead, err := h.store.Jobs.GetDead(r.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(errors.New("no dead job with this id")))
+			return
+		}
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	if err := h.store.Jobs.ReplayDead(r.Context(), dead); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(errors.New("no dead job with this id")))
+			return
+		}
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplayDeadJobs queues every dead job, optionally of one group, to run again.
+func (h *HTTPHandlers) ReplayDeadJobs(w http.ResponseWriter, r *http.Request) {
+	dead, err := h.store.Jobs.Dead(r.Context(), r.URL.Query().Get("group"))
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	replayed := 0
+	for _, job := range dead {
+		if err := h.store.Jobs.ReplayDead(r.Context(), &job); err != nil {
+			log.Error().Err(err).Uint("id", job.ID).Msg("Error while replaying dead job")
+			continue
+		}
+		replayed++
+	}
+	render.JSON(w, r, map[string]int{"replayed": replayed, "failed": len(dead) - replayed})
+}
+
+func (h *HTTPHandlers) renderJobError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		render.Render(w, r, ErrNotFound(errors.New("no waiting job with this key")))
+		return
+	}
+	render.Render(w, r, ErrInternalServerError(err))
 }
 
 // ErrResponse represents an error response.
@@ -188,6 +550,26 @@ func ErrBadRequest(err error) render.Renderer {
 	}
 }
 
+// ErrNotFound returns a 404 Not Found error.
+func ErrNotFound(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusNotFound,
+		StatusText:     "Not Found",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrConflict returns a 409 Conflict error.
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict",
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrInternalServerError returns a 500 Internal Server Error.
 func ErrInternalServerError(err error) render.Renderer {
 	return &ErrResponse{
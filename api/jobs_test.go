@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestJobKeyParamUnescapesSlashes(t *testing.T) {
+	var got string
+	router := chi.NewRouter()
+	router.Delete("/admin/jobs/{key}", func(w http.ResponseWriter, r *http.Request) {
+		got, _ = jobKeyParam(r)
+	})
+
+	key := "SourceCrawl::aif-IN/AIF3/13-14/0044-2025-01-01"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/jobs/"+url.PathEscape(key), nil))
+	if got != key {
+		t.Errorf("jobKeyParam() = %q, want %q", got, key)
+	}
+}
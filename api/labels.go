@@ -0,0 +1,163 @@
+package api
+
+import (
+	"alpha2/crawler"
+	"alpha2/crawler/pmf"
+	"alpha2/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+)
+
+// maxLabelFileSize caps the size of an imported label file.
+const maxLabelFileSize = 10 << 20
+
+// SetFundLabel labels a fund from the JSON body, or merges it into canonical_id,
+// which takes the label when one is given.
+func (h *HTTPHandlers) SetFundLabel(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	update := store.LabelUpdate{}
+	if err := render.DecodeJSON(r.Body, &update); err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+
+	fund, err := h.store.Labels.Set(r.Context(), fundID, update, adminName(r))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		render.Render(w, r, ErrNotFound(errors.New("fund not found")))
+		return
+	case errors.Is(err, store.ErrInvalidLabel):
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	case errors.Is(err, store.ErrInvalidMerge), errors.Is(err, store.ErrAlreadyMerged):
+		render.Render(w, r, ErrConflict(err))
+		return
+	case err != nil:
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	if update.CanonicalID != nil {
+		if err := pmf.ScheduleDataConsistencyForFund(r.Context(), h.store, *update.CanonicalID); err != nil {
+			log.Error().Err(err).Uint64("fund_id", *update.CanonicalID).Msg("Error scheduling PMSDataConsistency job")
+		}
+	}
+	render.JSON(w, r, fund)
+}
+
+// ImportLabels applies the label file in the "file" form field, in the form
+// ExportLabels writes with format=csv. Nothing is changed when any row is invalid;
+// pass dry_run=true to only report what would change.
+func (h *HTTPHandlers) ImportLabels(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLabelFileSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("reading file: %w", err)))
+		return
+	}
+	defer file.Close()
+
+	rows, err := crawler.ReadLabelRows(file)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	result, err := h.store.Labels.Import(r.Context(), rows, adminName(r), dryRun)
+	if errors.Is(err, store.ErrInvalidLabels) {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, result)
+		return
+	} else if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, result)
+}
+
+// ExportLabels returns a row for every labelled fund; with format=csv it is the
+// file ImportLabels reads.
+func (h *HTTPHandlers) ExportLabels(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	rows, err := h.store.Labels.Export(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	if format != "" {
+		table := &ExportTable{
+			Name:    "fund-labels",
+			Headers: []string{"ID", "Name", "Label", "Canonical"},
+		}
+		for _, row := range rows {
+			canonical := ""
+			if row.Canonical {
+				canonical = "1"
+			}
+			table.Rows = append(table.Rows, []any{float64(row.FundID), row.Name, row.Label, canonical})
+		}
+		writeExport(w, r, format, table)
+		return
+	}
+	render.JSON(w, r, rows)
+}
+
+// ListUnlabelledFunds returns the queue of unmerged PMS strategies and AIFs without
+// a label, newest first, optionally of the given type and paged with page and
+// per_page.
+func (h *HTTPHandlers) ListUnlabelledFunds(w http.ResponseWriter, r *http.Request) {
+	filter := store.UnlabelledFilter{Type: r.URL.Query().Get("type"), Limit: 50}
+	if perPage := r.URL.Query().Get("per_page"); perPage != "" {
+		limit, err := strconv.Atoi(perPage)
+		if err != nil || limit < 1 {
+			render.Render(w, r, ErrBadRequest(errors.New("invalid per_page")))
+			return
+		}
+		filter.Limit = limit
+	}
+	if page := r.URL.Query().Get("page"); page != "" {
+		pageInt, err := strconv.Atoi(page)
+		if err != nil || pageInt < 1 {
+			render.Render(w, r, ErrBadRequest(errors.New("invalid page")))
+			return
+		}
+		filter.Offset = (pageInt - 1) * filter.Limit
+	}
+
+	funds, err := h.store.Labels.Unlabelled(r.Context(), filter)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, funds)
+}
+
+// ListLabelChanges returns the label changes of a fund, newest first.
+func (h *HTTPHandlers) ListLabelChanges(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	changes, err := h.store.Labels.History(r.Context(), fundID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, changes)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"alpha2/store"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// PreviewMerge returns the months merging the fund into merge_fund_id would fill and
+// the months both have reports for, with the figures that conflict.
+func (h *HTTPHandlers) PreviewMerge(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	targetID, err := strconv.ParseUint(chi.URLParam(r, "merge_fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid merge_fund_id")))
+		return
+	}
+
+	preview, err := h.store.Merges.Preview(r.Context(), fundID, targetID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		render.Render(w, r, ErrNotFound(err))
+	case errors.Is(err, store.ErrInvalidMerge):
+		render.Render(w, r, ErrConflict(err))
+	case err != nil:
+		render.Render(w, r, ErrInternalServerError(err))
+	default:
+		render.JSON(w, r, preview)
+	}
+}
+
+// ListMerges returns the merges of the fund into another and of others into it,
+// newest first, including undone ones.
+func (h *HTTPHandlers) ListMerges(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	events, err := h.store.Merges.ForFund(r.Context(), fundID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, events)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"alpha2/crawler"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// reportCandidate is a report of a fund and whether it is the effective one of its
+// month.
+type reportCandidate struct {
+	*crawler.FundReport
+	Effective bool `json:"effective"`
+}
+
+// ListReportCandidates returns every report of a fund with its provenance, marking
+// the one of each month shown by the read APIs, so admins can see why a month shows
+// the value it does.
+func (h *HTTPHandlers) ListReportCandidates(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	reports, err := h.store.Reports.AllForFund(r.Context(), fundID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	effective := make(map[uint64]bool)
+	for _, report := range crawler.ResolveReports(reports) {
+		effective[report.ID] = true
+	}
+	candidates := make([]reportCandidate, len(reports))
+	for idx, report := range reports {
+		candidates[idx] = reportCandidate{FundReport: report, Effective: effective[report.ID]}
+	}
+	render.JSON(w, r, candidates)
+}
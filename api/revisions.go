@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// restatementWindow is how far back ListRestatements looks without a since parameter.
+const restatementWindow = 30 * 24 * time.Hour
+
+// ListReportRevisions returns the changes made to the reports of a fund, newest first.
+func (h *HTTPHandlers) ListReportRevisions(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	revisions, err := h.store.Revisions.ForFund(r.Context(), fundID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, revisions)
+}
+
+// ListRestatements returns the revisions that changed a return by more than the
+// restatement threshold since the RFC 3339 time in the since query parameter, or in
+// the last 30 days.
+func (h *HTTPHandlers) ListRestatements(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-restatementWindow)
+	if param := r.URL.Query().Get("since"); param != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, param); err != nil {
+			render.Render(w, r, ErrBadRequest(fmt.Errorf("invalid since: %w", err)))
+			return
+		}
+	}
+	revisions, err := h.store.Revisions.Restatements(r.Context(), since)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, revisions)
+}
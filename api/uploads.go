@@ -0,0 +1,168 @@
+package api
+
+import (
+	"alpha2/crawler"
+	"alpha2/crawler/pmf"
+	"alpha2/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+)
+
+// maxUploadSize caps the size of an uploaded report file.
+const maxUploadSize = 10 << 20
+
+// UploadReports stages the monthly reports of a fund read from the CSV or XLSX file
+// in the "file" form field. They are only shown once the upload is approved.
+func (h *HTTPHandlers) UploadReports(w http.ResponseWriter, r *http.Request) {
+	fundID, err := strconv.ParseUint(chi.URLParam(r, "fund_id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid fund_id")))
+		return
+	}
+	if _, err := h.store.Funds.Get(r.Context(), fundID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(fmt.Errorf("fund %d not found", fundID)))
+			return
+		}
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("reading file: %w", err)))
+		return
+	}
+	defer file.Close()
+
+	reports, err := crawler.ReadReportUpload(header.Filename, file, time.Now())
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	upload := &crawler.ReportUpload{
+		FundID:     fundID,
+		FileName:   header.Filename,
+		UploadedBy: adminName(r),
+		Reports:    reports,
+	}
+	if err := h.store.Uploads.Create(r.Context(), upload); err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, upload)
+}
+
+// ListReportUploads returns the report uploads with the status given by the status
+// query parameter, pending by default or all for "all".
+func (h *HTTPHandlers) ListReportUploads(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = crawler.UploadPending
+	case "all":
+		status = ""
+	case crawler.UploadPending, crawler.UploadApproved, crawler.UploadRejected:
+	default:
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("unknown status %q", status)))
+		return
+	}
+
+	uploads, err := h.store.Uploads.List(r.Context(), status)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.JSON(w, r, uploads)
+}
+
+// GetReportUpload returns a report upload with its reports.
+func (h *HTTPHandlers) GetReportUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid upload id")))
+		return
+	}
+	upload, err := h.store.Uploads.Get(r.Context(), id)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+	render.JSON(w, r, upload)
+}
+
+// ApproveReportUpload saves the reports of a pending upload as manual reports of its
+// fund and queues the consistency job of the fund's fund houses, which unhides the
+// fund if the reports fill the gap.
+func (h *HTTPHandlers) ApproveReportUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid upload id")))
+		return
+	}
+	upload, err := h.store.Uploads.Approve(r.Context(), id, adminName(r))
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+
+	if err := pmf.ScheduleDataConsistencyForFund(r.Context(), h.store, upload.FundID); err != nil {
+		log.Error().Err(err).Uint64("fund_id", upload.FundID).Msg("Error while scheduling PMSDataConsistency job")
+	}
+
+	render.JSON(w, r, upload)
+}
+
+// RejectReportUpload discards a pending upload. The body may give the reason as
+// {"note": "..."}.
+func (h *HTTPHandlers) RejectReportUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrBadRequest(errors.New("invalid upload id")))
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			render.Render(w, r, ErrBadRequest(err))
+			return
+		}
+	}
+	upload, err := h.store.Uploads.Reject(r.Context(), id, adminName(r), req.Note)
+	if err != nil {
+		renderUploadError(w, r, err)
+		return
+	}
+	render.JSON(w, r, upload)
+}
+
+func renderUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		render.Render(w, r, ErrNotFound(errors.New("upload not found")))
+	case errors.Is(err, store.ErrReviewed):
+		render.Render(w, r, ErrConflict(err))
+	default:
+		render.Render(w, r, ErrInternalServerError(err))
+	}
+}
+
+// adminName returns the username in the JWT of the request.
+func adminName(r *http.Request) string {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	name, _ := claims["username"].(string)
+	return name
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"alpha2/crawler/aif"
+	"alpha2/crawler/sources"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var aifMonth string
+
+// aifImportCmd saves an AIF performance disclosure and queues the crawl of its month
+var aifImportCmd = &cobra.Command{
+	Use:   "aifImport <file>",
+	Short: "Import a monthly AIF performance disclosure",
+	Long: `Import a CSV disclosure of the performance of Category III AIFs for one month
+into crawler.aif.dir, replacing any imported before for that month, and queue the
+crawl of its AIFs from that month on.
+
+The header row names the columns: Registration Number and Scheme are required;
+Manager, Strategy, AUM and the returns (1 Month ... 5 Years, Since Inception) are read
+when present.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		period, err := time.Parse("2006-01", aifMonth)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid --month, want YYYY-MM")
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening disclosure")
+		}
+		defer file.Close()
+
+		st := openStore()
+		initJobs(st)
+		registrations, err := aif.NewSource(aif.Dir(), st).Import(period, file)
+		if err != nil {
+			log.Fatal().Err(err).Str("file", args[0]).Msg("Error importing disclosure")
+		}
+		log.Info().Int("aifs", len(registrations)).Str("month", aifMonth).Msg("Imported AIF disclosure")
+
+		if err := sources.ScheduleBackfill("aif", period); err != nil {
+			log.Fatal().Err(err).Msg("Error scheduling AIF crawl")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(aifImportCmd)
+
+	aifImportCmd.Flags().StringVar(&aifMonth, "month", "", "Month of the disclosure, as YYYY-MM")
+	aifImportCmd.MarkFlagRequired("month")
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"alpha2/crawler"
+	"alpha2/store"
 	"math"
 	"time"
 
@@ -25,7 +26,7 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		db := crawler.Conn()
+		db := openStore().DB(cmd.Context())
 
 		var funds []crawler.Fund
 		// db.FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
@@ -153,7 +154,7 @@ to quickly create a Cobra application.`,
 			fund := funds[0]
 			reports := []crawler.FundReport{}
 			now := time.Now().AddDate(0, -5, 0)
-			err := db.Where(&crawler.FundReport{
+			err := db.Scopes(store.EffectiveReports).Where(&crawler.FundReport{
 				FundID: fund.ID,
 			}).
 				Where("report_date > ?", now).
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"alpha2/crawler"
+	"alpha2/store"
 	"math"
 	"time"
 
@@ -21,14 +22,14 @@ var drawdownCmd = &cobra.Command{
 	Short: "Compute the maximum drawdown of a fund",
 	Long:  ` Compute the maximum drawdown of a fund. The maximum drawdown is the maximum loss from a peak to a trough of a portfolio, before a new peak is attained.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		db := crawler.Conn()
+		db := openStore().DB(cmd.Context())
 
 		var funds []crawler.Fund
 		db.FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
 			fund := funds[0]
 
 			var reports []crawler.FundReport
-			err := db.Where(&crawler.FundReport{
+			err := db.Scopes(store.EffectiveReports).Where(&crawler.FundReport{
 				FundID: fund.ID,
 			}).
 				Where("month1_returns IS NOT NULL").
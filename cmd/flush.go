@@ -26,10 +26,15 @@ var flushCmd = &cobra.Command{
 			&crawler.FundXFundManagers{},
 			&jobs.ScheduledJob{},
 		}
-		db := crawler.Conn()
+		db := openStore().DB(cmd.Context())
 		for _, table := range tables {
 			stmt := &gorm.Statement{DB: db}
 			stmt.Parse(table)
+			if db.Dialector.Name() == "sqlite" {
+				db.Exec(fmt.Sprintf("DELETE FROM %s", stmt.Schema.Table))
+				db.Exec("DELETE FROM sqlite_sequence WHERE name = ?", stmt.Schema.Table)
+				continue
+			}
 			db.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", stmt.Schema.Table))
 		}
 	},
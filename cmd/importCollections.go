@@ -0,0 +1,107 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"alpha2/crawler"
+	"alpha2/store"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var collectionsFile string
+
+// importCollectionsCmd imports the collections of static/filters.csv
+var importCollectionsCmd = &cobra.Command{
+	Use:   "importCollections",
+	Short: "Import the curated fund lists of static/filters.csv as collections",
+	Long: `Read the label,category rows of static/filters.csv and save each category as a
+collection of the unmerged funds with the labels listed under it, in file order.
+Categories are trimmed, so "Top Picks " and "Top Picks" are one collection.
+Collections that already exist are left as they are, so the import only runs once;
+edit them in the admin API afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		st := openStore()
+
+		file, err := os.Open(collectionsFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening file")
+		}
+		defer file.Close()
+		records, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error reading CSV")
+		}
+
+		var collections []*crawler.Collection
+		labels := make(map[string][]string)
+		for _, record := range records {
+			if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+				continue
+			}
+			collection := &crawler.Collection{Name: record[1]}
+			collection.Normalize()
+			if collection.Slug == "" {
+				continue
+			}
+			if _, ok := labels[collection.Slug]; !ok {
+				collections = append(collections, collection)
+			}
+			labels[collection.Slug] = append(labels[collection.Slug], strings.TrimSpace(record[0]))
+		}
+
+		for _, collection := range collections {
+			if _, err := st.Collections.GetBySlug(ctx, collection.Slug); err == nil {
+				log.Info().Str("collection", collection.Slug).Msg("Collection exists, skipping")
+				continue
+			} else if !errors.Is(err, store.ErrNotFound) {
+				log.Fatal().Err(err).Str("collection", collection.Slug).Msg("Error fetching collection")
+			}
+
+			collection.FundIDs = []uint64{}
+			for _, label := range labels[collection.Slug] {
+				var ids []uint64
+				err := st.DB(ctx).Model(&crawler.Fund{}).
+					Where("label = ? AND original_id IS NULL", label).
+					Order("id").
+					Pluck("id", &ids).Error
+				if err != nil {
+					log.Fatal().Err(err).Str("label", label).Msg("Error finding funds")
+				}
+				if len(ids) == 0 {
+					log.Warn().Str("collection", collection.Slug).Str("label", label).Msg("No fund with label")
+				}
+				for _, id := range ids {
+					if !slices.Contains(collection.FundIDs, id) {
+						collection.FundIDs = append(collection.FundIDs, id)
+					}
+				}
+			}
+
+			if err := collection.Validate(); err != nil {
+				log.Error().Err(err).Str("collection", collection.Slug).Msg("Invalid collection")
+				continue
+			}
+			if err := st.Collections.Create(ctx, collection); err != nil {
+				log.Error().Err(err).Str("collection", collection.Slug).Msg("Error saving collection")
+				continue
+			}
+			log.Info().Str("collection", collection.Slug).Int("funds", len(collection.FundIDs)).Msg("Imported collection")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCollectionsCmd)
+
+	importCollectionsCmd.Flags().StringVar(&collectionsFile, "file", "./static/filters.csv", "CSV of fund label and category rows")
+}
@@ -5,169 +5,137 @@ package cmd
 
 import (
 	"alpha2/crawler"
-	"encoding/csv"
-	"errors"
+	"alpha2/crawler/pmf"
+	"alpha2/store"
+	"context"
+	"encoding/json"
 	"os"
-	"slices"
 	"strconv"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-	"gorm.io/gorm"
 )
 
-// mergeFundCmd represents the mergeFund command
+var (
+	mergeFundID   uint64
+	mergeTargetID uint64
+	mergePreview  bool
+	mergeBy       string
+	labelsFile    string
+)
+
+// mergeFundCmd merges duplicate funds through the merge service
 var mergeFundCmd = &cobra.Command{
 	Use:   "mergeFund",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Short: "Merge a duplicate fund into another, or import the fund labels of a file",
+	Long: `Merge the fund given by --fund into the one given by --into: the fund is hidden,
+takes the label of the other and its reports fill the months the other has none
+for. Pass --preview to print the months the merge would fill and the months both
+funds have reports for, without merging. Undo a merge with unmergeFund.
+
+Without --fund, import the labels of --file (static/funds.csv by default), rows of
+fund id, name, label and "1" for the canonical fund, as the admin API exports
+them. Within a fund house, funds sharing a label are merged into the canonical
+one. Nothing is changed if any row is invalid; --preview only reports what would
+change.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		file, err := os.Open("./static/funds.csv")
+		ctx := context.Background()
+		st := openStore()
+		if mergeFundID != 0 {
+			mergeOne(ctx, st)
+			return
+		}
+
+		file, err := os.Open(labelsFile)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Error opening file")
 		}
 		defer file.Close()
-
-		// Create a new CSV reader
-		reader := csv.NewReader(file)
-
-		// Read all records
-		records, err := reader.ReadAll()
+		rows, err := crawler.ReadLabelRows(file)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Error reading CSV")
 		}
 
-		slices.SortFunc(records, func(r1, r2 []string) int {
-			if len(r1) >= 4 && r1[3] == "1" {
-				return -1
-			}
-			if len(r2) >= 4 && r2[3] == "1" {
-				return 1
+		initJobs(st)
+		result, err := st.Labels.Import(ctx, rows, mergeBy, mergePreview)
+		if result != nil {
+			for _, row := range result.Skipped {
+				log.Warn().Int("line", row.Line).Msg(row.Error)
 			}
-			return 0
-		})
-
-		db := crawler.Conn()
-		// Print each record
-		for idx, record := range records {
-			if record[1] == "" || record[2] == "" {
-				continue
+			for _, row := range result.Errors {
+				log.Error().Int("line", row.Line).Msg(row.Error)
 			}
-			if len(record) > 4 {
-				log.Error().Err(err).Str("fund", record[1]).Msg("More Cols")
-				return
-			}
-
-			fund := &crawler.Fund{}
-			err := db.Where(&crawler.Fund{Name: record[1]}).
-				Preload("FundManagers").
-				First(fund).Error
-			if err != nil {
-				log.Error().Err(err).Str("fund", record[1]).Msg("Error finding fund")
-				continue
-			}
-
-			if fund.OtherData == nil {
-				fund.OtherData = crawler.JSONB{}
-			}
-			fund.OtherData["label"] = record[2]
-			fund.OtherData["perf"] = strconv.Itoa(idx)
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error importing labels")
+		}
+		log.Info().Bool("preview", result.DryRun).Int("labelled", result.Labelled).Int("merged", result.Merged).
+			Int("unmerged", result.Unmerged).Int("unchanged", result.Unchanged).Int("skipped", len(result.Skipped)).
+			Msg("Done importing labels")
+	},
+}
 
-			originalFund := &crawler.Fund{}
-			err = db.Model(&crawler.Fund{}).
-				Where("other_data->>'label' = ? and is_hidden = false", record[2]).
-				Preload("FundManagers").
-				Find(originalFund).Error
-			if errors.Is(err, gorm.ErrRecordNotFound) || originalFund.ID == 0 {
-				originalFund = nil
-			} else if err != nil {
-				log.Error().Err(err).Str("fund", record[1]).Msg("Error finding original fund")
-				continue
-			} else if len(originalFund.FundManagers) > 0 && len(fund.FundManagers) > 0 && originalFund.FundManagers[0].ID != fund.FundManagers[0].ID {
-				log.Info().Str("fund", record[1]).Msg("Fund managers do not match")
-				originalFund = nil
-			}
+// mergeOne previews or commits the merge of --fund into --into.
+func mergeOne(ctx context.Context, st *store.Store) {
+	if mergePreview {
+		preview, err := st.Merges.Preview(ctx, mergeFundID, mergeTargetID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error previewing merge")
+		}
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		out.Encode(preview)
+		return
+	}
+
+	initJobs(st)
+	event, err := st.Merges.Commit(ctx, mergeFundID, mergeTargetID, mergeBy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error merging fund")
+	}
+	log.Info().Uint64("merge_id", event.ID).Int("reports", event.ReportsCopied).Msg("Merged fund")
+	if err := pmf.ScheduleDataConsistencyForFund(ctx, st, mergeTargetID); err != nil {
+		log.Error().Err(err).Uint64("fund_id", mergeTargetID).Msg("Error scheduling PMSDataConsistency job")
+	}
+}
 
-			if originalFund != nil {
-				fund.OtherData["original_id"] = strconv.FormatUint(originalFund.ID, 10)
-				fund.IsHidden = true
-			}
+// unmergeFundCmd undoes the merge of a fund
+var unmergeFundCmd = &cobra.Command{
+	Use:   "unmergeFund <fund-id>",
+	Short: "Undo the merge of a fund, restoring it as it was before",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fundID, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid fund id")
+		}
 
-			err = db.Save(fund).Error
-			if err != nil {
-				log.Error().Err(err).Str("fund", record[1]).Msg("Error saving fund")
-				continue
+		ctx := context.Background()
+		st := openStore()
+		initJobs(st)
+		event, err := st.Merges.Unmerge(ctx, fundID, mergeBy)
+		if err != nil {
+			log.Fatal().Err(err).Uint64("fund_id", fundID).Msg("Error unmerging fund")
+		}
+		log.Info().Uint64("fund_id", fundID).Uint64("target_id", event.TargetID).Msg("Unmerged fund")
+		for _, id := range []uint64{fundID, event.TargetID} {
+			if err := pmf.ScheduleDataConsistencyForFund(ctx, st, id); err != nil {
+				log.Error().Err(err).Uint64("fund_id", id).Msg("Error scheduling PMSDataConsistency job")
 			}
 		}
-
-		// funds := []crawler.Fund{}
-		// err = db.Where(&crawler.Fund{IsHidden: false, Type: "PMF"}).FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
-
-		// 	fund := funds[0]
-		// 	duplicatefunds := []crawler.Fund{}
-		// 	err := tx.Where("other_data->>'original_id' = ?", strconv.FormatUint(fund.ID, 10)).Find(&duplicatefunds).Error
-		// 	if err != nil {
-		// 		log.Error().Err(err).Str("fund", fund.Name).Msg("Error finding duplicate funds")
-		// 		return err
-		// 	}
-
-		// 	if len(duplicatefunds) == 0 {
-		// 		log.Info().Str("fund", fund.Name).Msg("No duplicate funds found")
-		// 		return nil
-		// 	}
-
-		// 	log.Info().Str("fund", fund.Name).Msg("Duplicate funds found")
-
-		// 	duplicateFundReports := []crawler.FundReport{}
-		// 	duplicateFundIDs := lo.Map(duplicatefunds, func(fund crawler.Fund, index int) uint64 {
-		// 		return fund.ID
-		// 	})
-		// 	tx.Where("fund_id in ?", duplicateFundIDs).Find(&duplicateFundReports)
-		// 	if len(duplicateFundReports) == 0 {
-		// 		log.Info().Str("fund", fund.Name).Msg("No duplicate fund reports found")
-		// 		return nil
-		// 	}
-
-		// 	lo.ForEach(duplicateFundReports, func(report crawler.FundReport, index int) {
-		// 		report.OtherData["merged_id"] = strconv.FormatUint(report.FundID, 10)
-		// 		report.OtherData["priority"] = "low"
-		// 		report.FundID = fund.ID
-		// 		report.ID = 0
-		// 	})
-
-		// 	err = db.Model(&crawler.FundReport{}).Create(duplicateFundReports).Error
-		// 	if err != nil {
-		// 		log.Error().Err(err).Str("fund", fund.Name).Msg("Error updating fund reports")
-		// 		return err
-		// 	}
-
-		// 	return nil
-		// }).Error
-
-		// if err != nil {
-		// 	log.Error().Err(err).Msg("Error finding funds")
-		// 	return
-		// }
-
-		log.Info().Msg("Done merging funds")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(mergeFundCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// mergeFundCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// mergeFundCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.AddCommand(unmergeFundCmd)
+
+	mergeFundCmd.Flags().Uint64Var(&mergeFundID, "fund", 0, "ID of the duplicate fund to merge")
+	mergeFundCmd.Flags().Uint64Var(&mergeTargetID, "into", 0, "ID of the fund to merge it into")
+	mergeFundCmd.Flags().BoolVar(&mergePreview, "preview", false, "Print what the merge or import would do without changing anything")
+	mergeFundCmd.Flags().StringVar(&labelsFile, "file", "./static/funds.csv", "CSV of fund id, name, label and canonical rows to import")
+	mergeFundCmd.MarkFlagsRequiredTogether("fund", "into")
+	for _, c := range []*cobra.Command{mergeFundCmd, unmergeFundCmd} {
+		c.Flags().StringVar(&mergeBy, "by", os.Getenv("USER"), "Name recorded as the one merging")
+	}
 }
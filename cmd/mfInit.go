@@ -23,7 +23,7 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		jobs.Init()
+		initJobs(openStore())
 
 		jd := quartz.NewJobDetailWithOptions(&mf.MFSync{}, quartz.NewJobKeyWithGroup("MFSync", "MFSync"), &quartz.JobDetailOptions{
 			MaxRetries:    10,
@@ -27,12 +27,12 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		jobs.Init()
-		db := crawler.Conn()
+		st := openStore()
+		initJobs(st)
+		db := st.DB(cmd.Context())
 
 		var funds []mf.MutualFundData
 		db.FindInBatches(&funds, 100, func(tx *gorm.DB, batch int) error {
-			db := crawler.Conn()
 			for _, fund := range funds {
 				cFund := &crawler.Fund{
 					Name: fund.Name,
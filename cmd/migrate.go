@@ -0,0 +1,77 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"alpha2/migrations"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var migrateTarget int64
+var migrateSteps int
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage versioned database migrations",
+	Long:  `Apply, revert and inspect the versioned schema and data migrations registered in the migrations package.`,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := migrations.Status(openStore().DB(cmd.Context()))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error reading migration status")
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		tw.Flush()
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := migrations.Up(openStore().DB(cmd.Context()), migrateTarget); err != nil {
+			log.Fatal().Err(err).Msg("Error applying migrations")
+		}
+		log.Info().Msg("Migrations applied")
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the most recent migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := migrations.Down(openStore().DB(cmd.Context()), migrateSteps); err != nil {
+			log.Fatal().Err(err).Msg("Error reverting migrations")
+		}
+		log.Info().Int("steps", migrateSteps).Msg("Migrations reverted")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateStatusCmd, migrateUpCmd, migrateDownCmd)
+
+	migrateUpCmd.Flags().Int64Var(&migrateTarget, "to", 0, "Apply migrations up to and including this version (default all)")
+	migrateDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "Number of migrations to revert")
+}
@@ -23,7 +23,7 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		jobs.Init()
+		initJobs(openStore())
 
 		jd := quartz.NewJobDetailWithOptions(&pmf.PMFInit{}, quartz.NewJobKeyWithGroup("PMFInit 2", "PMFInit"), &quartz.JobDetailOptions{
 			MaxRetries:    10,
@@ -5,7 +5,6 @@ package cmd
 
 import (
 	"alpha2/crawler"
-	"strconv"
 
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
@@ -24,54 +23,39 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		db := crawler.Conn()
+		db := openStore().DB(cmd.Context())
 
 		var funds []crawler.Fund
-		err := db.Where("type = 'PMF' and other_data != 'null'").FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
+		err := db.Where("type = 'PMF' and original_id IS NOT NULL").FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
 			for _, fund := range funds {
 
 				var reports []*crawler.FundReport
 				tx.Where("fund_id = ?", fund.ID).Find(&reports)
 				if len(reports) == 0 {
-					log.Info().Msgf("No reports found for fund %d on date %s", fund.ID, fund.OtherData)
+					log.Info().Msgf("No reports found for fund %d", fund.ID)
 					continue
 				}
 
-				if fund.OtherData == nil {
-					log.Warn().Msgf("No other_data found for fund %d on date %s", fund.ID, fund.OtherData)
+				if fund.OriginalID == nil {
+					log.Warn().Msgf("No original_id found for fund %d", fund.ID)
 					continue
 				}
-
-				idStr := fund.OtherData["original_id"]
-				if idStr == "" {
-					log.Warn().Msgf("No original_id found for fund %d on date %s", fund.ID, fund.OtherData)
-					continue
-				}
-				originalID, err := strconv.ParseUint(idStr, 10, 64)
-				if err != nil {
-					log.Error().Err(err).Msgf("Failed to parse original_id for fund %d on date %s", fund.ID, fund.OtherData)
-					return err
-				}
+				originalID := *fund.OriginalID
 
 				if originalID == fund.ID {
-					log.Warn().Msgf("Original ID is the same as fund ID for fund %d on date %s", fund.ID, fund.OtherData)
+					log.Warn().Msgf("Original ID is the same as fund ID for fund %d", fund.ID)
 					continue
 				}
 
 				lo.ForEach(reports, func(report *crawler.FundReport, idx int) {
 					report.ID = 0
 					report.FundID = originalID
-
-					if report.OtherData == nil {
-						report.OtherData = crawler.JSONB{}
-					}
-
-					report.OtherData["priority"] = "low"
+					report.MarkMerged(fund.ID)
 				})
 
-				err = tx.Create(&reports).Error
+				err := tx.Create(&reports).Error
 				if err != nil {
-					log.Error().Err(err).Msgf("Failed to create reports for fund %d on date %s", fund.ID, fund.OtherData)
+					log.Error().Err(err).Msgf("Failed to create reports for fund %d", fund.ID)
 					return err
 				}
 
@@ -6,7 +6,13 @@ package cmd
 import (
 	"alpha2/api"
 	"alpha2/crawler"
+	"alpha2/crawler/aif"
+	"alpha2/crawler/mf"
 	"alpha2/crawler/pmf"
+	"alpha2/crawler/sources"
+	"alpha2/jobs"
+	"alpha2/store"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -47,7 +53,10 @@ var rootCmd = &cobra.Command{
 		// 	forDate, _ := time.Parse("2006-01-02", targetDate)
 		// 	runClawl(&forDate)
 		// }
-		api.RunServer()
+		st := openStore()
+		initJobs(st)
+		scheduleRecurring()
+		api.RunServer(st)
 	},
 }
 
@@ -157,10 +166,56 @@ func initConfig() {
 	fmt.Println("Using config file:", viper.ConfigFileUsed())
 }
 
-func runClawl(forDate *time.Time) {
-	craw := pmf.NewPMFCrawler()
-	db := crawler.Conn()
-	craw.CrawlAllFund(forDate, func(funds []*crawler.Fund) {
+// openStore connects to the configured database, exiting on failure.
+func openStore() *store.Store {
+	db, err := crawler.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error connecting to database")
+	}
+	return store.New(db)
+}
+
+// initJobs creates the job scheduler and registers the crawler jobs against st.
+func initJobs(st *store.Store) {
+	jobs.Init(st.DB(context.Background()))
+	pmf.RegisterJobs(st)
+	mf.RegisterJobs(st)
+	sources.RegisterJobs(st)
+	aif.Register(st)
+}
+
+// defaultSchedules are the recurring jobs queued when jobs.schedules is not set.
+var defaultSchedules = []jobs.Schedule{
+	{Name: "monthly-pms-crawl", Group: "PMFMonthlyCrawl", Cron: "0 0 2 21 * *"},
+	{Name: "daily-mf-nav-sync", Group: "MFSync", Cron: "0 0 3 * * *"},
+	{Name: "nightly-pms-consistency", Group: "PMSNightlyConsistency", Cron: "0 30 1 * * *"},
+	{Name: "daily-pms-crawl-reconciler", Group: "PMFCrawlReconciler", Cron: "0 0 6 * * *"},
+}
+
+// scheduleRecurring queues the recurring jobs listed under jobs.schedules, each with
+// a name, group, cron and optional JSON payload. Removing a schedule from the config
+// does not remove it from the queue; cancel it through the admin API. Nothing is
+// queued unless jobs.enable is set, as the scheduler does not run then.
+func scheduleRecurring() {
+	if !viper.GetBool("jobs.enable") {
+		return
+	}
+	schedules := defaultSchedules
+	if viper.IsSet("jobs.schedules") {
+		schedules = nil
+		if err := viper.UnmarshalKey("jobs.schedules", &schedules); err != nil {
+			log.Fatal().Err(err).Msg("Error reading jobs.schedules")
+		}
+	}
+	if err := jobs.ScheduleRecurring(schedules); err != nil {
+		log.Fatal().Err(err).Msg("Error scheduling recurring jobs")
+	}
+}
+
+func runClawl(st *store.Store, forDate *time.Time) {
+	craw := pmf.NewPMFCrawler(context.Background(), st)
+	db := st.DB(context.Background())
+	crawlErr := craw.CrawlAllFund(forDate, func(funds []*crawler.Fund) {
 		for _, fund := range funds {
 			err := db.Model(&crawler.Fund{}).Clauses(clause.OnConflict{
 				DoNothing: true,
@@ -171,4 +226,7 @@ func runClawl(forDate *time.Time) {
 			}
 		}
 	})
+	if crawlErr != nil {
+		log.Error().Err(crawlErr).Msg("Error while crawling funds")
+	}
 }
@@ -14,7 +14,10 @@ var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: " API server",
 	Run: func(cmd *cobra.Command, args []string) {
-		api.RunServer()
+		st := openStore()
+		initJobs(st)
+		scheduleRecurring()
+		api.RunServer(st)
 	},
 }
 
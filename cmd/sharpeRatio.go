@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"alpha2/crawler"
+	"alpha2/store"
 	"math"
 	"time"
 
@@ -19,14 +20,14 @@ var sharpeRatioCmd = &cobra.Command{
 	Short: " A Sharpe ratio is a measure of risk-adjusted return of an investment asset or a trading strategy.",
 	Long:  ` A Sharpe ratio is a measure of risk-adjusted return of an investment asset or a trading strategy.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		db := crawler.Conn()
+		db := openStore().DB(cmd.Context())
 		var funds []crawler.Fund
 		db.FindInBatches(&funds, 1, func(tx *gorm.DB, batch int) error {
 			fund := funds[0]
 
 			var reports []crawler.FundReport
 			threeYearsAgo := time.Now().AddDate(-noOfYears, 0, 0)
-			err := db.Where("fund_id = ? AND month1_returns IS NOT NULL AND report_date >= ?", fund.ID, threeYearsAgo).
+			err := db.Scopes(store.EffectiveReports).Where("fund_id = ? AND month1_returns IS NOT NULL AND report_date >= ?", fund.ID, threeYearsAgo).
 				Order("report_date desc").
 				Limit(noOfYears * 12).
 				Find(&reports).Error
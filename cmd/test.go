@@ -27,16 +27,17 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		jobs.Init()
-		ctx, cancel := context.WithCancel(context.Background())
+		st := openStore()
+		initJobs(st)
+		ctx, cancel := context.WithCancel(cmd.Context())
 		defer cancel()
-		jobs.Scheduler.Start(ctx)
+		jobs.Start(ctx)
 
-		db := crawler.Conn()
+		db := st.DB(ctx)
 
 		managers := []crawler.FundManager{}
 		err := db.FindInBatches(&managers, 1, func(tx *gorm.DB, batch int) error {
-			UID := managers[0].OtherData["UID"]
+			UID := managers[0].UID
 			if UID == "" {
 				return nil
 			}
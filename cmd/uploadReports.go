@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"alpha2/crawler"
+	"alpha2/crawler/pmf"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uploadFundID   uint64
+	uploadReviewer string
+	uploadApprove  bool
+	uploadReject   bool
+	uploadNote     string
+)
+
+// uploadReportsCmd stages manual monthly reports of a fund for approval
+var uploadReportsCmd = &cobra.Command{
+	Use:   "uploadReports <file>",
+	Short: "Upload monthly returns and AUM of a fund for approval",
+	Long: `Read the monthly reports of a fund from a CSV or XLSX file and stage them as a
+pending upload. The header row needs a Month column (such as 2024-06) and any of
+AUM, 1 Month, 3 Months, 6 Months, 1 Year ... 5 Years and Since Inception.
+
+The reports are shown only once the upload is approved with reviewReports or the
+admin API. They never replace crawled reports of the same month.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		st := openStore()
+		if _, err := st.Funds.Get(ctx, uploadFundID); err != nil {
+			log.Fatal().Err(err).Uint64("fund_id", uploadFundID).Msg("Error fetching fund")
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error opening upload")
+		}
+		defer file.Close()
+		reports, err := crawler.ReadReportUpload(args[0], file, time.Now())
+		if err != nil {
+			log.Fatal().Err(err).Str("file", args[0]).Msg("Invalid upload")
+		}
+
+		upload := &crawler.ReportUpload{
+			FundID:     uploadFundID,
+			FileName:   filepath.Base(args[0]),
+			UploadedBy: uploadReviewer,
+			Reports:    reports,
+		}
+		if err := st.Uploads.Create(ctx, upload); err != nil {
+			log.Fatal().Err(err).Msg("Error saving upload")
+		}
+		log.Info().Uint64("upload_id", upload.ID).Int("reports", len(reports)).Msg("Upload pending approval")
+	},
+}
+
+// reviewReportsCmd approves or rejects a pending upload of manual reports
+var reviewReportsCmd = &cobra.Command{
+	Use:   "reviewReports <upload-id>",
+	Short: "Approve or reject a pending upload of monthly reports",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid upload id")
+		}
+		if uploadApprove == uploadReject {
+			log.Fatal().Msg("Pass one of --approve and --reject")
+		}
+
+		ctx := context.Background()
+		st := openStore()
+		if uploadReject {
+			if _, err := st.Uploads.Reject(ctx, id, uploadReviewer, uploadNote); err != nil {
+				log.Fatal().Err(err).Uint64("upload_id", id).Msg("Error rejecting upload")
+			}
+			log.Info().Uint64("upload_id", id).Msg("Rejected upload")
+			return
+		}
+
+		initJobs(st)
+		upload, err := st.Uploads.Approve(ctx, id, uploadReviewer)
+		if err != nil {
+			log.Fatal().Err(err).Uint64("upload_id", id).Msg("Error approving upload")
+		}
+		skipped := 0
+		for _, report := range upload.Reports {
+			if report.Skipped {
+				skipped++
+			}
+		}
+		log.Info().Uint64("upload_id", id).Int("saved", len(upload.Reports)-skipped).Int("skipped", skipped).Msg("Approved upload")
+		if err := pmf.ScheduleDataConsistencyForFund(ctx, st, upload.FundID); err != nil {
+			log.Error().Err(err).Uint64("fund_id", upload.FundID).Msg("Error scheduling PMSDataConsistency job")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uploadReportsCmd)
+	rootCmd.AddCommand(reviewReportsCmd)
+
+	uploadReportsCmd.Flags().Uint64Var(&uploadFundID, "fund", 0, "ID of the fund the reports are of")
+	uploadReportsCmd.MarkFlagRequired("fund")
+	for _, c := range []*cobra.Command{uploadReportsCmd, reviewReportsCmd} {
+		c.Flags().StringVar(&uploadReviewer, "by", os.Getenv("USER"), "Name recorded as the uploader or reviewer")
+	}
+	reviewReportsCmd.Flags().BoolVar(&uploadApprove, "approve", false, "Approve the upload")
+	reviewReportsCmd.Flags().BoolVar(&uploadReject, "reject", false, "Reject the upload")
+	reviewReportsCmd.Flags().StringVar(&uploadNote, "note", "", "Reason for rejecting the upload")
+}
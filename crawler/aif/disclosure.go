@@ -0,0 +1,149 @@
+package aif
+
+import (
+	"alpha2/crawler"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A disclosure is a CSV file of the performance of Category III AIFs for one month,
+// with a header row naming its columns. The columns are matched without regard to
+// case, and "years" and "months" may be used for "year" and "month".
+const (
+	colRegistration = "registration number" // SEBI registration number of the AIF
+	colManager      = "manager"             // Name of the investment manager
+	colScheme       = "scheme"              // Name of the scheme
+	colStrategy     = "strategy"
+	colAUM          = "aum" // In INR crores
+)
+
+// returnColumns maps the columns of returns, in percent, to the report fields they fill.
+var returnColumns = map[string]func(*crawler.FundReport) **float64{
+	"1 month":         func(r *crawler.FundReport) **float64 { return &r.Month1Returns },
+	"3 month":         func(r *crawler.FundReport) **float64 { return &r.Month3Returns },
+	"6 month":         func(r *crawler.FundReport) **float64 { return &r.Month6Returns },
+	"1 year":          func(r *crawler.FundReport) **float64 { return &r.Yr1Returns },
+	"2 year":          func(r *crawler.FundReport) **float64 { return &r.Yr2Returns },
+	"3 year":          func(r *crawler.FundReport) **float64 { return &r.Yr3Returns },
+	"4 year":          func(r *crawler.FundReport) **float64 { return &r.Yr4Returns },
+	"5 year":          func(r *crawler.FundReport) **float64 { return &r.Yr5Returns },
+	"since inception": func(r *crawler.FundReport) **float64 { return &r.OverAllReturns },
+}
+
+// disclosureRow is one scheme of a disclosure, by normalised column name.
+type disclosureRow map[string]string
+
+func (r disclosureRow) registration() string {
+	return r[colRegistration]
+}
+
+// report returns the fund report of the row. Empty and "NA" cells are left nil.
+func (r disclosureRow) report() (*crawler.FundReport, error) {
+	report := &crawler.FundReport{
+		Strategy:  r[colStrategy],
+		Source:    sourceName,
+		OtherData: make(map[string]string),
+	}
+	if report.Strategy == "" {
+		report.Strategy = "Category III"
+	}
+	aum, err := r.number(colAUM)
+	if err != nil {
+		return nil, err
+	}
+	if aum != nil {
+		rounded := math.Round(*aum*100) / 100
+		report.AUM = &rounded
+	}
+	for col, field := range returnColumns {
+		if *field(report), err = r.number(col); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func (r disclosureRow) number(col string) (*float64, error) {
+	cell := strings.TrimSuffix(strings.ReplaceAll(r[col], ",", ""), "%")
+	if cell == "" || strings.EqualFold(cell, "NA") || cell == "-" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s of %s: %w", col, r[colScheme], err)
+	}
+	return &value, nil
+}
+
+// readDisclosure reads the normalised column names and the rows of a disclosure.
+// Rows without a registration number or scheme, such as totals, are skipped.
+func readDisclosure(in io.Reader) ([]string, []disclosureRow, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading disclosure header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for idx, name := range header {
+		columns[idx] = columnKey(name)
+	}
+	if !slices.Contains(columns, colRegistration) || !slices.Contains(columns, colScheme) {
+		return nil, nil, errors.New("disclosure needs the columns " + colRegistration + " and " + colScheme)
+	}
+
+	var rows []disclosureRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return columns, rows, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row := make(disclosureRow, len(columns))
+		for idx, cell := range record {
+			if idx < len(columns) {
+				row[columns[idx]] = strings.TrimSpace(cell)
+			}
+		}
+		if row.registration() == "" || row[colScheme] == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+}
+
+// writeDisclosure writes rows back as a disclosure with the given columns.
+func writeDisclosure(out io.Writer, columns []string, rows []disclosureRow) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for idx, col := range columns {
+			record[idx] = row[col]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func columnKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "years", "year")
+	name = strings.ReplaceAll(name, "months", "month")
+	return name
+}
@@ -0,0 +1,265 @@
+package aif
+
+import (
+	"alpha2/crawler"
+	"alpha2/store"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// sourceName is the Name of the source and the Source of its reports.
+	sourceName = "aif"
+	// periodLayout names the disclosure file of a month, with the extension ".csv".
+	periodLayout = "2006-01"
+)
+
+// Source is the crawler.Source of the performance disclosures of Category III AIFs,
+// saved as one disclosure file per month in a directory. Its entities are the SEBI
+// registration numbers of the AIFs.
+type Source struct {
+	dir   string
+	store *store.Store
+}
+
+// NewSource returns the AIF source reading the disclosures in dir. Parse looks up
+// the fund houses through st.
+func NewSource(dir string, st *store.Store) *Source {
+	return &Source{dir: dir, store: st}
+}
+
+// Dir returns the directory of the disclosures, crawler.aif.dir in the config.
+func Dir() string {
+	if dir := viper.GetString("crawler.aif.dir"); dir != "" {
+		return dir
+	}
+	return filepath.Join("data", "aif")
+}
+
+// Register registers the AIF source, reading from Dir(), with the source registry.
+func Register(st *store.Store) {
+	crawler.RegisterSource(NewSource(Dir(), st))
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+// Discover returns the AIFs listed in any of the disclosures.
+func (s *Source) Discover(ctx context.Context) ([]string, error) {
+	periods, err := s.periods()
+	if err != nil {
+		return nil, err
+	}
+	var registrations []string
+	for _, period := range periods {
+		_, rows, err := s.read(period)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			registrations = append(registrations, row.registration())
+		}
+	}
+	if len(registrations) == 0 {
+		return nil, errors.New("no AIF disclosures in " + s.dir)
+	}
+	slices.Sort(registrations)
+	return slices.Compact(registrations), nil
+}
+
+// LatestPeriod returns the latest month with a disclosure, or the zero time if there
+// is none.
+func (s *Source) LatestPeriod(now time.Time) time.Time {
+	periods, err := s.periods()
+	if err != nil {
+		log.Error().Err(err).Str("dir", s.dir).Msg("Error while listing AIF disclosures")
+	}
+	var latest time.Time
+	for _, period := range periods {
+		if !period.After(now) {
+			latest = period
+		}
+	}
+	return latest
+}
+
+// NextPeriod returns the next month with a disclosure, skipping months missing from
+// the directory.
+func (s *Source) NextPeriod(period time.Time) time.Time {
+	periods, _ := s.periods()
+	for _, next := range periods {
+		if next.After(period) {
+			return next
+		}
+	}
+	return period.AddDate(0, 1, 0)
+}
+
+// Fetch returns the rows of the disclosure of the month on the AIF, as a disclosure.
+func (s *Source) Fetch(ctx context.Context, entity string, period time.Time) ([]byte, error) {
+	columns, rows, err := s.read(period)
+	if err != nil {
+		return nil, err
+	}
+	rows = slices.DeleteFunc(rows, func(row disclosureRow) bool {
+		return row.registration() != entity
+	})
+	var raw bytes.Buffer
+	if err := writeDisclosure(&raw, columns, rows); err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
+}
+
+func (s *Source) Parse(ctx context.Context, entity string, period time.Time, raw []byte) ([]*crawler.Fund, error) {
+	_, rows, err := readDisclosure(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	fundHouse := s.fundHouse(ctx, entity)
+	funds := make([]*crawler.Fund, 0, len(rows))
+	for _, row := range rows {
+		if row.registration() != entity {
+			continue
+		}
+		if manager := row[colManager]; manager != "" {
+			fundHouse.RegistrationName = manager
+		}
+		report, err := row.report()
+		if err != nil {
+			return nil, err
+		}
+		reportDate := period
+		report.ReportDate = &reportDate
+
+		fund := &crawler.Fund{
+			Name:         row[colScheme],
+			Type:         "AIF",
+			FundManagers: []*crawler.FundManager{fundHouse},
+			FundReports:  []*crawler.FundReport{report},
+		}
+		for _, known := range fundHouse.Funds {
+			if known.Type == fund.Type && known.Name == fund.Name {
+				fund.ID = known.ID
+			}
+		}
+		funds = append(funds, fund)
+	}
+	return funds, nil
+}
+
+// Import saves the disclosure read from in as the one of the month of period,
+// replacing any saved before, and returns the AIFs it lists.
+func (s *Source) Import(period time.Time, in io.Reader) ([]string, error) {
+	raw, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := readDisclosure(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if _, err := row.report(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Write through a temporary file, so a crawl never reads half a disclosure.
+	tmp, err := os.CreateTemp(s.dir, ".import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), s.path(period)); err != nil {
+		return nil, err
+	}
+
+	registrations := make([]string, 0, len(rows))
+	for _, row := range rows {
+		registrations = append(registrations, row.registration())
+	}
+	slices.Sort(registrations)
+	return slices.Compact(registrations), nil
+}
+
+// fundHouse returns the stored fund house of the AIF with its funds, or a new one.
+func (s *Source) fundHouse(ctx context.Context, registration string) *crawler.FundManager {
+	fundHouse, err := s.store.FundHouses.GetByUID(ctx, registration)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Error().Err(err).Str("registration", registration).Msg("Error while fetching FundManager")
+		}
+		fundHouse = &crawler.FundManager{UID: registration}
+	}
+	fundHouse.RegisterNumber = registration
+	if fundHouse.OtherData == nil {
+		fundHouse.OtherData = make(map[string]string)
+	}
+	return fundHouse
+}
+
+func (s *Source) path(period time.Time) string {
+	return filepath.Join(s.dir, period.Format(periodLayout)+".csv")
+}
+
+func (s *Source) read(period time.Time) ([]string, []disclosureRow, error) {
+	file, err := os.Open(s.path(period))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	columns, rows, err := readDisclosure(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file.Name(), err)
+	}
+	return columns, rows, nil
+}
+
+// periods returns the months with a disclosure in order.
+func (s *Source) periods() ([]time.Time, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var periods []time.Time
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".csv")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		period, err := time.Parse(periodLayout, name)
+		if err != nil {
+			continue
+		}
+		periods = append(periods, period)
+	}
+	slices.SortFunc(periods, time.Time.Compare)
+	return periods, nil
+}
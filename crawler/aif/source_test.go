@@ -0,0 +1,98 @@
+package aif
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"alpha2/store"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const disclosure = `Registration Number,Manager,Scheme,Strategy,AUM,1 Month,3 Months,1 Year,Since Inception
+IN/AIF3/13-14/0044,Alpha Capital,Alpha Long Short Fund,Long Short,"1,250.456",1.5,NA,12.25%,18
+IN/AIF3/13-14/0044,Alpha Capital,Alpha Absolute Return,,300,-0.5,2,,
+IN/AIF3/19-20/0712,Beta Partners,Beta Opportunities,,80,3,,,
+Total,,,,1630.456,,,,
+`
+
+func TestSourceImportsAndParses(t *testing.T) {
+	ctx := context.Background()
+	st := store.New(migratetest.Open(t))
+	source := NewSource(t.TempDir(), st)
+
+	june := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	august := time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC)
+	for _, period := range []time.Time{june, august} {
+		if _, err := source.Import(period, strings.NewReader(disclosure)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := source.Import(june, strings.NewReader("Scheme,AUM\nX,1\n")); err == nil {
+		t.Error("Import() accepted a disclosure without registration numbers")
+	}
+
+	registrations, err := source.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registrations) != 2 || registrations[0] != "IN/AIF3/13-14/0044" {
+		t.Errorf("Discover() = %v, want the two AIFs", registrations)
+	}
+	if got := source.LatestPeriod(time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)); !got.Equal(june) {
+		t.Errorf("LatestPeriod() in July = %v, want June", got)
+	}
+	if got := source.NextPeriod(june); !got.Equal(august) {
+		t.Errorf("NextPeriod(June) = %v, want August, skipping the missing July", got)
+	}
+
+	entity := "IN/AIF3/13-14/0044"
+	crawl := func(period time.Time) []*crawler.Fund {
+		t.Helper()
+		raw, err := source.Fetch(ctx, entity, period)
+		if err != nil {
+			t.Fatal(err)
+		}
+		funds, err := source.Parse(ctx, entity, period, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fund := range funds {
+			if err := st.Funds.SaveCrawled(ctx, fund, period); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return funds
+	}
+
+	funds := crawl(june)
+	if len(funds) != 2 {
+		t.Fatalf("parsed %d funds, want the 2 of %s", len(funds), entity)
+	}
+	fund, report := funds[0], funds[0].FundReports[0]
+	if fund.Type != "AIF" || fund.Name != "Alpha Long Short Fund" || fund.FundManagers[0].RegistrationName != "Alpha Capital" {
+		t.Errorf("fund = %s %q of %q", fund.Type, fund.Name, fund.FundManagers[0].RegistrationName)
+	}
+	if *report.AUM != 1250.46 || *report.Month1Returns != 1.5 || report.Month3Returns != nil || *report.Yr1Returns != 12.25 ||
+		*report.OverAllReturns != 18 || report.Strategy != "Long Short" {
+		t.Errorf("report = AUM %v, returns %v %v %v %v, strategy %q", *report.AUM, *report.Month1Returns,
+			report.Month3Returns, *report.Yr1Returns, *report.OverAllReturns, report.Strategy)
+	}
+	if strategy := funds[1].FundReports[0].Strategy; strategy != "Category III" {
+		t.Errorf("default strategy = %q", strategy)
+	}
+
+	again := crawl(august)
+	if again[0].ID != fund.ID || again[0].FundManagers[0].ID != fund.FundManagers[0].ID {
+		t.Errorf("August crawl saved fund %d of %d, want fund %d of %d", again[0].ID, again[0].FundManagers[0].ID,
+			fund.ID, fund.FundManagers[0].ID)
+	}
+	reports, err := st.Reports.ForFund(ctx, fund.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Errorf("fund has %d reports, want June and August", len(reports))
+	}
+}
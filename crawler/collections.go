@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Collection is a curated list of funds, such as "Top Picks", shown as a filter of
+// the explore page. Its funds are kept in the order curators give.
+type Collection struct {
+	ID          uint64 `json:"id"`
+	Name        string `gorm:"not null" json:"name"`
+	Slug        string `gorm:"not null;uniqueIndex" json:"slug"`
+	Description string `gorm:"not null;default:''" json:"description"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// FundIDs are the funds of the collection in order, read from and saved to
+	// CollectionFunds.
+	FundIDs []uint64 `gorm:"-" json:"fund_ids"`
+}
+
+// CollectionFund places a fund in a Collection.
+type CollectionFund struct {
+	CollectionID uint64 `gorm:"primaryKey"`
+	FundID       uint64 `gorm:"primaryKey;index"`
+	Position     int    `gorm:"not null;default:0"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Slugify returns the lower-case, dash separated slug of name, so "Top Picks " and
+// "top-picks" give the same slug.
+func Slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+	})
+	return strings.Join(words, "-")
+}
+
+// Normalize trims the name and description and derives the slug from the name when
+// none is given.
+func (c *Collection) Normalize() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	c.Slug = strings.TrimSpace(c.Slug)
+	if c.Slug == "" {
+		c.Slug = Slugify(c.Name)
+	}
+}
+
+// Validate checks a normalized collection has a name, a well formed slug and lists
+// no fund twice.
+func (c *Collection) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if !slugPattern.MatchString(c.Slug) {
+		return fmt.Errorf("invalid slug %q: use lower-case letters, digits and dashes", c.Slug)
+	}
+	seen := make(map[uint64]bool, len(c.FundIDs))
+	for _, id := range c.FundIDs {
+		if seen[id] {
+			return fmt.Errorf("fund %d is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
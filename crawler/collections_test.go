@@ -0,0 +1,31 @@
+package crawler
+
+import "testing"
+
+func TestCollectionNormalize(t *testing.T) {
+	for name, slug := range map[string]string{
+		"Top Picks ":         "top-picks",
+		"Sector  Leaders":    "sector-leaders",
+		"Small & Mid-Cap 50": "small-mid-cap-50",
+	} {
+		collection := &Collection{Name: name}
+		collection.Normalize()
+		if collection.Slug != slug {
+			t.Errorf("slug of %q = %q, want %q", name, collection.Slug, slug)
+		}
+		if err := collection.Validate(); err != nil {
+			t.Errorf("Validate(%q) = %v", name, err)
+		}
+	}
+
+	for _, collection := range []*Collection{
+		{Name: " "},
+		{Name: "Top Picks", Slug: "Top Picks"},
+		{Name: "Top Picks", FundIDs: []uint64{1, 2, 1}},
+	} {
+		collection.Normalize()
+		if err := collection.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", collection)
+		}
+	}
+}
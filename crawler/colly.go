@@ -8,8 +8,11 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// NewCollector returns a collector whose requests are held to the politeness policy
+// shared by the process.
 func NewCollector() *colly.Collector {
 	c := colly.NewCollector(colly.Debugger(&LogDebugger{}))
+	c.WithTransport(Politeness())
 	extensions.RandomUserAgent(c)
 	extensions.Referer(c)
 	return c
@@ -2,7 +2,6 @@ package crawler
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -10,25 +9,24 @@ import (
 	"gorm.io/gorm"
 )
 
-type FundCrawler interface {
-	CrawlFund(forDate *time.Time, cb SaveFund) []*Fund
-}
-
-var db *gorm.DB
-
-func Conn() *gorm.DB {
-	if db != nil {
-		return db
+// Open connects to the database configured under db.* in viper. db.driver selects
+// "postgres" (the default) or "sqlite", which stores the database at db.path.
+func Open() (*gorm.DB, error) {
+	switch driver := viper.GetString("db.driver"); driver {
+	case "", "postgres":
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s", viper.GetString("db.host"), viper.GetString("db.user"), viper.GetString("db.password"), viper.GetString("db.dbname"), viper.GetString("db.port"))
+		gormLogger := &ZeroLogger{log: log.Logger}
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger})
+	case "sqlite":
+		path := viper.GetString("db.path")
+		if path == "" {
+			path = "alpha2.db"
+		}
+		// The scheduler writes from several workers; wait on locks instead of failing.
+		return OpenSQLite(fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL", path))
+	default:
+		return nil, fmt.Errorf("unsupported db.driver %q", driver)
 	}
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s", viper.GetString("db.host"), viper.GetString("db.user"), viper.GetString("db.password"), viper.GetString("db.dbname"), viper.GetString("db.port"))
-	var err error
-	gormLogger := &ZeroLogger{log: log.Logger}
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger})
-	if err != nil {
-		panic(err)
-	}
-
-	return db
 }
 
 type SaveFund func([]*Fund)
@@ -0,0 +1,227 @@
+package crawler
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Statuses of a DuplicateSuggestion.
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted"
+	SuggestionRejected = "rejected"
+)
+
+// DefaultDuplicateScore is the Score from which a pair of funds is suggested as
+// duplicates unless another minimum is given.
+const DefaultDuplicateScore = 0.6
+
+// duplicateWeights weigh the parts of a DuplicateScore into its Score. They add up
+// to 1.
+var duplicateWeights = struct{ name, returns, aum, strategy float64 }{
+	name: 0.4, returns: 0.3, aum: 0.15, strategy: 0.15,
+}
+
+const (
+	// returnsTolerance is how far apart, in percentage points, the 1 month returns of
+	// a month may be and still count as the same.
+	returnsTolerance = 0.1
+	// maxContinuityGap is the most months between the last report of one fund and
+	// the first of the other for their AUMs to be compared as one series.
+	maxContinuityGap = 3
+)
+
+// DuplicateScore rates how likely two funds of a fund house are the same strategy
+// filed twice, such as under a renamed or re-registered scheme. Every part is
+// between 0 and 1.
+type DuplicateScore struct {
+	Score float64 `gorm:"not null;default:0;index" json:"score"`
+	// NameScore is the trigram similarity of the names, 1 if they only differ in
+	// case, spacing or punctuation.
+	NameScore float64 `gorm:"not null;default:0" json:"name_score"`
+	// ReturnsScore is the share of OverlappingMonths with the same 1 month return.
+	ReturnsScore      float64 `gorm:"not null;default:0" json:"returns_score"`
+	OverlappingMonths int     `gorm:"not null;default:0" json:"overlapping_months"`
+	// AUMScore compares the AUMs of the months both funds report, or the last AUM of
+	// one with the first of the other when one series continues the other.
+	AUMScore float64 `gorm:"not null;default:0" json:"aum_score"`
+	// StrategyScore is 1 when the latest strategies match, 0 when they differ and
+	// 0.5 when either is unknown.
+	StrategyScore float64 `gorm:"not null;default:0" json:"strategy_score"`
+}
+
+// DuplicateSuggestion is a pair of funds suggested as duplicates, queued for a
+// curator to accept, which merges Duplicate into Fund, or reject. Pairs are kept
+// once decided so they are not suggested again.
+type DuplicateSuggestion struct {
+	ID uint64 `json:"id"`
+	// FundID is the fund kept, the older of the two.
+	FundID      uint64 `gorm:"uniqueIndex:idx_duplicate_pair" json:"fund_id"`
+	DuplicateID uint64 `gorm:"uniqueIndex:idx_duplicate_pair" json:"duplicate_id"`
+	FundHouseID uint64 `gorm:"index" json:"fund_house_id"`
+
+	DuplicateScore `gorm:"embedded"`
+
+	Status     string     `gorm:"not null;default:'pending';index" json:"status"`
+	ReviewedBy string     `gorm:"not null;default:''" json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	// MergeEventID is the merge made when the suggestion was accepted.
+	MergeEventID *uint64 `json:"merge_event_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Fund      *Fund `gorm:"-" json:"fund,omitempty"`
+	Duplicate *Fund `gorm:"-" json:"duplicate,omitempty"`
+}
+
+// ScoreDuplicate scores fund and duplicate as duplicates from their names and the
+// effective reports of each.
+func ScoreDuplicate(fund, duplicate *Fund, reports, duplicateReports []*FundReport) DuplicateScore {
+	score := DuplicateScore{NameScore: nameScore(fund.Name, duplicate.Name)}
+	score.ReturnsScore, score.OverlappingMonths = returnsScore(reports, duplicateReports)
+	score.AUMScore = aumScore(reports, duplicateReports)
+	score.StrategyScore = strategyScore(reports, duplicateReports)
+	score.Score = duplicateWeights.name*score.NameScore +
+		duplicateWeights.returns*score.ReturnsScore +
+		duplicateWeights.aum*score.AUMScore +
+		duplicateWeights.strategy*score.StrategyScore
+	return score
+}
+
+func nameScore(a, b string) float64 {
+	if squash(a) != "" && squash(a) == squash(b) {
+		return 1
+	}
+	return Similarity(a, b)
+}
+
+// squash lower-cases s and drops everything but letters and digits.
+func squash(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func returnsScore(reports, others []*FundReport) (float64, int) {
+	byMonth := reportsByMonth(others)
+	overlapping, same := 0, 0
+	for _, report := range reports {
+		other, ok := byMonth[monthOf(report)]
+		if !ok || report.Month1Returns == nil || other.Month1Returns == nil {
+			continue
+		}
+		overlapping++
+		if math.Abs(*report.Month1Returns-*other.Month1Returns) <= returnsTolerance {
+			same++
+		}
+	}
+	if overlapping == 0 {
+		return 0, 0
+	}
+	return float64(same) / float64(overlapping), overlapping
+}
+
+func aumScore(reports, others []*FundReport) float64 {
+	byMonth := reportsByMonth(others)
+	var ratios []float64
+	for _, report := range reports {
+		if other, ok := byMonth[monthOf(report)]; ok {
+			if ratio, ok := aumRatio(report, other); ok {
+				ratios = append(ratios, ratio)
+			}
+		}
+	}
+	if len(ratios) > 0 {
+		total := 0.0
+		for _, ratio := range ratios {
+			total += ratio
+		}
+		return total / float64(len(ratios))
+	}
+
+	// No month in common: compare the end of the earlier series with the start of
+	// the later one.
+	first, second := datedReports(reports), datedReports(others)
+	if len(first) == 0 || len(second) == 0 {
+		return 0
+	}
+	if first[0].ReportDate.After(*second[0].ReportDate) {
+		first, second = second, first
+	}
+	last, next := first[len(first)-1], second[0]
+	if monthsBetween(*last.ReportDate, *next.ReportDate) > maxContinuityGap {
+		return 0
+	}
+	ratio, _ := aumRatio(last, next)
+	return ratio
+}
+
+func strategyScore(reports, others []*FundReport) float64 {
+	a, b := latestStrategy(reports), latestStrategy(others)
+	switch {
+	case a == "" || b == "":
+		return 0.5
+	case strings.EqualFold(a, b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// aumRatio returns the smaller AUM of the two reports divided by the larger.
+func aumRatio(a, b *FundReport) (float64, bool) {
+	if a.AUM == nil || b.AUM == nil || *a.AUM <= 0 || *b.AUM <= 0 {
+		return 0, false
+	}
+	return math.Min(*a.AUM, *b.AUM) / math.Max(*a.AUM, *b.AUM), true
+}
+
+func latestStrategy(reports []*FundReport) string {
+	dated := datedReports(reports)
+	for idx := len(dated) - 1; idx >= 0; idx-- {
+		if dated[idx].Strategy != "" {
+			return dated[idx].Strategy
+		}
+	}
+	return ""
+}
+
+// datedReports returns the reports with a date, in date order.
+func datedReports(reports []*FundReport) []*FundReport {
+	dated := slices.DeleteFunc(slices.Clone(reports), func(report *FundReport) bool {
+		return report.ReportDate == nil
+	})
+	slices.SortFunc(dated, func(a, b *FundReport) int {
+		return a.ReportDate.Compare(*b.ReportDate)
+	})
+	return dated
+}
+
+func reportsByMonth(reports []*FundReport) map[time.Time]*FundReport {
+	byMonth := make(map[time.Time]*FundReport, len(reports))
+	for _, report := range reports {
+		if report.ReportDate != nil {
+			byMonth[monthOf(report)] = report
+		}
+	}
+	return byMonth
+}
+
+func monthOf(report *FundReport) time.Time {
+	if report.ReportDate == nil {
+		return time.Time{}
+	}
+	date := report.ReportDate.UTC()
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
@@ -0,0 +1,42 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoreDuplicate(t *testing.T) {
+	month := func(m time.Month) *time.Time {
+		d := time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	value := func(v float64) *float64 { return &v }
+	report := func(m time.Month, month1, aum float64) *FundReport {
+		return &FundReport{ReportDate: month(m), Month1Returns: value(month1), AUM: value(aum), Strategy: "Equity"}
+	}
+
+	fund := &Fund{Name: "Alpha Growth Portfolio"}
+	same := ScoreDuplicate(fund, &Fund{Name: "ALPHA GROWTH  PORTFOLIO"},
+		[]*FundReport{report(time.April, 1, 100), report(time.May, 2, 110)},
+		[]*FundReport{report(time.May, 2.05, 110), report(time.April, 1, 100)})
+	if same.NameScore != 1 || same.ReturnsScore != 1 || same.OverlappingMonths != 2 || same.AUMScore != 1 || same.StrategyScore != 1 {
+		t.Errorf("score of a refiled fund = %+v", same)
+	}
+	if same.Score < 0.99 {
+		t.Errorf("Score = %v, want 1", same.Score)
+	}
+
+	renamed := ScoreDuplicate(fund, &Fund{Name: "Alpha Growth Portfolio II"},
+		[]*FundReport{report(time.March, 1, 100), report(time.April, 1, 100)},
+		[]*FundReport{report(time.June, 3, 80)})
+	if renamed.OverlappingMonths != 0 || renamed.AUMScore != 0.8 || renamed.NameScore >= 1 || renamed.NameScore < 0.7 {
+		t.Errorf("score of a continued fund = %+v", renamed)
+	}
+
+	unrelated := ScoreDuplicate(fund, &Fund{Name: "Debt Income"},
+		[]*FundReport{report(time.April, 1, 100)},
+		[]*FundReport{{ReportDate: month(time.April), Month1Returns: value(0.4), AUM: value(10), Strategy: "Debt"}})
+	if unrelated.Score >= DefaultDuplicateScore {
+		t.Errorf("score of unrelated funds = %+v", unrelated)
+	}
+}
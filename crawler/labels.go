@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Sources of a LabelChange.
+const (
+	LabelSourceAPI    = "api"
+	LabelSourceImport = "import"
+)
+
+// maxLabelLength caps the length of a fund label, in characters.
+const maxLabelLength = 200
+
+// LabelChange records a change of the label of a fund or of the canonical fund it is
+// merged into.
+type LabelChange struct {
+	ID     uint64 `json:"id"`
+	FundID uint64 `gorm:"index" json:"fund_id"`
+
+	PreviousLabel      string  `gorm:"not null;default:''" json:"previous_label"`
+	Label              string  `gorm:"not null;default:''" json:"label"`
+	PreviousOriginalID *uint64 `json:"previous_original_id"`
+	OriginalID         *uint64 `json:"original_id"`
+
+	// Source is how the change was made, one of the LabelSource constants.
+	Source    string    `gorm:"not null;default:''" json:"source"`
+	ChangedBy string    `gorm:"not null;default:''" json:"changed_by"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LabelRow is a row of a label file: the fund, its label and whether it is the
+// canonical fund of the label, which the other funds of its fund house with the same
+// label are merged into.
+type LabelRow struct {
+	Line      int    `json:"line"`
+	FundID    uint64 `json:"fund_id"`
+	Name      string `json:"name"`
+	Label     string `json:"label"`
+	Canonical bool   `json:"canonical"`
+}
+
+// NormalizeLabel trims the label and collapses runs of spaces.
+func NormalizeLabel(label string) string {
+	return strings.Join(strings.Fields(label), " ")
+}
+
+// ValidateLabel checks a normalized label is set, not too long and printable.
+func ValidateLabel(label string) error {
+	if label == "" {
+		return errors.New("label is required")
+	}
+	if n := len([]rune(label)); n > maxLabelLength {
+		return fmt.Errorf("label is %d characters, at most %d are allowed", n, maxLabelLength)
+	}
+	if strings.IndexFunc(label, unicode.IsControl) >= 0 {
+		return errors.New("label has control characters")
+	}
+	return nil
+}
+
+// ReadLabelRows reads a label file: CSV rows of fund ID, fund name, label and "1" for
+// the canonical fund, as static/funds.csv was kept. IDs may be formatted as a
+// spreadsheet shows them, such as "5,141.00"; a row without an ID is matched on the
+// fund name. Lines starting with "#", such as the metadata of an export, and a
+// header row starting with "id" are skipped. Labels are normalized but not validated.
+func ReadLabelRows(r io.Reader) ([]LabelRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var rows []LabelRow
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "id") {
+			continue
+		}
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: %d columns, want id, name, label and canonical", line, len(record))
+		}
+
+		row := LabelRow{Line: line, Name: strings.TrimSpace(record[1]), Label: NormalizeLabel(record[2])}
+		if id := strings.NewReplacer(",", "", " ", "").Replace(record[0]); id != "" {
+			if whole, fraction, ok := strings.Cut(id, "."); ok && strings.Trim(fraction, "0") == "" {
+				id = whole
+			}
+			if row.FundID, err = strconv.ParseUint(id, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid fund id %q", line, record[0])
+			}
+		}
+		if row.FundID == 0 && row.Name == "" {
+			return nil, fmt.Errorf("line %d: fund id or name is required", line)
+		}
+		row.Canonical = len(record) == 4 && strings.TrimSpace(record[3]) == "1"
+		rows = append(rows, row)
+	}
+}
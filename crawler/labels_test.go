@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLabelRows(t *testing.T) {
+	rows, err := ReadLabelRows(strings.NewReader(`ID,Name,Label,Canonical
+"1,17,050",Accelt Long Term Equity Fund,Accelt Asset Management :  Long Term Equity Fund ,1
+"7,317.00",1729 FUNDAMENTAL VALUE INVESTING,1729 Advisors : Fundamental Value Investing,pending
+,Alchemy Select Stock,Alchemy Capital : Select Stock
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LabelRow{
+		{Line: 2, FundID: 117050, Name: "Accelt Long Term Equity Fund", Label: "Accelt Asset Management : Long Term Equity Fund", Canonical: true},
+		{Line: 3, FundID: 7317, Name: "1729 FUNDAMENTAL VALUE INVESTING", Label: "1729 Advisors : Fundamental Value Investing"},
+		{Line: 4, Name: "Alchemy Select Stock", Label: "Alchemy Capital : Select Stock"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("ReadLabelRows() = %+v, want %+v", rows, want)
+	}
+	for idx := range want {
+		if rows[idx] != want[idx] {
+			t.Errorf("row %d = %+v, want %+v", idx, rows[idx], want[idx])
+		}
+	}
+
+	for _, file := range []string{"1,Name\n", "x,Name,Label,1\n", ",,Label,1\n"} {
+		if _, err := ReadLabelRows(strings.NewReader(file)); err == nil {
+			t.Errorf("ReadLabelRows(%q) = nil error", file)
+		}
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	if err := ValidateLabel("Amaltas Asset Management LLP Keystone Fund"); err != nil {
+		t.Errorf("ValidateLabel() = %v", err)
+	}
+	for _, label := range []string{"", strings.Repeat("x", maxLabelLength+1), "Top\tPicks"} {
+		if err := ValidateLabel(label); err == nil {
+			t.Errorf("ValidateLabel(%q) = nil, want an error", label)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"time"
+)
+
+// MergeEvent records a fund merged into another, its target, with what the merge
+// changed on the merged fund so an unmerge can restore it. A merge hides the merged
+// fund, gives it the target's label and copies its reports to the target as
+// ProvenanceMerged reports.
+type MergeEvent struct {
+	ID       uint64 `json:"id"`
+	FundID   uint64 `gorm:"index" json:"fund_id"`
+	TargetID uint64 `gorm:"index" json:"target_id"`
+	MergedBy string `gorm:"not null;default:''" json:"merged_by"`
+
+	// The merged fund before the merge.
+	PreviousOriginalID *uint64 `json:"previous_original_id"`
+	PreviousLabel      string  `gorm:"not null;default:''" json:"previous_label"`
+	PreviousIsHidden   bool    `gorm:"not null;default:false" json:"previous_is_hidden"`
+
+	// ReportsCopied is the number of reports copied to the target.
+	ReportsCopied int `gorm:"not null;default:0" json:"reports_copied"`
+	// MovedFundIDs are the funds merged into the merged fund that the merge moved to
+	// the target. An unmerge moves them back.
+	MovedFundIDs []uint64 `gorm:"serializer:json" json:"moved_fund_ids"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UnmergedBy string     `gorm:"not null;default:''" json:"unmerged_by"`
+	UnmergedAt *time.Time `json:"unmerged_at"`
+}
+
+// MergePreview shows what merging a fund into a target would do to the target's
+// reports.
+type MergePreview struct {
+	Fund   *Fund `json:"fund"`
+	Target *Fund `json:"target"`
+	// Filled are the months only the merged fund has a report for, which the merge
+	// adds to the target.
+	Filled []time.Time `json:"filled"`
+	// Overlapping are the months both funds have a report for. The target keeps its
+	// own report for them.
+	Overlapping []MonthComparison `json:"overlapping"`
+}
+
+// MonthComparison is the report of each fund for a month both have one for.
+type MonthComparison struct {
+	ReportDate time.Time    `json:"report_date"`
+	Fund       ReportValues `json:"fund"`
+	Target     ReportValues `json:"target"`
+	// Conflicts names the figures both reports have with different values.
+	Conflicts []string `json:"conflicts"`
+}
+
+// CompareReports returns the preview of merging the fund with reports into the target
+// with targetReports, given the effective report of each month of both.
+func CompareReports(fund, target *Fund, reports, targetReports []*FundReport) *MergePreview {
+	preview := &MergePreview{Fund: fund, Target: target, Filled: []time.Time{}, Overlapping: []MonthComparison{}}
+	byMonth := make(map[time.Time]*FundReport, len(targetReports))
+	for _, report := range targetReports {
+		if report.ReportDate != nil {
+			byMonth[report.ReportDate.UTC()] = report
+		}
+	}
+	for _, report := range reports {
+		if report.ReportDate == nil {
+			continue
+		}
+		month := report.ReportDate.UTC()
+		targetReport, ok := byMonth[month]
+		if !ok {
+			preview.Filled = append(preview.Filled, month)
+			continue
+		}
+		values, targetValues := report.Values(), targetReport.Values()
+		preview.Overlapping = append(preview.Overlapping, MonthComparison{
+			ReportDate: month,
+			Fund:       values,
+			Target:     targetValues,
+			Conflicts:  values.conflicts(targetValues),
+		})
+	}
+	return preview
+}
+
+// conflicts returns the names of the figures v and other both have with different
+// values.
+func (v ReportValues) conflicts(other ReportValues) []string {
+	conflicts := []string{}
+	if differ(v.AUM, other.AUM) {
+		conflicts = append(conflicts, "aum")
+	}
+	for idx, value := range v.returns() {
+		if differ(value, other.returns()[idx]) {
+			conflicts = append(conflicts, returnNames[idx])
+		}
+	}
+	if v.Strategy != "" && other.Strategy != "" && v.Strategy != other.Strategy {
+		conflicts = append(conflicts, "strategy")
+	}
+	return conflicts
+}
+
+func differ(a, b *float64) bool {
+	return a != nil && b != nil && *a != *b
+}
+
+// returnNames are the JSON names of the figures returned by ReportValues.returns.
+var returnNames = []string{"1_month_return", "3_month_return", "6_month_return", "1_year_return", "2_year_return",
+	"3_year_return", "4_year_return", "5_year_return", "over_all_return"}
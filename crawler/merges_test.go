@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCompareReports(t *testing.T) {
+	month := func(m time.Month) *time.Time {
+		d := time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	value := func(v float64) *float64 { return &v }
+	reports := []*FundReport{
+		{ReportDate: month(time.April), Month1Returns: value(1)},
+		{ReportDate: month(time.May), Month1Returns: value(2), AUM: value(50), Strategy: "Equity"},
+		{ReportDate: month(time.June), Month1Returns: value(3)},
+	}
+	targetReports := []*FundReport{
+		{ReportDate: month(time.May), Month1Returns: value(2.5), AUM: value(50), Strategy: "Debt"},
+		{ReportDate: month(time.June), Month1Returns: value(3), Yr1Returns: value(10)},
+	}
+
+	preview := CompareReports(&Fund{ID: 2}, &Fund{ID: 1}, reports, targetReports)
+	if len(preview.Filled) != 1 || !preview.Filled[0].Equal(*month(time.April)) {
+		t.Errorf("Filled = %v, want April", preview.Filled)
+	}
+	if len(preview.Overlapping) != 2 {
+		t.Fatalf("Overlapping has %d months, want 2", len(preview.Overlapping))
+	}
+	if got := fmt.Sprint(preview.Overlapping[0].Conflicts); got != "[1_month_return strategy]" {
+		t.Errorf("May conflicts = %s", got)
+	}
+	if got := preview.Overlapping[1].Conflicts; len(got) != 0 {
+		t.Errorf("June conflicts = %v, want none", got)
+	}
+}
@@ -3,7 +3,9 @@ package mf
 import (
 	"alpha2/crawler"
 	"alpha2/jobs"
+	"alpha2/store"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -12,22 +14,26 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-func init() {
+// RegisterJobs registers the mutual fund jobs with the scheduler's job registry.
+// The jobs read and write through st.
+func RegisterJobs(st *store.Store) {
 	jobs.RegisterJob("MFSync", func() jobs.Job {
-		return &MFSync{}
+		return &MFSync{store: st}
 	})
 	jobs.RegisterJob("MFNavSync", func() jobs.Job {
-		return &MFNavSync{}
+		return &MFNavSync{store: st}
 	})
 	jobs.RegisterJob("MFRetuns", func() jobs.Job {
-		return &MFRetuns{}
+		return &MFRetuns{store: st}
 	})
 }
 
 type MFSync struct {
+	store *store.Store
 }
 
 func (j *MFSync) Execute(ctx context.Context) error {
+	jobs.ReportStage(ctx, "crawling funds")
 	mfCrawler := NewMutualFundCrawler()
 	funds, err := mfCrawler.CrawlFundMeta()
 	if err != nil {
@@ -37,17 +43,17 @@ func (j *MFSync) Execute(ctx context.Context) error {
 	if len(funds) == 0 {
 		return nil
 	}
-	db := crawler.Conn()
+	db := j.store.DB(ctx)
 	db.Clauses(clause.OnConflict{
 		DoNothing: true,
 	}).Save(&funds)
+	jobs.ReportStage(ctx, "scheduling NAV syncs")
 	for idx, fund := range funds {
 		job := &MFNavSync{
 			FundID: fund.ID,
 		}
 
-		nxtDate := time.Now().AddDate(0, 0, -time.Now().Day())
-		key := fmt.Sprintf("%d-%s", job.FundID, nxtDate.Format(time.DateOnly))
+		key := fmt.Sprintf("%d-%s", job.FundID, time.Now().Format(time.DateOnly))
 		jobDetail := quartz.NewJobDetailWithOptions(
 			job, quartz.NewJobKeyWithGroup(key, "MFNavSync"),
 			&quartz.JobDetailOptions{
@@ -59,9 +65,10 @@ func (j *MFSync) Execute(ctx context.Context) error {
 		)
 
 		err = jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(time.Second*time.Duration(idx)))
-		if err != nil {
+		if err != nil && !errors.Is(err, quartz.ErrJobAlreadyExists) {
 			return err
 		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 	}
 
 	return nil
@@ -76,12 +83,14 @@ func (p *MFSync) Description() string {
 
 type MFNavSync struct {
 	FundID uint
+
+	store *store.Store
 }
 
 func (m *MFNavSync) Execute(ctx context.Context) error {
 	mfCrawler := NewMutualFundCrawler()
 
-	db := crawler.Conn()
+	db := m.store.DB(ctx)
 	var res MutualFundData
 	db.Where("id = ?", m.FundID).Find(&res)
 	navs, err := mfCrawler.CrawlFundNav(&res)
@@ -89,7 +98,7 @@ func (m *MFNavSync) Execute(ctx context.Context) error {
 		return err
 	}
 	if len(navs) == 0 {
-		db.Save(&crawler.CrawlerEvent{
+		m.store.Events.Create(ctx, &crawler.CrawlerEvent{
 			Data: crawler.JSONB{"FundID": strconv.Itoa(int(m.FundID)), "error": "No NAVs found"},
 		})
 		return nil
@@ -112,13 +121,13 @@ func (m *MFNavSync) Description() string {
 
 type MFRetuns struct {
 	FundID uint64
+
+	store *store.Store
 }
 
 func (m *MFRetuns) Execute(ctx context.Context) error {
-	db := crawler.Conn()
-
-	fund := &crawler.Fund{}
-	if err := db.Model(&crawler.Fund{}).Find(fund, m.FundID).Error; err != nil {
+	fund, err := m.store.Funds.Get(ctx, m.FundID)
+	if err != nil {
 		return err
 	}
 	mdID, err := strconv.ParseUint(fund.OtherData["mf_fund_id"], 10, 64)
@@ -127,19 +136,13 @@ func (m *MFRetuns) Execute(ctx context.Context) error {
 	}
 
 	var navs []MutualFundNav
-	err = db.Raw(`
-        WITH RankedNavs AS (
-            SELECT *, 
-                   ROW_NUMBER() OVER (
-                       PARTITION BY mutual_fund_data_id, DATE_TRUNC('month', date) 
-                       ORDER BY date DESC
-                   ) AS rank
-            FROM mutual_fund_navs
-        )
-        SELECT * FROM RankedNavs WHERE rank = 1 AND mutual_fund_data_id = ?;
-    `, mdID).Scan(&navs).Error
+	d := m.store.Dialect()
+	err = m.store.DB(ctx).Raw(
+		d.FirstPerGroup("mutual_fund_navs", "mutual_fund_data_id = ?",
+			[]string{"mutual_fund_data_id", d.MonthStart("date")}, "date DESC"),
+		mdID).Scan(&navs).Error
 	if err != nil {
-		db.Save(&crawler.CrawlerEvent{
+		m.store.Events.Create(ctx, &crawler.CrawlerEvent{
 			Data: crawler.JSONB{"FundID": strconv.Itoa(int(m.FundID)), "error": err.Error()},
 		})
 		return err
@@ -159,19 +162,19 @@ func (m *MFRetuns) Execute(ctx context.Context) error {
 			FundID:        m.FundID,
 			ReportDate:    nav.Date,
 			Month1Returns: &r,
+			Source:        "advisorkhoj",
 		}
 		reports = append(reports, report)
 	}
 
-	if len(reports) == 0 {
+	return m.store.Transaction(ctx, func(tx *store.Store) error {
+		for _, report := range reports {
+			if err := tx.Reports.Upsert(ctx, report); err != nil {
+				return err
+			}
+		}
 		return nil
-	}
-
-	if err := db.Save(&reports).Error; err != nil {
-		return err
-	}
-
-	return nil
+	})
 }
 
 func (m *MFRetuns) SetDescription(s string) {
@@ -1,6 +1,7 @@
 package mf
 
 import (
+	"alpha2/crawler"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -34,6 +35,10 @@ type MutualFundNav struct {
 	Date             *time.Time `json:"navdate"`
 }
 
+// advisorkhojSession is shared by every request to advisorkhoj, which expects the
+// cookies of its landing page.
+var advisorkhojSession = crawler.NewSession("https://www.advisorkhoj.com/", "", time.Hour)
+
 type MutualFundCrawler struct {
 	collector *colly.Collector
 	queue     *queue.Queue
@@ -41,6 +46,8 @@ type MutualFundCrawler struct {
 
 func NewMutualFundCrawler() *MutualFundCrawler {
 	collector := colly.NewCollector()
+	collector.WithTransport(crawler.Politeness())
+	collector.SetCookieJar(advisorkhojSession.Jar())
 	extensions.RandomUserAgent(collector)
 	extensions.Referer(collector)
 	queue, _ := queue.New(
@@ -155,7 +162,6 @@ func addHeaders(req *colly.Request) {
 	req.Headers.Add("Accept-Encoding", "gzip, deflate, br, zstd")
 	req.Headers.Add("Referer", "https://www.advisorkhoj.com/mutual-funds-research/mutual-fund-portfolio/Axis-Mutual-Fund/2024")
 	req.Headers.Add("Connection", "keep-alive")
-	req.Headers.Add("Cookie", "_GPSLSC=; JSESSIONID=7C8F93A86A4B5932931D550CAFFF596B; G_ENABLED_IDPS=google; dsq__u=298eo8n38cmibc; dsq__s=298eo8n38cmibc; _GPSLSC=")
 	req.Headers.Add("Upgrade-Insecure-Requests", "1")
 	req.Headers.Add("Sec-Fetch-Dest", "document")
 	req.Headers.Add("Sec-Fetch-Mode", "navigate")
@@ -170,7 +176,6 @@ func addHeaders2(req *colly.Request) {
 	req.Headers.Add("Accept-Encoding", "gzip, deflate, br, zstd")
 	req.Headers.Add("Connection", "keep-alive")
 	req.Headers.Add("Referer", "https://www.advisorkhoj.com/mutual-funds-research/historical-NAV/HDFC%20Overnight%20Gr?start_date=07-04-2015&end_date=13-11-2019")
-	req.Headers.Add("Cookie", "_GPSLSC=; _GPSLSC=; JSESSIONID=115879A3A0BBC5DC6EE5AC68917995FB; G_ENABLED_IDPS=google; dsq__u=29chejv1vvckv4; dsq__s=29chejv1vvckv4; _GPSLSC=")
 	req.Headers.Add("Upgrade-Insecure-Requests", "1")
 	req.Headers.Add("Sec-Fetch-Dest", "document")
 	req.Headers.Add("Sec-Fetch-Mode", "navigate")
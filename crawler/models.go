@@ -4,7 +4,6 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -26,6 +25,14 @@ type FundManager struct {
 
 	RefreshedDate *time.Time
 
+	UID              string `gorm:"not null;default:'';index"`
+	RegistrationName string `gorm:"not null;default:''"`
+	Slug             string `gorm:"not null;default:'';index"`
+	DisplayName      string `gorm:"not null;default:''"`
+	LogoURL          string `gorm:"not null;default:''"`
+	Description      string `gorm:"not null;default:''"`
+
+	// OtherData holds ad-hoc attributes such as compliance officer details.
 	OtherData JSONB `gorm:"type:jsonb"`
 
 	Managers []*Manager `json:"managers"`
@@ -48,10 +55,6 @@ type Manager struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
-func (f *FundManager) RegistrationName() string {
-	return f.OtherData["RegistrationName"]
-}
-
 type Fund struct {
 	ID   uint64   `json:"id"`
 	Name string   `json:"name"`
@@ -84,35 +87,26 @@ type Fund struct {
 	SharpeRatio3Yrs *float64 `json:"sharpe_ratio_3yr"`
 	SharpeRatio5Yrs *float64 `json:"sharpe_ratio_5yr"`
 
+	// Label is the curated display name, empty until the fund is labelled.
+	Label string `gorm:"not null;default:'';index" json:"label"`
+	// OriginalID is the canonical fund this one is merged into.
+	OriginalID *uint64 `gorm:"index" json:"original_id"`
+
 	OtherData JSONB `gorm:"type:jsonb"`
 }
 
 func (f *Fund) DisplayName() string {
-	if f.OtherData == nil {
+	if f.Label == "" {
 		return f.Name
 	}
-	label, ok := f.OtherData["label"]
-	if !ok {
-		return f.Name
-	}
-	return label
-}
-func (f *Fund) OptDisplayName() string {
-	if f.OtherData == nil {
-		return ""
-	}
-	label, ok := f.OtherData["label"]
-	if !ok {
-		return ""
-	}
-	return label
+	return f.Label
 }
 
 type FundReport struct {
 	ID     uint64
-	FundID uint64 `json:"fund_id" gorm:"uniqueIndex:idx_report_date_fund_id"`
+	FundID uint64 `json:"fund_id" gorm:"uniqueIndex:idx_fund_report_origin"`
 
-	ReportDate *time.Time `gorm:"uniqueIndex:idx_report_date_fund_id"`
+	ReportDate *time.Time `gorm:"uniqueIndex:idx_fund_report_origin"`
 
 	Month1Returns *float64 `json:"1_month_return"`
 	Month3Returns *float64 `json:"3_month_return"`
@@ -125,22 +119,26 @@ type FundReport struct {
 	Yr5Returns     *float64 `json:"5_year_return"`
 	OverAllReturns *float64 `json:"over_all_return"`
 
-	OtherData JSONB `gorm:"type:jsonb" json:"-"`
-}
+	AUM      *float64 `json:"aum"`
+	Strategy string   `gorm:"not null;default:''" json:"strategy"`
+	// MergedID is the fund the report was copied from when that fund was merged.
+	MergedID *uint64 `gorm:"index" json:"merged_id"`
+	// Priority is "low" on merged reports written before Provenance was recorded.
+	Priority string `gorm:"not null;default:''" json:"priority"`
+
+	// Provenance is how the report was obtained, one of the Provenance constants.
+	Provenance string `gorm:"not null;default:'crawled'" json:"provenance"`
+	// Origin tells apart the reports of a fund and month: the provenance, and for
+	// merged reports the merged fund, as returned by MergedOrigin.
+	Origin string `gorm:"not null;default:'crawled';uniqueIndex:idx_fund_report_origin" json:"origin"`
+	// Source is the crawler.Source a crawled report was published by.
+	Source string `gorm:"not null;default:''" json:"source"`
+	// CrawlRunID is the jobs.JobRun that saved a crawled report.
+	CrawlRunID *uint `json:"crawl_run_id"`
+	// UploadID is the ReportUpload a manual report was approved from.
+	UploadID *uint64 `json:"upload_id"`
 
-func (f *FundReport) AUM() *float64 {
-	if f.OtherData == nil {
-		return nil
-	}
-	aum, ok := f.OtherData["AUM"]
-	if !ok {
-		return nil
-	}
-	aumFloat, err := strconv.ParseFloat(aum, 64)
-	if err != nil {
-		return nil
-	}
-	return &aumFloat
+	OtherData JSONB `gorm:"type:jsonb" json:"-"`
 }
 
 type FundXFundManagers struct {
@@ -3,9 +3,11 @@ package pmf
 import (
 	"alpha2/crawler"
 	"alpha2/jobs"
+	"alpha2/store"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"strconv"
 	"time"
@@ -13,37 +15,41 @@ import (
 	"github.com/reugn/go-quartz/quartz"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-func init() {
-	jobs.RegisterJob("PMSDataConsistencyJob", func() jobs.Job {
-		return &PMSDataConsistencyJob{}
-	})
-}
-
 type PMSDataConsistencyJob struct {
 	FundHouseID uint64
+
+	store *store.Store
 }
 
 func (j *PMSDataConsistencyJob) Execute(ctx context.Context) (err error) {
-
-	db := crawler.Conn()
-
-	fundHouse := &crawler.FundManager{}
-	err = db.Where(&crawler.FundManager{ID: j.FundHouseID}).Preload("Funds").First(fundHouse).Error
+	fundHouse, err := j.store.FundHouses.GetWithFunds(ctx, j.FundHouseID)
 	if err != nil {
 		log.Error().Err(err).Msg("Error while getting fund house")
 		return err
 	}
 
-	resyncReportsForMergedFunds(db, fundHouse.Funds)
-	updateDrawdownForFunds(db, fundHouse.Funds, 3)
-	updateSharpeRatioForFunds(db, fundHouse.Funds, 3)
-	hideFundsIfNoReportsFor3Months(db, fundHouse.Funds)
-
-	return
+	// Every step runs even when an earlier one fails, and the run fails with all
+	// their errors so it is retried.
+	var errs []error
+	jobs.ReportStage(ctx, "resyncing merged funds")
+	if err := resyncReportsForMergedFunds(ctx, j.store, fundHouse.Funds); err != nil {
+		errs = append(errs, fmt.Errorf("resyncing merged funds: %w", err))
+	}
+	jobs.ReportStage(ctx, "updating drawdowns")
+	if err := updateDrawdownForFunds(ctx, j.store, fundHouse.Funds, 3); err != nil {
+		errs = append(errs, fmt.Errorf("updating drawdowns: %w", err))
+	}
+	jobs.ReportStage(ctx, "updating sharpe ratios")
+	if err := updateSharpeRatioForFunds(ctx, j.store, fundHouse.Funds, 3); err != nil {
+		errs = append(errs, fmt.Errorf("updating sharpe ratios: %w", err))
+	}
+	jobs.ReportStage(ctx, "hiding stale funds")
+	if err := hideFundsIfNoReportsFor3Months(ctx, j.store, fundHouse.Funds); err != nil {
+		errs = append(errs, fmt.Errorf("hiding stale funds: %w", err))
+	}
+	return errors.Join(errs...)
 }
 
 func (j *PMSDataConsistencyJob) SetDescription(s string) {
@@ -63,53 +69,40 @@ func (j *PMSDataConsistencyJob) Description() string {
 	return string(data)
 }
 
-func hideFundsIfNoReportsFor3Months(db *gorm.DB, funds []*crawler.Fund) error {
+func hideFundsIfNoReportsFor3Months(ctx context.Context, st *store.Store, funds []*crawler.Fund) error {
 	// Check if the fund has reports for the last 3 months
-	for _, fund := range funds {
-		if fund.OtherData != nil && fund.OtherData["original_id"] != "" {
+	for idx, fund := range funds {
+		if fund.OriginalID != nil {
 			fund.IsHidden = true
+			jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 			continue
 		}
-		reports := []crawler.FundReport{}
 		// Get the report for latest 5 months, there is delay in data getting updted in SEBI.
 		now := time.Now().AddDate(0, -5, 0)
-		err := db.Where(&crawler.FundReport{
-			FundID: fund.ID,
-		}).
-			Where("report_date > ?", now).
-			Find(&reports).Error
-
+		reports, err := st.Reports.Since(ctx, fund.ID, now)
 		if err != nil {
 			return err
 		}
 
 		fund.IsHidden = len(reports) < 3
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 	}
 
-	db.Save(&funds)
-
-	return nil
+	return st.Funds.SaveAll(ctx, funds)
 }
 
-func updateDrawdownForFunds(db *gorm.DB, funds []*crawler.Fund, noOfYears int) error {
-	for _, fund := range funds {
-
-		var reports []crawler.FundReport
-		err := db.Where(&crawler.FundReport{
-			FundID: fund.ID,
-		}).
-			Where("month1_returns IS NOT NULL").
-			Order("report_date desc").
-			Limit(noOfYears * 12).
-			Find(&reports).Error
+func updateDrawdownForFunds(ctx context.Context, st *store.Store, funds []*crawler.Fund, noOfYears int) error {
+	for idx, fund := range funds {
+		reports, err := st.Reports.RecentMonthly(ctx, fund.ID, noOfYears*12, time.Time{})
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to fetch report data")
 			return err
 		}
 
 		if len(reports) < noOfYears*12 {
-			log.Warn().Msg("Insufficient data for drawdown calculation")
-			return nil
+			log.Warn().Uint64("fund_id", fund.ID).Msg("Insufficient data for drawdown calculation")
+			jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
+			continue
 		}
 
 		// Calculate Cumulative Return
@@ -140,10 +133,11 @@ func updateDrawdownForFunds(db *gorm.DB, funds []*crawler.Fund, noOfYears int) e
 			fund.MaxDrawdown5Yr = &maxDrawdown
 		}
 
-		if err := db.Save(&fund).Error; err != nil {
+		if err := st.Funds.Save(ctx, fund); err != nil {
 			log.Error().Err(err).Msg("Failed to save max drawdown")
 			return err
 		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 	}
 
 	return nil
@@ -172,15 +166,11 @@ func computeMaxDrawdown(data []struct {
 	return maxDrawdown
 }
 
-func updateSharpeRatioForFunds(db *gorm.DB, funds []*crawler.Fund, noOfYears int) error {
+func updateSharpeRatioForFunds(ctx context.Context, st *store.Store, funds []*crawler.Fund, noOfYears int) error {
 
-	for _, fund := range funds {
-		var reports []crawler.FundReport
+	for idx, fund := range funds {
 		threeYearsAgo := time.Now().AddDate(-noOfYears, 0, 0)
-		err := db.Where("fund_id = ? AND month1_returns IS NOT NULL AND report_date >= ?", fund.ID, threeYearsAgo).
-			Order("report_date desc").
-			Limit(noOfYears * 12).
-			Find(&reports).Error
+		reports, err := st.Reports.RecentMonthly(ctx, fund.ID, noOfYears*12, threeYearsAgo)
 		if err != nil {
 			log.Error().Err(err).Uint64("fund_id", fund.ID).Msg("Failed to fetch report data")
 			return err
@@ -188,7 +178,8 @@ func updateSharpeRatioForFunds(db *gorm.DB, funds []*crawler.Fund, noOfYears int
 
 		if len(reports) < noOfYears*12 {
 			log.Warn().Uint64("fund_id", fund.ID).Msg("Insufficient data for sharpe ratio calculation")
-			return nil
+			jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
+			continue
 		}
 
 		// Calculate mean return
@@ -223,75 +214,108 @@ func updateSharpeRatioForFunds(db *gorm.DB, funds []*crawler.Fund, noOfYears int
 		} else {
 			fund.SharpeRatio5Yrs = &sharpeRatio
 		}
-		if err = db.Save(&fund).Error; err != nil {
+		if err = st.Funds.Save(ctx, fund); err != nil {
 			log.Error().Err(err).Uint64("fund_id", fund.ID).Msg("Failed to fetch report data")
 			return err
 		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 	}
 	return nil
 }
 
-func resyncReportsForMergedFunds(db *gorm.DB, funds []*crawler.Fund) error {
-	for _, fund := range funds {
-		if fund.OtherData == nil || fund.OtherData["original_id"] == "" {
+func resyncReportsForMergedFunds(ctx context.Context, st *store.Store, funds []*crawler.Fund) error {
+	for idx, fund := range funds {
+		if fund.OriginalID == nil {
 			log.Info().Uint64("fund_id", fund.ID).Msg("No original fund id")
+			jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
 			continue
 		}
 
-		originalFundId, err := strconv.ParseUint(fund.OtherData["original_id"], 10, 64)
+		originalFundId := *fund.OriginalID
+
+		reports, err := st.Reports.ForFund(ctx, fund.ID)
 		if err != nil {
-			log.Error().Err(err).Uint64("fund_id", fund.ID).Msg("Error parsing original fund id")
+			log.Error().Err(err).Uint64("fund_id", fund.ID).Msg("Error fetching reports")
 			return err
 		}
+		if len(reports) == 0 {
+			log.Info().Uint64("fund_id", fund.ID).Msg("No reports found")
+			jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
+			continue
+		}
 
-		reports := []*crawler.FundReport{}
-		db.Where(&crawler.FundReport{
-			FundID: fund.ID,
-		}).FindInBatches(&reports, 100, func(tx *gorm.DB, batch int) error {
-			if len(reports) == 0 {
-				log.Info().Uint64("fund_id", fund.ID).Msg("No reports found")
-				return nil
-			}
+		lo.ForEach(reports, func(report *crawler.FundReport, index int) {
+			report.MarkMerged(fund.ID)
+			report.FundID = originalFundId
+			report.ID = 0
+		})
 
-			lo.ForEach(reports, func(report *crawler.FundReport, index int) {
-				report.OtherData["merged_id"] = strconv.FormatUint(fund.ID, 10)
-				report.OtherData["priority"] = "low"
-				report.FundID = originalFundId
-				report.ID = 0
-			})
-
-			for _, report := range reports {
-				err = db.Model(&crawler.FundReport{}).Clauses(clause.OnConflict{
-					DoNothing: true,
-				}).Create(report).Error
-
-				if err != nil {
-					log.Error().Err(err).Msg("Error saving report")
-					return err
-				}
+		for _, report := range reports {
+			if err := st.Reports.Upsert(ctx, report); err != nil {
+				log.Error().Err(err).Msg("Error saving report")
+				return err
 			}
+		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
+	}
 
-			return nil
-		})
+	return nil
+}
 
+// ScheduleDataConsistencyJobIsNotPresent queues the PMSDataConsistencyJob of a fund
+// house to run once the crawls queued for it have finished, unless one is already
+// queued, in which case it waits for them too.
+func ScheduleDataConsistencyJobIsNotPresent(ctx context.Context, st *store.Store, fundHouseID uint64) error {
+	fundHouse, err := st.FundHouses.Get(ctx, fundHouseID)
+	if err != nil {
+		return err
 	}
+	return scheduleDataConsistencyAfterCrawls(ctx, st, fundHouse.ID, fundHouse.UID)
+}
 
+// ScheduleDataConsistencyForFund queues the PMSDataConsistencyJob of each fund house of
+// the fund, unless one is already queued.
+func ScheduleDataConsistencyForFund(ctx context.Context, st *store.Store, fundID uint64) error {
+	funds, err := st.Funds.ListByIDs(ctx, []uint64{fundID})
+	if err != nil {
+		return err
+	}
+	for _, fund := range funds {
+		for _, fundHouse := range fund.FundManagers {
+			if err := scheduleDataConsistencyAfterCrawls(ctx, st, fundHouse.ID, fundHouse.UID); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-func ScheduleDataConsistencyJobIsNotPresent(fundHouseID uint64) error {
+// scheduleDataConsistencyAfterCrawls queues the PMSDataConsistencyJob of a fund house
+// to run once every other crawl queued for its UID has finished, so it sees all the
+// months being crawled. The running job is left out, as it is the one scheduling.
+func scheduleDataConsistencyAfterCrawls(ctx context.Context, st *store.Store, fundHouseID uint64, UID string) error {
+	crawls, err := queuedCrawls(ctx, st)
+	if err != nil {
+		return err
+	}
+	running := jobs.RunKey(ctx)
+	after := make([]string, 0, len(crawls[UID]))
+	for _, key := range crawls[UID] {
+		if key != running {
+			after = append(after, key)
+		}
+	}
+	return ScheduleDataConsistencyAfter(fundHouseID, after)
+}
+
+// ScheduleDataConsistencyAfter queues the PMSDataConsistencyJob of a fund house to run
+// once the jobs with the given keys have finished. It is the
+// crawler.ConsistencyScheduler, so the crawls of other sources keep the analytics of
+// their funds up to date.
+func ScheduleDataConsistencyAfter(fundHouseID uint64, after []string) error {
 	job := &PMSDataConsistencyJob{
 		FundHouseID: fundHouseID,
 	}
 	jd := quartz.NewJobDetail(job, quartz.NewJobKeyWithGroup(strconv.FormatUint(fundHouseID, 10), "PMSDataConsistencyJob"))
-	t := quartz.NewRunOnceTrigger(time.Minute * 1)
-	err := jobs.Scheduler.ScheduleJob(jd, t)
-	if err != nil {
-		if errors.Is(err, quartz.ErrJobAlreadyExists) {
-			log.Warn().Uint64("fund_house_id", fundHouseID).Msg("PMSDataConsistencyJob alredy present for fund house")
-			return nil
-		}
-		return err
-	}
-	return nil
+	return jobs.ScheduleAfter(jd, quartz.NewRunOnceTrigger(0), after)
 }
@@ -3,136 +3,90 @@ package pmf
 import (
 	"alpha2/crawler"
 	"alpha2/jobs"
+	"alpha2/store"
 	"context"
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
 	"time"
 
 	"github.com/reugn/go-quartz/quartz"
 	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-func init() {
+// RegisterJobs registers the PMS jobs with the scheduler's job registry and the
+// SEBI PMS source with the source registry, and makes PMSDataConsistencyJob the
+// consistency job of every source. The jobs read and write through st.
+func RegisterJobs(st *store.Store) {
+	crawler.RegisterSource(NewSource(st))
+	crawler.SetConsistencyScheduler(ScheduleDataConsistencyAfter)
 	jobs.RegisterJob("CrawlPMFFunds", func() jobs.Job {
-		return &CrawlPMFFunds{}
+		return &CrawlPMFFunds{store: st}
 	})
 	jobs.RegisterJob("PMFInit", func() jobs.Job {
 		return &PMFInit{}
 	})
+	jobs.RegisterJob("PMSDataConsistencyJob", func() jobs.Job {
+		return &PMSDataConsistencyJob{store: st}
+	})
+	jobs.RegisterJob("PMFMonthlyCrawl", func() jobs.Job {
+		return &PMFMonthlyCrawl{}
+	})
+	jobs.RegisterJob("PMSNightlyConsistency", func() jobs.Job {
+		return &PMSNightlyConsistency{store: st}
+	})
+	jobs.RegisterJob("PMFCrawlReconciler", func() jobs.Job {
+		return &PMFCrawlReconciler{store: st}
+	})
 }
 
 type CrawlPMFFunds struct {
 	UID      string
 	ForDate  string
 	SkipNext bool
+
+	store *store.Store
 }
 
 func (j *CrawlPMFFunds) Execute(ctx context.Context) (err error) {
 	forDate, _ := time.Parse(time.DateOnly, j.ForDate)
 
-	crwl := NewPMFCrawler()
-	crwl.CrawlFundWithManager(j.UID, &forDate, func(funds []*crawler.Fund) {
-		if crwl.err != nil {
+	crwl := NewPMFCrawler(ctx, j.store)
+	crawlErr := crwl.CrawlFundWithManager(j.UID, &forDate, func(funds []*crawler.Fund) {
+		if err != nil {
 			return
 		}
-		db := crawler.Conn()
-		db.Transaction(func(tx *gorm.DB) error {
+		err = func() error {
+			fundHouseIDs := map[uint64]bool{}
 			for _, fund := range funds {
-				fund.FundManagers[0].RefreshedDate = &forDate
-
-				tx := db.Model(&crawler.FundManager{}).Clauses(clause.OnConflict{
-					Columns: []clause.Column{{Name: "id"}},
-					Where: clause.Where{Exprs: []clause.Expression{
-						clause.And(
-							clause.Eq{Column: "fund_managers.id", Value: fund.FundManagers[0].ID},
-							clause.Lt{Column: "fund_managers.refreshed_date", Value: forDate},
-						),
-					}},
-					DoUpdates: clause.AssignmentColumns([]string{"name", "email", "contact", "address", "total_no_of_client", "other_data", "total_aum",
-						"refreshed_date"}),
-				}).Omit("Funds").Create(fund.FundManagers[0])
-				if tx.Error != nil {
+				if err := j.store.Funds.SaveCrawled(ctx, fund, forDate); err != nil {
 					jsonfund, _ := json.Marshal(fund)
-					log.Error().Err(tx.Error).RawJSON("fund", jsonfund).Msg("Error while saving funds")
-					err = tx.Error
-					return tx.Error
-				}
-
-				tx = db.Model(&crawler.Fund{}).Clauses(clause.OnConflict{
-					Columns:   []clause.Column{{Name: "id"}},
-					DoNothing: true,
-				}).Omit("FundReports").Create(fund)
-				if tx.Error != nil {
-					jsonfund, _ := json.Marshal(fund)
-					log.Error().Err(tx.Error).RawJSON("fund", jsonfund).Msg("Error while saving funds")
-					err = tx.Error
-					return tx.Error
-				}
-
-				for _, fundReport := range fund.FundReports {
-					fundReport.FundID = fund.ID
-					tx = db.Model(&crawler.FundReport{}).Clauses(clause.OnConflict{
-						Columns:   []clause.Column{{Name: "fund_id"}, {Name: "report_date"}},
-						UpdateAll: true,
-					}).Create(fundReport)
-					if tx.Error != nil {
-						jsonfund, _ := json.Marshal(fund)
-						log.Error().Err(tx.Error).RawJSON("fund", jsonfund).Msg("Error while saving funds")
-						err = tx.Error
-						return tx.Error
-					}
-				}
-
-				err = ScheduleDataConsistencyJobIsNotPresent(fund.FundManagers[0].ID)
-				if err != nil {
-					jsonfund, _ := json.Marshal(fund)
-					log.Error().Err(err).RawJSON("fund", jsonfund).Msg("Error while scheduling PMSDataConsistency job")
+					log.Error().Err(err).RawJSON("fund", jsonfund).Msg("Error while saving funds")
 					return err
 				}
+				fundHouseIDs[fund.FundManagers[0].ID] = true
 			}
 
-			if j.SkipNext {
-				return nil
-			}
-
+			// Backfill the following months that are already published. Future months
+			// are crawled by the PMFMonthlyCrawl schedule.
 			nxtDate := forDate.AddDate(0, 1, 0)
-
-			var triggerDur time.Duration
-
-			now := time.Now().AddDate(0, 0, -time.Now().Day())
-			if nxtDate.Before(now) {
-				triggerDur = time.Second * 1
-			} else {
-				triggerDur = time.Until(NextTimeToRunJob())
+			if !j.SkipNext && !nxtDate.After(lastPublishedMonth(time.Now())) {
+				if err := ScheduleCrawl(j.UID, nxtDate, false); err != nil {
+					return err
+				}
 			}
 
-			job := &CrawlPMFFunds{
-				UID:     j.UID,
-				ForDate: nxtDate.Format(time.DateOnly),
+			for fundHouseID := range fundHouseIDs {
+				if err := scheduleDataConsistencyAfterCrawls(ctx, j.store, fundHouseID, j.UID); err != nil {
+					log.Error().Err(err).Uint64("fund_house_id", fundHouseID).Msg("Error while scheduling PMSDataConsistency job")
+					return err
+				}
 			}
-
-			key := fmt.Sprintf("%s-%s-%s", j.UID, nxtDate.Format(time.DateOnly), RandomString(3))
-			jobDetail := quartz.NewJobDetailWithOptions(
-				job, quartz.NewJobKeyWithGroup(key, "CrawlPMFFunds"),
-				&quartz.JobDetailOptions{
-					MaxRetries:    10,
-					RetryInterval: time.Minute * 5,
-					Replace:       false,
-					Suspended:     false,
-				},
-			)
-
-			err = jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(triggerDur))
-
-			return err
-		})
+			return nil
+		}()
 	})
-	if crwl.err != nil {
-		return crwl.err
+	if crawlErr != nil {
+		return crawlErr
 	}
 
 	return err
@@ -155,44 +109,49 @@ func (j *CrawlPMFFunds) Description() string {
 	return string(data)
 }
 
-func NextTimeToRunJob() time.Time {
-	// Get the current time
-	now := time.Now()
-
-	// Calculate the first day of the current month
-	firstDayOfCurrentMonth := time.Date(now.Year(), now.Month(), 21, 0, 0, 0, 0, now.Location())
-
-	// Add one month to get the first day of the next month
-	firstDayOfNextMonth := firstDayOfCurrentMonth.AddDate(0, 1, 0)
+// ScheduleCrawl queues a crawl of the fund house with the given UID for the month of
+// forDate. A crawl already queued for that month is left as is. Unless skipNext is
+// set, the crawl goes on to the following published months.
+func ScheduleCrawl(UID string, forDate time.Time, skipNext bool) error {
+	job := &CrawlPMFFunds{
+		UID:      UID,
+		ForDate:  forDate.Format(time.DateOnly),
+		SkipNext: skipNext,
+	}
+	jobDetail := quartz.NewJobDetailWithOptions(
+		job, quartz.NewJobKeyWithGroup(fmt.Sprintf("%s-%s", job.UID, job.ForDate), "CrawlPMFFunds"),
+		&quartz.JobDetailOptions{
+			MaxRetries:    10,
+			RetryInterval: time.Minute * 5,
+			Replace:       false,
+			Suspended:     false,
+		},
+	)
+
+	err := jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(time.Second*1))
+	if errors.Is(err, quartz.ErrJobAlreadyExists) {
+		return nil
+	}
+	return err
+}
 
-	return firstDayOfNextMonth
+// lastPublishedMonth returns the first day of the last month whose data SEBI has
+// published by now: the previous month.
+func lastPublishedMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
 }
 
 type PMFInit struct{}
 
 func (j *PMFInit) Execute(ctx context.Context) (err error) {
+	jobs.ReportStage(ctx, "crawling fund houses")
 	UIDs := CrawlFundManagarIDs()
-	for _, UID := range UIDs {
-		job := &CrawlPMFFunds{
-			UID:     UID,
-			ForDate: "2021-01-01",
-		}
-
-		key := fmt.Sprintf("%s-%s-%s", job.UID, job.ForDate, RandomString(3))
-		jobDetail := quartz.NewJobDetailWithOptions(
-			job, quartz.NewJobKeyWithGroup(key, "CrawlPMFFunds"),
-			&quartz.JobDetailOptions{
-				MaxRetries:    10,
-				RetryInterval: time.Minute * 5,
-				Replace:       false,
-				Suspended:     false,
-			},
-		)
-
-		err = jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(time.Second*1))
-		if err != nil {
+	jobs.ReportStage(ctx, "scheduling crawls")
+	for idx, UID := range UIDs {
+		if err := ScheduleCrawl(UID, time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC), false); err != nil {
 			return err
 		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(UIDs)))
 	}
 	return nil
 }
@@ -202,17 +161,3 @@ func (j *PMFInit) SetDescription(s string) {
 func (p *PMFInit) Description() string {
 	return "PMFInit"
 }
-
-const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
-func RandomString(length int) string {
-	result := make([]byte, length)
-	for i := range result {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-		if err != nil {
-			return ""
-		}
-		result[i] = charset[num.Int64()]
-	}
-	return string(result)
-}
@@ -0,0 +1,21 @@
+package pmf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastPublishedMonth(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{now: time.Date(2025, time.March, 21, 2, 0, 0, 0, time.UTC), want: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{now: time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC), want: time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := lastPublishedMonth(tt.now); !got.Equal(tt.want) {
+			t.Errorf("lastPublishedMonth(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
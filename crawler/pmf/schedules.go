@@ -0,0 +1,129 @@
+package pmf
+
+import (
+	"alpha2/store"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PMFMonthlyCrawl queues a crawl of every fund house listed by SEBI for the last
+// published month. It is meant to run on a monthly schedule.
+type PMFMonthlyCrawl struct{}
+
+func (j *PMFMonthlyCrawl) Execute(ctx context.Context) error {
+	forDate := lastPublishedMonth(time.Now())
+	for _, UID := range CrawlFundManagarIDs() {
+		if err := ScheduleCrawl(UID, forDate, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *PMFMonthlyCrawl) SetDescription(s string) {
+}
+
+func (j *PMFMonthlyCrawl) Description() string {
+	return "PMFMonthlyCrawl"
+}
+
+// PMSNightlyConsistency queues a PMSDataConsistencyJob for every fund house.
+type PMSNightlyConsistency struct {
+	store *store.Store
+}
+
+func (j *PMSNightlyConsistency) Execute(ctx context.Context) error {
+	fundHouses, err := j.store.FundHouses.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, fundHouse := range fundHouses {
+		if err := scheduleDataConsistencyAfterCrawls(ctx, j.store, fundHouse.ID, fundHouse.UID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *PMSNightlyConsistency) SetDescription(s string) {
+}
+
+func (j *PMSNightlyConsistency) Description() string {
+	return "PMSNightlyConsistency"
+}
+
+// PMFCrawlReconciler finds fund houses that fell behind the most recently crawled
+// month and have no crawl queued, and queues one from the first month they miss.
+type PMFCrawlReconciler struct {
+	store *store.Store
+}
+
+func (j *PMFCrawlReconciler) Execute(ctx context.Context) error {
+	fundHouses, err := j.store.FundHouses.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	var latest time.Time
+	for _, fundHouse := range fundHouses {
+		if fundHouse.RefreshedDate != nil && fundHouse.RefreshedDate.After(latest) {
+			latest = *fundHouse.RefreshedDate
+		}
+	}
+	if latest.IsZero() {
+		return nil
+	}
+
+	queued, err := queuedCrawls(ctx, j.store)
+	if err != nil {
+		return err
+	}
+
+	for _, fundHouse := range fundHouses {
+		if fundHouse.UID == "" || len(queued[fundHouse.UID]) > 0 {
+			continue
+		}
+		if fundHouse.RefreshedDate != nil && !fundHouse.RefreshedDate.Before(latest) {
+			continue
+		}
+
+		from := latest
+		if fundHouse.RefreshedDate != nil {
+			refreshed := fundHouse.RefreshedDate
+			from = time.Date(refreshed.Year(), refreshed.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		log.Warn().Str("UID", fundHouse.UID).Time("from", from).Msg("Fund house has no crawl queued, scheduling one")
+		if err := ScheduleCrawl(fundHouse.UID, from, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queuedCrawls returns the keys of the CrawlPMFFunds jobs waiting or running, by the
+// UID of the fund house they crawl.
+func queuedCrawls(ctx context.Context, st *store.Store) (map[string][]string, error) {
+	queued, err := st.Jobs.List(ctx, store.JobFilter{Group: "CrawlPMFFunds"})
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string][]string)
+	for _, scheduled := range queued {
+		var crawl CrawlPMFFunds
+		if err := json.Unmarshal([]byte(scheduled.JobDescription), &crawl); err != nil {
+			log.Error().Err(err).Str("key", scheduled.JobKey).Msg("Error while reading queued crawl")
+			continue
+		}
+		keys[crawl.UID] = append(keys[crawl.UID], scheduled.JobKey)
+	}
+	return keys, nil
+}
+
+func (j *PMFCrawlReconciler) SetDescription(s string) {
+}
+
+func (j *PMFCrawlReconciler) Description() string {
+	return "PMFCrawlReconciler"
+}
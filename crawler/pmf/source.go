@@ -0,0 +1,109 @@
+package pmf
+
+import (
+	"alpha2/crawler"
+	"alpha2/store"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Source is the crawler.Source of the monthly PMS reports published by SEBI. Its
+// entities are the UIDs of portfolio managers.
+// sourceName is the Name of the source and the Source of its reports.
+const sourceName = "sebi-pms"
+
+type Source struct {
+	store *store.Store
+}
+
+// NewSource returns the SEBI PMS source. Parse looks up the fund houses through st.
+func NewSource(st *store.Store) *Source {
+	return &Source{store: st}
+}
+
+func (s *Source) Name() string {
+	return sourceName
+}
+
+func (s *Source) Discover(ctx context.Context) ([]string, error) {
+	UIDs := CrawlFundManagarIDs()
+	if len(UIDs) == 0 {
+		return nil, errors.New("SEBI listed no portfolio managers")
+	}
+	return UIDs, nil
+}
+
+func (s *Source) LatestPeriod(now time.Time) time.Time {
+	return lastPublishedMonth(now)
+}
+
+func (s *Source) NextPeriod(period time.Time) time.Time {
+	return period.AddDate(0, 1, 0)
+}
+
+// Fetch posts the report form with the token of the SEBI session, and once more
+// with a new session if SEBI answers with a session error page.
+func (s *Source) Fetch(ctx context.Context, entity string, period time.Time) ([]byte, error) {
+	client := &http.Client{Transport: crawler.Politeness(), Jar: sebiSession.Jar(), Timeout: time.Minute}
+	for attempt := 0; ; attempt++ {
+		token, err := sebiSession.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		form := reportForm(entity, period.Year(), int(period.Month()), token)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sebiPMRURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		addReportHeaders(req.Header)
+		// Let the transport negotiate and decode the encoding.
+		req.Header.Del("Accept-Encoding")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("SEBI report of %s for %s: status %d", entity, period.Format("2006-01"), resp.StatusCode)
+		}
+		if !isSessionError(body) {
+			return body, nil
+		}
+		sebiSession.Expire(token)
+		if attempt > 0 {
+			return nil, errors.New("SEBI session expired again after a retry")
+		}
+	}
+}
+
+func (s *Source) Parse(ctx context.Context, entity string, period time.Time, raw []byte) ([]*crawler.Fund, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Year:        period.Year(),
+		Month:       int(period.Month()),
+		GeneralInfo: loadFundHouse(ctx, s.store, entity),
+		Services:    make([]*DiscretionaryService, 0),
+		Complaints:  &Complaints{},
+	}
+	if report.GeneralInfo.OtherData == nil {
+		report.GeneralInfo.OtherData = make(map[string]string)
+	}
+	parseReport(doc.Find("#main-content"), report)
+	return reportToFundConverter([]*Report{report}), nil
+}
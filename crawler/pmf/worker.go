@@ -2,7 +2,12 @@ package pmf
 
 import (
 	"alpha2/crawler"
+	"alpha2/store"
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -11,356 +16,459 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
-	"github.com/gocolly/colly/v2/extensions"
 	"github.com/gocolly/colly/v2/queue"
 	"github.com/puzpuzpuz/xsync/v3"
 	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
 )
 
+// PMFCrawler crawls the monthly reports of portfolio managers from SEBI. Requests run
+// concurrently; each one parses its report into its own colly context and passes
+// the result to a single goroutine, which hands it on.
 type PMFCrawler struct {
-	fundManagers        []*crawler.FundManager
-	fundManagerVsReport *xsync.MapOf[string, []*Report]
-	collector           *colly.Collector
-	queue               *queue.Queue
+	collector *colly.Collector
+	store     *store.Store
+	ctx       context.Context
+
+	// fundHouses caches the fund houses looked up by UID. Requests use copies.
+	fundHouses *xsync.MapOf[string, *crawler.FundManager]
+	// results receives the result of every request of the running crawl.
+	results chan *crawlResult
+}
+
+// crawlResult is what a request for the report of a fund house for a month produced:
+// the parsed report or the error it failed with.
+type crawlResult struct {
+	UID, year, month string
 
-	err error
+	report *Report
+	err    error
 }
 
-func NewPMFCrawler() *PMFCrawler {
-	queue, _ := queue.New(
-		30,
-		nil,
-	)
-	return &PMFCrawler{
-		fundManagers:        make([]*crawler.FundManager, 0),
-		fundManagerVsReport: xsync.NewMapOf[string, []*Report](),
-		collector:           crawler.NewCollector(),
-		queue:               queue,
+const crawlThreads = 30
+
+// NewPMFCrawler returns a crawler that looks up fund houses and records failed
+// requests through st. ctx bounds those lookups, not the HTTP requests.
+func NewPMFCrawler(ctx context.Context, st *store.Store) *PMFCrawler {
+	collector := crawler.NewCollector()
+	collector.SetCookieJar(sebiSession.Jar())
+	p := &PMFCrawler{
+		collector:  collector,
+		store:      st,
+		ctx:        ctx,
+		fundHouses: xsync.NewMapOf[string, *crawler.FundManager](),
 	}
+	p.registerCrawler()
+	return p
 }
 
-func (p *PMFCrawler) CrawlAllFund(forDate *time.Time, cb crawler.SaveFund) []*crawler.Fund {
-	p.registerCrawler(cb)
-	p.registerCrawlerError()
-
-	portfolioManagerIDs := CrawlFundManagarIDs()
-	for _, uid := range portfolioManagerIDs {
-		for i := 1; i <= 12; i++ {
-			p.queue.AddRequest(CreateRequest(uid, forDate.Year(), int(forDate.Month())))
-		}
+// CrawlAllFund crawls the report of every portfolio manager for the month of forDate
+// and passes the funds of each to cb. It returns the first error of a request.
+func (p *PMFCrawler) CrawlAllFund(forDate *time.Time, cb crawler.SaveFund) error {
+	q, _ := queue.New(crawlThreads, nil)
+	for _, uid := range CrawlFundManagarIDs() {
+		q.AddRequest(CreateRequest(uid, forDate.Year(), int(forDate.Month())))
 	}
-
-	p.queue.Run(p.collector)
-
-	return nil
+	return p.run(q, p.save(cb))
 }
 
-func (p *PMFCrawler) CrawlFundWithManager(UID string, forDate *time.Time, cb crawler.SaveFund) []*crawler.Fund {
-	p.registerCrawler(cb)
-	p.registerCrawlerError()
-	p.queue.AddRequest(CreateRequest(UID, forDate.Year(), int(forDate.Month())))
-	p.queue.Run(p.collector)
-	return nil
+// CrawlFundWithManager crawls the report of one portfolio manager for the month of
+// forDate and passes its funds to cb.
+func (p *PMFCrawler) CrawlFundWithManager(UID string, forDate *time.Time, cb crawler.SaveFund) error {
+	q, _ := queue.New(crawlThreads, nil)
+	q.AddRequest(CreateRequest(UID, forDate.Year(), int(forDate.Month())))
+	return p.run(q, p.save(cb))
 }
 
+// ReTryFailed crawls again the requests recorded as failed and forgets the ones that
+// succeed.
 func (p *PMFCrawler) ReTryFailed(cb crawler.SaveFund) error {
-	db := crawler.Conn()
-	var events []crawler.CrawlerEvent
-	p.registerCrawler(cb)
-	db.Model(&crawler.CrawlerEvent{}).Where("Data->'UID' is not null").FindInBatches(&events, 100, func(tx *gorm.DB, batch int) error {
-		bulkQueue, _ := queue.New(
-			30,
-			nil,
-		)
-		for _, event := range events {
-			UID := event.Data["UID"]
-			year, _ := strconv.Atoi(event.Data["year"])
-			month, _ := strconv.Atoi(event.Data["month"])
-			if err := bulkQueue.AddRequest(CreateRequest(UID, year, month)); err != nil {
-				log.Error().Err(err).Msg("Error during AddRequest in ReTryFailed")
-			}
+	events, err := p.store.Events.FailedPMFRequests(p.ctx)
+	if err != nil {
+		return err
+	}
+
+	bulkQueue, _ := queue.New(crawlThreads, nil)
+	for _, event := range events {
+		UID := event.Data["UID"]
+		year, _ := strconv.Atoi(event.Data["year"])
+		month, _ := strconv.Atoi(event.Data["month"])
+		if err := bulkQueue.AddRequest(CreateRequest(UID, year, month)); err != nil {
+			log.Error().Err(err).Msg("Error during AddRequest in ReTryFailed")
 		}
+	}
 
-		p.collector.OnScraped(func(r *colly.Response) {
-			err := tx.Model(&crawler.CrawlerEvent{}).
-				Where("data->>'UID' = ? AND data->>'year' = ? AND data->>'month' = ?", r.Ctx.Get("UID"), r.Ctx.Get("year"), r.Ctx.Get("month")).
-				Delete(&crawler.CrawlerEvent{}).Error
-			if err != nil {
-				log.Error().Err(err).Msg("Error during Delete in ReTryFailed")
+	return p.run(bulkQueue, func(res *crawlResult) error {
+		if res.err != nil {
+			log.Error().Err(res.err).Msg("Error during colly request in ReTryFailed")
+			return res.err
+		}
+		cb(reportToFundConverter([]*Report{res.report}))
+		err := p.store.Events.DeletePMFFailure(p.ctx, res.UID, res.year, res.month)
+		if err != nil {
+			log.Error().Err(err).Msg("Error during Delete in ReTryFailed")
+		}
+		return nil
+	})
+}
+
+// run runs the requests of q and passes their results to handle from a single
+// goroutine. It returns the first error of handle.
+func (p *PMFCrawler) run(q *queue.Queue, handle func(*crawlResult) error) error {
+	p.results = make(chan *crawlResult)
+	written := make(chan error)
+	go func() {
+		var first error
+		for res := range p.results {
+			if err := handle(res); err != nil && first == nil {
+				first = err
 			}
-		})
+		}
+		written <- first
+	}()
 
-		p.collector.OnError(func(r *colly.Response, err error) {
-			log.Error().Err(err).Msg("Error during colly request in ReTryFailed")
-		})
+	runErr := q.Run(p.collector)
+	close(p.results)
+	if err := <-written; err != nil {
+		return err
+	}
+	return runErr
+}
 
-		return bulkQueue.Run(p.collector)
+// save returns a result handler that passes the funds of each report to cb and
+// records the failed requests, so ReTryFailed can send them again.
+func (p *PMFCrawler) save(cb crawler.SaveFund) func(*crawlResult) error {
+	// Fund houses created by an earlier report of the crawl, by UID.
+	created := make(map[string]uint64)
+	return func(res *crawlResult) error {
+		if res.err != nil {
+			p.recordFailure(res)
+			return res.err
+		}
+
+		fundHouse := res.report.GeneralInfo
+		if fundHouse.ID == 0 {
+			fundHouse.ID = created[res.UID]
+		}
+		cb(reportToFundConverter([]*Report{res.report}))
+		if fundHouse.ID != 0 {
+			created[res.UID] = fundHouse.ID
+		}
+		return nil
+	}
+}
+
+func (p *PMFCrawler) recordFailure(res *crawlResult) {
+	err := p.store.Events.Create(p.ctx, &crawler.CrawlerEvent{
+		Data: map[string]string{
+			"year":  res.year,
+			"month": res.month,
+			"UID":   res.UID,
+			"error": res.err.Error(),
+			"time":  time.Now().String(),
+		},
 	})
-	return nil
+	if err != nil {
+		log.Error().Err(err).
+			Str("year", res.year).
+			Str("month", res.month).
+			Str("UID", res.UID).
+			Msg("Create CrawlerEvent Failed")
+	}
 }
 
-func (p *PMFCrawler) registerCrawler(cb crawler.SaveFund) {
+func (p *PMFCrawler) registerCrawler() {
+	p.collector.OnRequest(func(r *colly.Request) {
+		r.Ctx.Put("report", p.newReport(r.Ctx))
+	})
+	p.registerSessionCheck()
 
-	//General Information
 	p.collector.OnHTML("#main-content", func(e *colly.HTMLElement) {
-		report := p.GetReport(e.Request.Ctx)
-		e.DOM.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
-			if strings.Contains(s.Text(), "General Information") {
-				s.Parent().Parent().Parent().Find("table").Find("tr").Each(func(i int, s *goquery.Selection) {
-					switch s.Find("th").Text() {
-					case "Name of the Portfolio Manager":
-						report.GeneralInfo.OtherData["RegistrationName"] = s.Find("td").Text()
-						return
-					case "Registration Number":
-						report.GeneralInfo.RegisterNumber = s.Find("td").Text()
-						return
-					case "Date of Registration":
-						target, err := time.Parse("2006-01-02", s.Find("td").Text())
-						if err != nil {
-							log.Error().
-								Str("date", s.Find("td").Text()).
-								Str("UID", e.Request.Ctx.Get("UID")).
-								Err(err).Msg("Error during Date of Registration")
-						}
-						report.GeneralInfo.RegisteredDate = &target
-						return
-					case "Registered Address of the Portfolio Manager":
-						report.GeneralInfo.Address = s.Find("td").Text()
-						return
-					case "Name of Principal Officer":
-						report.GeneralInfo.Name = s.Find("td").Text()
-						return
-					case "Email ID of the Principal Officer":
-						report.GeneralInfo.Email = s.Find("td").Text()
-						return
-					case "Contact Number (Direct) of the Principal Officer":
-						report.GeneralInfo.Contact = s.Find("td").Text()
-						return
-					case "Name of Compliance Officer":
-						report.GeneralInfo.OtherData["ComplianceOfficer"] = s.Find("td").Text()
-						return
-					case "Email ID of the Compliance Officer":
-						report.GeneralInfo.OtherData["ComplianceOfficerEmail"] = s.Find("td").Text()
-						return
-					case "No. of clients as on last day of the month":
-						tvalue, err := strconv.ParseFloat(s.Find("td").Text(), 64)
-						if err != nil {
-							log.Error().
-								Str("TotalNoOfClient", s.Find("td").Text()).
-								Str("UID", e.Request.Ctx.Get("UID")).
-								Err(err).Msg("Error during TotalNoOfClient")
-						}
-						report.GeneralInfo.TotalNoOfClient = &tvalue
-						return
-					case "Total Assets under Management (AUM) as on last day of the month (Amount in INR crores)":
-						tvalue, err := strconv.ParseFloat(s.Find("td").Text(), 64)
-						if err != nil {
-							log.Error().
-								Str("TotalAUM", s.Find("td").Text()).
-								Str("UID", e.Request.Ctx.Get("UID")).
-								Err(err).Msg("Error during TotalAUM")
-						}
-						report.GeneralInfo.TotalAUM = &tvalue
-						return
-					}
-				})
-				return false
-			}
-			return true
-		})
+		if e.Request.Ctx.Get("expired") != "" {
+			return
+		}
+		parseReport(e.DOM, reportOf(e.Request.Ctx))
 	})
 
-	//E. Performance Data
-	p.collector.OnHTML("#main-content", func(e *colly.HTMLElement) {
-		report := p.GetReport(e.Request.Ctx)
-		var returnskey []string
-		var turnOverkey []string
-		singleTableFlow := false
-
-		e.DOM.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
-			if strings.Contains(s.Text(), "E. Performance Data") {
-				thead := s.Parent().Parent().Next().Find("thead")
-
-				var returnsKeyLen int64
-				var returnSkipKeyLen int64
-				var turnOverKeyLen int64
-				if len(thead.Children().Nodes) == 2 {
-					singleTableFlow = len(thead.Find("tr").First().Children().Nodes) != 2
-					thead.Find("tr").First().Find("th").Each(func(i int, s *goquery.Selection) {
-						if singleTableFlow {
-
-							if s.Text() == "Returns(%)" {
-								returnsKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
-							}
-							if s.Text() == "Portfolio Turnover Ratio" {
-								turnOverKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
-							}
-						} else {
-							if s.Text() == "TWRR Returns (%)" {
-								returnsKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
-							} else {
-								returnSkipKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
-							}
-						}
-					})
+	p.collector.OnScraped(func(r *colly.Response) {
+		if r.Ctx.Get("expired") != "" {
+			p.retryWithNewSession(r)
+			return
+		}
+		log.Info().Int("Status", r.StatusCode).
+			Str("year", r.Ctx.Get("year")).
+			Str("month", r.Ctx.Get("month")).
+			Str("UID", r.Ctx.Get("UID")).
+			Msg("Request scraped successfully")
+		p.send(r.Ctx, reportOf(r.Ctx), nil)
+	})
 
-					thead.Find("tr").Last().Find("th").Each(func(i int, s *goquery.Selection) {
-						if returnSkipKeyLen > 0 {
-							returnSkipKeyLen--
-							return
-						}
-						if returnsKeyLen > 0 {
-							returnskey = append(returnskey, jsonKey(s.Text()))
-							returnsKeyLen--
-						}
-						if returnsKeyLen == 0 && turnOverKeyLen > 0 {
-							turnOverkey = append(turnOverkey, s.Text())
-							turnOverKeyLen--
-						}
-					})
+	p.collector.OnError(func(r *colly.Response, err error) {
+		log.Error().Int("Status", r.StatusCode).
+			Str("year", r.Ctx.Get("year")).
+			Str("month", r.Ctx.Get("month")).
+			Str("UID", r.Ctx.Get("UID")).
+			Err(err).Msg("Error during colly request")
+		p.send(r.Ctx, nil, err)
+	})
+}
+
+func (p *PMFCrawler) send(ctx *colly.Context, report *Report, err error) {
+	p.results <- &crawlResult{
+		UID:    ctx.Get("UID"),
+		year:   ctx.Get("year"),
+		month:  ctx.Get("month"),
+		report: report,
+		err:    err,
+	}
+}
+
+// registerSessionCheck marks the responses that are SEBI error pages instead of
+// reports, and expires the session that made them.
+func (p *PMFCrawler) registerSessionCheck() {
+	p.collector.OnResponse(func(r *colly.Response) {
+		if !isSessionError(r.Body) {
+			return
+		}
+		log.Warn().Str("UID", r.Ctx.Get("UID")).Msg("SEBI session expired")
+		sebiSession.Expire(r.Ctx.Get("token"))
+		r.Ctx.Put("expired", "true")
+	})
+}
+
+// retryWithNewSession sends a request that failed on an expired session once more,
+// with the token of a new session.
+func (p *PMFCrawler) retryWithNewSession(r *colly.Response) {
+	if r.Ctx.Get("retried") != "" {
+		p.send(r.Ctx, nil, errors.New("SEBI session expired again after a retry"))
+		return
+	}
+	year, _ := strconv.Atoi(r.Ctx.Get("year"))
+	month, _ := strconv.Atoi(r.Ctx.Get("month"))
+	req := CreateRequest(r.Ctx.Get("UID"), year, month)
+	req.Ctx.Put("retried", "true")
+	if err := p.collector.Request(req.Method, req.URL.String(), req.Body, req.Ctx, *req.Headers); err != nil {
+		log.Error().Err(err).Str("UID", r.Ctx.Get("UID")).Msg("Error while retrying request with a new session")
+		p.send(r.Ctx, nil, err)
+	}
+}
+
+// parseReport fills report from the #main-content element of a SEBI report page.
+func parseReport(dom *goquery.Selection, report *Report) {
+	parseGeneralInformation(dom, report)
+	parsePerformance(dom, report)
+	parseComplaints(dom, report)
+}
+
+// parseGeneralInformation reads the fund house details of the report.
+func parseGeneralInformation(dom *goquery.Selection, report *Report) {
+	dom.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if strings.Contains(s.Text(), "General Information") {
+			s.Parent().Parent().Parent().Find("table").Find("tr").Each(func(i int, s *goquery.Selection) {
+				switch s.Find("th").Text() {
+				case "Name of the Portfolio Manager":
+					report.GeneralInfo.RegistrationName = s.Find("td").Text()
+					return
+				case "Registration Number":
+					report.GeneralInfo.RegisterNumber = s.Find("td").Text()
+					return
+				case "Date of Registration":
+					target, err := time.Parse("2006-01-02", s.Find("td").Text())
+					if err != nil {
+						log.Error().
+							Str("date", s.Find("td").Text()).
+							Str("UID", report.GeneralInfo.UID).
+							Err(err).Msg("Error during Date of Registration")
+					}
+					report.GeneralInfo.RegisteredDate = &target
+					return
+				case "Registered Address of the Portfolio Manager":
+					report.GeneralInfo.Address = s.Find("td").Text()
+					return
+				case "Name of Principal Officer":
+					report.GeneralInfo.Name = s.Find("td").Text()
+					return
+				case "Email ID of the Principal Officer":
+					report.GeneralInfo.Email = s.Find("td").Text()
+					return
+				case "Contact Number (Direct) of the Principal Officer":
+					report.GeneralInfo.Contact = s.Find("td").Text()
+					return
+				case "Name of Compliance Officer":
+					report.GeneralInfo.OtherData["ComplianceOfficer"] = s.Find("td").Text()
+					return
+				case "Email ID of the Compliance Officer":
+					report.GeneralInfo.OtherData["ComplianceOfficerEmail"] = s.Find("td").Text()
+					return
+				case "No. of clients as on last day of the month":
+					tvalue, err := strconv.ParseFloat(s.Find("td").Text(), 64)
+					if err != nil {
+						log.Error().
+							Str("TotalNoOfClient", s.Find("td").Text()).
+							Str("UID", report.GeneralInfo.UID).
+							Err(err).Msg("Error during TotalNoOfClient")
+					}
+					report.GeneralInfo.TotalNoOfClient = &tvalue
+					return
+				case "Total Assets under Management (AUM) as on last day of the month (Amount in INR crores)":
+					tvalue, err := strconv.ParseFloat(s.Find("td").Text(), 64)
+					if err != nil {
+						log.Error().
+							Str("TotalAUM", s.Find("td").Text()).
+							Str("UID", report.GeneralInfo.UID).
+							Err(err).Msg("Error during TotalAUM")
+					}
+					report.GeneralInfo.TotalAUM = &tvalue
+					return
 				}
+			})
+			return false
+		}
+		return true
+	})
+}
 
-				if !singleTableFlow {
-					var fundLen int64
-					Strategy := ""
-					s.Parent().Parent().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
-						td := s.Children().First()
-						if fundLen == 0 {
-							Strategy = td.Text()
-							fundLen, _ = strconv.ParseInt(td.AttrOr("rowspan", "0"), 10, 64)
-							fundLen--
-							return
+// parsePerformance reads the AUM, returns and turnover of each service of the report.
+func parsePerformance(dom *goquery.Selection, report *Report) {
+	var returnskey []string
+	var turnOverkey []string
+	singleTableFlow := false
+
+	dom.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if strings.Contains(s.Text(), "E. Performance Data") {
+			thead := s.Parent().Parent().Next().Find("thead")
+
+			var returnsKeyLen int64
+			var returnSkipKeyLen int64
+			var turnOverKeyLen int64
+			if len(thead.Children().Nodes) == 2 {
+				singleTableFlow = len(thead.Find("tr").First().Children().Nodes) != 2
+				thead.Find("tr").First().Find("th").Each(func(i int, s *goquery.Selection) {
+					if singleTableFlow {
+
+						if s.Text() == "Returns(%)" {
+							returnsKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
 						}
-						if fundLen > 0 {
-							parseReturnsData(td, Strategy, returnskey, report)
-							fundLen--
+						if s.Text() == "Portfolio Turnover Ratio" {
+							turnOverKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
 						}
-
-					})
-
-					turnOverTh := s.Parent().Parent().Next().Next().Find("thead").Find("tr").Last()
-					turnOverTh.Children().Each(func(i int, s *goquery.Selection) {
-						if s.Text() == "Investment Approach" {
-							return
+					} else {
+						if s.Text() == "TWRR Returns (%)" {
+							returnsKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
+						} else {
+							returnSkipKeyLen, _ = strconv.ParseInt(s.AttrOr("colspan", "0"), 10, 64)
 						}
+					}
+				})
 
+				thead.Find("tr").Last().Find("th").Each(func(i int, s *goquery.Selection) {
+					if returnSkipKeyLen > 0 {
+						returnSkipKeyLen--
+						return
+					}
+					if returnsKeyLen > 0 {
+						returnskey = append(returnskey, jsonKey(s.Text()))
+						returnsKeyLen--
+					}
+					if returnsKeyLen == 0 && turnOverKeyLen > 0 {
 						turnOverkey = append(turnOverkey, s.Text())
-					})
-					if len(turnOverkey) != 0 {
-						s.Parent().Parent().Next().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
-							ds := report.FindServiceByFundName(s.Find("td").First().Text())
-							node := s.Find("td").First()
-
-							for _, period := range turnOverkey {
-								node = node.Next()
-								var err error
-								ds.TurnOverData[period], err = strconv.ParseFloat(node.Text(), 64)
-								if err != nil {
-									fmt.Printf("Error during TurnOverData Convert For fund %s %v\n", ds.FundName, err)
-								}
-							}
+						turnOverKeyLen--
+					}
+				})
+			}
 
-						})
+			if !singleTableFlow {
+				var fundLen int64
+				Strategy := ""
+				s.Parent().Parent().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
+					td := s.Children().First()
+					if fundLen == 0 {
+						Strategy = td.Text()
+						fundLen, _ = strconv.ParseInt(td.AttrOr("rowspan", "0"), 10, 64)
+						fundLen--
+						return
 					}
-				}
+					if fundLen > 0 {
+						parseReturnsData(td, Strategy, returnskey, report)
+						fundLen--
+					}
+
+				})
 
-				if singleTableFlow {
-					// single table handling
-					s.Parent().Parent().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
-						td := s.Children().First()
-						Strategy := ""
-						ds := parseReturnsData(td, Strategy, returnskey, report)
-
-						if ds != nil {
-							for _, period := range turnOverkey {
-								td = td.Next()
-								var err error
-								ds.TurnOverData[period], err = strconv.ParseFloat(td.Text(), 64)
-								if err != nil {
-									fmt.Printf("Error during TurnOverData convert: %v\n", err)
-								}
+				turnOverTh := s.Parent().Parent().Next().Next().Find("thead").Find("tr").Last()
+				turnOverTh.Children().Each(func(i int, s *goquery.Selection) {
+					if s.Text() == "Investment Approach" {
+						return
+					}
+
+					turnOverkey = append(turnOverkey, s.Text())
+				})
+				if len(turnOverkey) != 0 {
+					s.Parent().Parent().Next().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
+						ds := report.FindServiceByFundName(s.Find("td").First().Text())
+						node := s.Find("td").First()
+
+						for _, period := range turnOverkey {
+							node = node.Next()
+							var err error
+							ds.TurnOverData[period], err = strconv.ParseFloat(node.Text(), 64)
+							if err != nil {
+								fmt.Printf("Error during TurnOverData Convert For fund %s %v\n", ds.FundName, err)
 							}
 						}
+
 					})
 				}
-				return false
-
 			}
-			return true
-		})
-	})
 
-	p.collector.OnHTML("#main-content", func(e *colly.HTMLElement) {
-		report := p.GetReport(e.Request.Ctx)
-		e.DOM.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
-			if strings.Contains(s.Text(), "Data on Complaints") {
-				complaints := report.Complaints
-				row := s.Parent().Parent().Next().Find("tbody").Find("tr").Last()
-				row.Find("td").Children().Each(func(i int, s *goquery.Selection) {
-					switch i {
-					case 1:
-						complaints.PendingMonthStart, _ = strconv.ParseFloat(s.Text(), 64)
-					case 2:
-						complaints.ReceivedDuringMonth, _ = strconv.ParseFloat(s.Text(), 64)
-					case 3:
-						complaints.ResolvedDuringMonth, _ = strconv.ParseFloat(s.Text(), 64)
-					case 4:
-						complaints.PendingMonthEnd, _ = strconv.ParseFloat(s.Text(), 64)
+			if singleTableFlow {
+				// single table handling
+				s.Parent().Parent().Next().Find("tbody").Find("tr").Each(func(i int, s *goquery.Selection) {
+					td := s.Children().First()
+					Strategy := ""
+					ds := parseReturnsData(td, Strategy, returnskey, report)
+
+					if ds != nil {
+						for _, period := range turnOverkey {
+							td = td.Next()
+							var err error
+							ds.TurnOverData[period], err = strconv.ParseFloat(td.Text(), 64)
+							if err != nil {
+								fmt.Printf("Error during TurnOverData convert: %v\n", err)
+							}
+						}
 					}
-
 				})
-
-				return false
 			}
-			return true
-		})
-	})
+			return false
 
-	p.collector.OnScraped(func(r *colly.Response) {
-		if val, ok := p.fundManagerVsReport.Load(r.Ctx.Get("UID")); ok {
-			cb(reportToFundConverter(val))
 		}
-		log.Info().Int("Status", r.StatusCode).
-			Str("year", r.Ctx.Get("year")).
-			Str("month", r.Ctx.Get("month")).
-			Str("UID", r.Ctx.Get("UID")).
-			Msg("Request scraped successfully")
-
-		p.fundManagerVsReport.Delete(r.Ctx.Get("UID"))
+		return true
 	})
-
-	// p.queue.AddRequest(CreateRequest(UID, forDate.Year(), int(forDate.Month())))
-
 }
 
-func (p *PMFCrawler) registerCrawlerError() {
-	p.collector.OnError(func(r *colly.Response, err error) {
-		log.Error().Int("Status", r.StatusCode).
-			Str("year", r.Ctx.Get("year")).
-			Str("month", r.Ctx.Get("month")).
-			Str("UID", r.Ctx.Get("UID")).
-			Err(err).Msg("Error during colly request")
+// parseComplaints reads the complaints of the month.
+func parseComplaints(dom *goquery.Selection, report *Report) {
+	dom.Find("strong").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if strings.Contains(s.Text(), "Data on Complaints") {
+			complaints := report.Complaints
+			row := s.Parent().Parent().Next().Find("tbody").Find("tr").Last()
+			row.Find("td").Children().Each(func(i int, s *goquery.Selection) {
+				switch i {
+				case 1:
+					complaints.PendingMonthStart, _ = strconv.ParseFloat(s.Text(), 64)
+				case 2:
+					complaints.ReceivedDuringMonth, _ = strconv.ParseFloat(s.Text(), 64)
+				case 3:
+					complaints.ResolvedDuringMonth, _ = strconv.ParseFloat(s.Text(), 64)
+				case 4:
+					complaints.PendingMonthEnd, _ = strconv.ParseFloat(s.Text(), 64)
+				}
 
-		p.err = err
-		db := crawler.Conn()
-		err = db.Create(&crawler.CrawlerEvent{
-			Data: map[string]string{
-				"year":  r.Ctx.Get("year"),
-				"month": r.Ctx.Get("month"),
-				"UID":   r.Ctx.Get("UID"),
-				"error": err.Error(),
-				"time":  time.Now().String(),
-			},
-		}).Error
+			})
 
-		if err != nil {
-			log.Error().Err(err).
-				Str("year", r.Ctx.Get("year")).
-				Str("month", r.Ctx.Get("month")).
-				Str("UID", r.Ctx.Get("UID")).
-				Err(err).Msg("Create CrawlerEvent Failed")
+			return false
 		}
+		return true
 	})
 }
 
@@ -407,63 +515,58 @@ func IsIndexName(FundName string) bool {
 	return false
 }
 
-func (p *PMFCrawler) GetFundManager(UID string) *crawler.FundManager {
-	for _, fm := range p.fundManagers {
-		if fm.OtherData["UID"] == UID {
-			return fm
-		}
+// fundHouse returns a copy of the fund house with the given UID, looked up once per
+// crawl. A fund house not stored yet is returned with only its UID.
+func (p *PMFCrawler) fundHouse(UID string) *crawler.FundManager {
+	fm, _ := p.fundHouses.LoadOrCompute(UID, func() *crawler.FundManager {
+		return loadFundHouse(p.ctx, p.store, UID)
+	})
+
+	fundHouse := *fm
+	fundHouse.OtherData = maps.Clone(fm.OtherData)
+	if fundHouse.OtherData == nil {
+		fundHouse.OtherData = make(map[string]string)
 	}
+	if fundHouse.Funds == nil {
+		fundHouse.Funds = make([]*crawler.Fund, 0)
+	}
+	return &fundHouse
+}
 
-	fmm := &crawler.FundManager{}
-	err := crawler.Conn().Model(&crawler.FundManager{}).Where("other_data->>'UID' = ?", UID).Preload("Funds").First(fmm).Error
+// loadFundHouse returns the stored fund house with the given UID, or a new one with
+// only its UID.
+func loadFundHouse(ctx context.Context, st *store.Store, UID string) *crawler.FundManager {
+	fundHouse, err := st.FundHouses.GetByUID(ctx, UID)
 	if err != nil {
-		log.Error().Err(err).Str("UID", UID).Msg("Error while fetching FundManager")
-	} else if fmm.OtherData != nil && fmm.OtherData["UID"] == UID {
-		p.fundManagers = append(p.fundManagers, fmm)
-		if fmm.Funds == nil {
-			fmm.Funds = make([]*crawler.Fund, 0)
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Error().Err(err).Str("UID", UID).Msg("Error while fetching FundManager")
 		}
-		return fmm
+		return &crawler.FundManager{UID: UID}
 	}
-
-	fm := &crawler.FundManager{
-		OtherData: make(map[string]string),
-		Funds:     make([]*crawler.Fund, 0),
-	}
-	fm.OtherData["UID"] = UID
-
-	p.fundManagers = append(p.fundManagers, fm)
-	return fm
+	return fundHouse
 }
 
-func (p *PMFCrawler) GetReport(ctx *colly.Context) *Report {
-	UID := ctx.Get("UID")
+// newReport returns the empty report a request fills in.
+func (p *PMFCrawler) newReport(ctx *colly.Context) *Report {
 	year, _ := strconv.Atoi(ctx.Get("year"))
 	month, _ := strconv.Atoi(ctx.Get("month"))
-	reports, _ := p.fundManagerVsReport.Load(UID)
-
-	for _, report := range reports {
-		if report.Month == month && report.Year == year {
-			return report
-		}
-	}
-	report := &Report{
+	return &Report{
 		Year:        year,
 		Month:       month,
-		GeneralInfo: p.GetFundManager(UID),
+		GeneralInfo: p.fundHouse(ctx.Get("UID")),
 		Services:    make([]*DiscretionaryService, 0),
 		Complaints:  &Complaints{},
 	}
+}
 
-	reports = append(reports, report)
-	p.fundManagerVsReport.Store(UID, reports)
+// reportOf returns the report of the request with the given context.
+func reportOf(ctx *colly.Context) *Report {
+	report, _ := ctx.GetAny("report").(*Report)
 	return report
 }
 
 func CrawlFundManagarIDs() []string {
-	c := colly.NewCollector(colly.Debugger(&crawler.LogDebugger{}))
-	extensions.RandomUserAgent(c)
-	extensions.Referer(c)
+	c := crawler.NewCollector()
 
 	var portfolioManagerIDs []string
 	// portfolio managers
@@ -488,14 +591,59 @@ func CrawlFundManagarIDs() []string {
 			Err(err).Msg("Error during colly request")
 	})
 
-	c.Visit("https://www.sebi.gov.in/sebiweb/other/OtherAction.do?doPmr=yes")
+	c.SetCookieJar(sebiSession.Jar())
+	c.Visit(sebiPMRURL)
 	c.Wait()
 	return portfolioManagerIDs
 }
 
+const sebiPMRURL = "https://www.sebi.gov.in/sebiweb/other/OtherAction.do?doPmr=yes"
+
+// sebiSession is shared by every request to SEBI. Its Struts token is sent with the
+// report form.
+var sebiSession = crawler.NewSession(sebiPMRURL, "org.apache.struts.taglib.html.TOKEN", 20*time.Minute)
+
+// sessionErrorMarkers are found on the pages SEBI serves instead of a report when
+// the session or its token is no longer valid.
+var sessionErrorMarkers = []string{"session has expired", "session expired", "invalid session", "invalid token"}
+
+func isSessionError(body []byte) bool {
+	page := strings.ToLower(string(body))
+	for _, marker := range sessionErrorMarkers {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 func CreateRequest(UID string, year, month int) *colly.Request {
-	url_, _ := url.Parse("https://www.sebi.gov.in/sebiweb/other/OtherAction.do?doPmr=yes")
-	// payload := fmt.Sprintf("currdate=&loginflag=0&searchValue=&pmrId=%s&year=%d&month=%d&org.apache.struts.taglib.html.TOKEN=...&loginEmail=&loginPassword=&cap_login=&moduleNo=-1&moduleId=&link=&yourName=&friendName=&friendEmail=&mailmessage=&cap_email=", url.PathEscape(UID), year, month)
+	url_, _ := url.Parse(sebiPMRURL)
+	token, err := sebiSession.Token(context.Background())
+	if err != nil {
+		log.Error().Err(err).Str("UID", UID).Msg("Error while starting SEBI session")
+	}
+
+	req := &colly.Request{
+		URL:     url_,
+		Ctx:     colly.NewContext(),
+		Method:  "POST",
+		Headers: &http.Header{},
+		Body:    strings.NewReader(reportForm(UID, year, month, token).Encode()),
+	}
+
+	req.Ctx.Put("UID", UID)
+	req.Ctx.Put("year", strconv.Itoa(year))
+	req.Ctx.Put("month", strconv.Itoa(month))
+	req.Ctx.Put("token", token)
+	addReportHeaders(*req.Headers)
+
+	return req
+}
+
+// reportForm returns the form SEBI's PMR page is posted with to show the report of a
+// portfolio manager for a month.
+func reportForm(UID string, year, month int, token string) url.Values {
 	params := url.Values{}
 	params.Add("currdate", "")
 	params.Add("loginflag", "0")
@@ -503,7 +651,7 @@ func CreateRequest(UID string, year, month int) *colly.Request {
 	params.Add("pmrId", UID)
 	params.Add("year", strconv.FormatInt(int64(year), 10))
 	params.Add("month", strconv.FormatInt(int64(month), 10))
-	params.Add("org.apache.struts.taglib.html.TOKEN", "...")
+	params.Add("org.apache.struts.taglib.html.TOKEN", token)
 	params.Add("loginEmail", "")
 	params.Add("cap_login", "")
 	params.Add("moduleNo", "-1")
@@ -514,37 +662,24 @@ func CreateRequest(UID string, year, month int) *colly.Request {
 	params.Add("friendEmail", "")
 	params.Add("mailmessage", "")
 	params.Add("cap_email", "")
-	params.Encode()
-
-	req := &colly.Request{
-		URL:     url_,
-		Ctx:     colly.NewContext(),
-		Method:  "POST",
-		Headers: &http.Header{},
-		Body:    strings.NewReader(params.Encode()),
-	}
-
-	req.Ctx.Put("UID", UID)
-	req.Ctx.Put("year", strconv.Itoa(year))
-	req.Ctx.Put("month", strconv.Itoa(month))
-
-	req.Headers.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:135.0) Gecko/20100101 Firefox/135.0")
-	req.Headers.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Headers.Add("Accept-Language", "en-US,en;q=0.5")
-	req.Headers.Add("Accept-Encoding", "gzip, deflate, br, zstd")
-	req.Headers.Add("Referer", "https://www.sebi.gov.in/sebiweb/other/OtherAction.do?doPmr=yes")
-	req.Headers.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Headers.Add("Origin", "https://www.sebi.gov.in")
-	req.Headers.Add("Connection", "keep-alive")
-	req.Headers.Add("Upgrade-Insecure-Requests", "1")
-	req.Headers.Add("Sec-Fetch-Dest", "document")
-	req.Headers.Add("Sec-Fetch-Mode", "navigate")
-	req.Headers.Add("Sec-Fetch-Site", "same-origin")
-	req.Headers.Add("Sec-Fetch-User", "?1")
-	req.Headers.Add("Priority", "u=0, i")
-	req.Headers.Add("Cookie", "JSESSIONID=BA0846513788A51696A1C56A5F37A38E")
+	return params
+}
 
-	return req
+func addReportHeaders(headers http.Header) {
+	headers.Add("User-Agent", crawler.UserAgent)
+	headers.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	headers.Add("Accept-Language", "en-US,en;q=0.5")
+	headers.Add("Accept-Encoding", "gzip, deflate, br, zstd")
+	headers.Add("Referer", "https://www.sebi.gov.in/sebiweb/other/OtherAction.do?doPmr=yes")
+	headers.Add("Content-Type", "application/x-www-form-urlencoded")
+	headers.Add("Origin", "https://www.sebi.gov.in")
+	headers.Add("Connection", "keep-alive")
+	headers.Add("Upgrade-Insecure-Requests", "1")
+	headers.Add("Sec-Fetch-Dest", "document")
+	headers.Add("Sec-Fetch-Mode", "navigate")
+	headers.Add("Sec-Fetch-Site", "same-origin")
+	headers.Add("Sec-Fetch-User", "?1")
+	headers.Add("Priority", "u=0, i")
 }
 
 func reportToFundConverter(reports []*Report) []*crawler.Fund {
@@ -556,6 +691,7 @@ func reportToFundConverter(reports []*Report) []*crawler.Fund {
 			reportDate, _ := time.Parse("2006-01-02", fmt.Sprintf("%04d-%02d-01", report.Year, report.Month))
 			fundReport := &crawler.FundReport{
 				ReportDate: &reportDate,
+				Source:     sourceName,
 				OtherData:  make(map[string]string),
 			}
 			if _, ok := service.ReturnsData["1 month"]; ok {
@@ -599,8 +735,9 @@ func reportToFundConverter(reports []*Report) []*crawler.Fund {
 			if service.Strategy == "" {
 				service.Strategy = "Equity"
 			}
-			fundReport.OtherData["Strategy"] = service.Strategy
-			fundReport.OtherData["AUM"] = strconv.FormatFloat(service.AUM, 'f', 2, 64)
+			aum := math.Round(service.AUM*100) / 100
+			fundReport.Strategy = service.Strategy
+			fundReport.AUM = &aum
 
 			fund := &crawler.Fund{
 				ID:           service.ID,
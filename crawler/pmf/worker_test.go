@@ -2,17 +2,107 @@ package pmf
 
 import (
 	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"alpha2/store"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
-	"time"
+	"text/template"
+
+	"github.com/gocolly/colly/v2/queue"
 )
 
-func TestCrawlFund(t *testing.T) {
-	crawle := PMFCrawler{}
-	forDate, _ := time.Parse("2006-01-02", "2025-01-01")
-	crawle.CrawlAllFund(&forDate, func(res []*crawler.Fund) {
-		if len(res) == 0 {
-			t.Error("CrawlFund should not return empty array")
-			return
-		}
+// sebiFixture serves the landing page and, for report requests, the report fixture
+// filled with the requested UID and month, or a 500 for the UID "broken".
+type sebiFixture struct {
+	report *template.Template
+}
+
+func (f *sebiFixture) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "text/html")
+	if req.Method == http.MethodGet {
+		fmt.Fprint(w, `<form><input type="hidden" name="org.apache.struts.taglib.html.TOKEN" value="t1"></form>`)
+		return w.Result(), nil
+	}
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	if req.PostForm.Get("pmrId") == "broken" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return w.Result(), nil
+	}
+	err := f.report.Execute(w, map[string]string{
+		"UID":   req.PostForm.Get("pmrId"),
+		"Year":  req.PostForm.Get("year"),
+		"Month": req.PostForm.Get("month"),
 	})
+	return w.Result(), err
+}
+
+func TestCrawlReportsConcurrently(t *testing.T) {
+	fixture := &sebiFixture{report: template.Must(template.ParseFiles("testdata/report.html"))}
+	sebiSession.WithTransport(fixture)
+	p := NewPMFCrawler(context.Background(), store.New(migratetest.Open(t)))
+	p.collector.WithTransport(fixture)
+
+	q, _ := queue.New(crawlThreads, nil)
+	uids := []string{"PM1", "PM2", "PM3", "PM4", "PM5"}
+	for _, uid := range append(uids, "broken") {
+		for month := 1; month <= 12; month++ {
+			q.AddRequest(CreateRequest(uid, 2024, month))
+		}
+	}
+
+	// cb is only called from one goroutine, so it needs no lock.
+	funds := make(map[string]*crawler.Fund)
+	err := p.run(q, p.save(func(crawled []*crawler.Fund) {
+		for _, fund := range crawled {
+			report := fund.FundReports[0]
+			funds[fmt.Sprintf("%s-%d", fund.Name, report.ReportDate.Month())] = fund
+		}
+	}))
+	if err == nil {
+		t.Error("run() returned no error with failing requests")
+	}
+
+	if len(funds) != len(uids)*12 {
+		t.Fatalf("crawled %d fund reports, want %d", len(funds), len(uids)*12)
+	}
+	for _, uid := range uids {
+		for month := 1; month <= 12; month++ {
+			fund := funds[fmt.Sprintf("Fund %s-%d", uid, month)]
+			if fund == nil {
+				t.Fatalf("no report of %s for month %d", uid, month)
+			}
+			fundHouse := fund.FundManagers[0]
+			if fundHouse.UID != uid || fundHouse.Name != "Officer "+uid || *fundHouse.TotalNoOfClient != float64(month) {
+				t.Errorf("fund house of %s for month %d = %+v", uid, month, fundHouse)
+			}
+			if fundHouse.OtherData["ComplianceOfficer"] != "Compliance "+uid {
+				t.Errorf("compliance officer of %s = %q", uid, fundHouse.OtherData["ComplianceOfficer"])
+			}
+			report := fund.FundReports[0]
+			if *report.AUM != float64(month)+0.5 || *report.Month1Returns != 2024 || *report.Yr1Returns != float64(month) {
+				t.Errorf("report of %s for month %d = AUM %v, returns %v %v", uid, month,
+					*report.AUM, *report.Month1Returns, *report.Yr1Returns)
+			}
+		}
+	}
+
+	failures, err := p.store.Events.FailedPMFRequests(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 12 {
+		t.Errorf("recorded %d failed requests, want 12", len(failures))
+	}
+	for _, failure := range failures {
+		if month, _ := strconv.Atoi(failure.Data["month"]); failure.Data["UID"] != "broken" || month < 1 || month > 12 {
+			t.Errorf("recorded failure %v, want the broken requests", failure.Data)
+		}
+	}
 }
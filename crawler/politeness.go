@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v3"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// HostPolicy limits the requests sent to one host. Zero fields take the value of
+// the default policy.
+type HostPolicy struct {
+	Host        string
+	Concurrency int           // Requests in flight at once
+	Delay       time.Duration // Least time between the start of two requests
+	Jitter      time.Duration // Random extra delay, up to this long
+	Backoff     time.Duration // Pause after the first 429 or 5xx response, doubled on each further one
+	MaxBackoff  time.Duration
+}
+
+// DefaultPolicy applies to hosts without a policy of their own.
+var DefaultPolicy = HostPolicy{
+	Concurrency: 4,
+	Delay:       500 * time.Millisecond,
+	Jitter:      500 * time.Millisecond,
+	Backoff:     5 * time.Second,
+	MaxBackoff:  5 * time.Minute,
+}
+
+func (p HostPolicy) withDefaults(d HostPolicy) HostPolicy {
+	if p.Concurrency <= 0 {
+		p.Concurrency = d.Concurrency
+	}
+	if p.Delay == 0 {
+		p.Delay = d.Delay
+	}
+	if p.Jitter == 0 {
+		p.Jitter = d.Jitter
+	}
+	if p.Backoff == 0 {
+		p.Backoff = d.Backoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = d.MaxBackoff
+	}
+	return p
+}
+
+// PoliteTransport is an http.RoundTripper that holds the requests to each host to
+// its HostPolicy. Sharing one transport shares the limits.
+type PoliteTransport struct {
+	transport http.RoundTripper
+	fallback  HostPolicy
+	policies  map[string]HostPolicy
+	limiters  *xsync.MapOf[string, *hostLimiter]
+}
+
+// NewPoliteTransport returns a transport sending requests through transport, limited
+// by the policy of their host or by fallback.
+func NewPoliteTransport(transport http.RoundTripper, fallback HostPolicy, policies []HostPolicy) *PoliteTransport {
+	fallback = fallback.withDefaults(DefaultPolicy)
+	byHost := make(map[string]HostPolicy, len(policies))
+	for _, policy := range policies {
+		byHost[policy.Host] = policy.withDefaults(fallback)
+	}
+	return &PoliteTransport{
+		transport: transport,
+		fallback:  fallback,
+		policies:  byHost,
+		limiters:  xsync.NewMapOf[string, *hostLimiter](),
+	}
+}
+
+var (
+	politeness     *PoliteTransport
+	politenessOnce sync.Once
+)
+
+// Politeness returns the transport shared by every crawler in the process. On first
+// use it reads crawler.politeness from viper: default overrides DefaultPolicy and
+// hosts lists the policies of single hosts, each with a host and the fields to
+// override.
+func Politeness() *PoliteTransport {
+	politenessOnce.Do(func() {
+		fallback := DefaultPolicy
+		if err := viper.UnmarshalKey("crawler.politeness.default", &fallback); err != nil {
+			log.Error().Err(err).Msg("Error reading crawler.politeness.default")
+		}
+		var hosts []HostPolicy
+		if err := viper.UnmarshalKey("crawler.politeness.hosts", &hosts); err != nil {
+			log.Error().Err(err).Msg("Error reading crawler.politeness.hosts")
+		}
+		politeness = NewPoliteTransport(http.DefaultTransport, fallback, hosts)
+	})
+	return politeness
+}
+
+func (t *PoliteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	limiter, _ := t.limiters.LoadOrCompute(host, func() *hostLimiter {
+		policy, ok := t.policies[host]
+		if !ok {
+			policy = t.fallback
+		}
+		return &hostLimiter{policy: policy, slots: make(chan struct{}, policy.Concurrency)}
+	})
+
+	if err := limiter.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.transport.RoundTrip(req)
+	limiter.release(resp)
+	return resp, err
+}
+
+// hostLimiter spaces the requests to one host and bounds how many are in flight.
+type hostLimiter struct {
+	policy HostPolicy
+	slots  chan struct{}
+
+	mtx     sync.Mutex
+	next    time.Time     // Earliest start of the next request
+	backoff time.Duration // Current backoff, zero while the host responds well
+}
+
+// acquire waits for a free slot and for the request's turn.
+func (l *hostLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mtx.Lock()
+	start := time.Now()
+	if l.next.After(start) {
+		start = l.next
+	}
+	gap := l.policy.Delay
+	if l.policy.Jitter > 0 {
+		gap += rand.N(l.policy.Jitter)
+	}
+	l.next = start.Add(gap)
+	l.mtx.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		<-l.slots
+		return ctx.Err()
+	}
+}
+
+// release frees the slot of a finished request and backs off if the host refused it
+// or failed.
+func (l *hostLimiter) release(resp *http.Response) {
+	defer func() { <-l.slots }()
+	if resp == nil {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		l.backoff = 0
+		return
+	}
+
+	l.backoff = min(max(2*l.backoff, l.policy.Backoff), l.policy.MaxBackoff)
+	pause := l.backoff
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		pause = min(max(pause, time.Duration(seconds)*time.Second), l.policy.MaxBackoff)
+	}
+	if next := time.Now().Add(pause); next.After(l.next) {
+		l.next = next
+	}
+	log.Warn().Str("host", resp.Request.URL.Hostname()).Int("status", resp.StatusCode).
+		Dur("pause", pause).Msg("Backing off host")
+}
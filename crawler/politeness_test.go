@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoliteTransportLimitsHost(t *testing.T) {
+	var inFlight, most atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	policy := HostPolicy{Concurrency: 2, Delay: 20 * time.Millisecond, Jitter: time.Nanosecond}
+	client := &http.Client{Transport: NewPoliteTransport(http.DefaultTransport, policy, nil)}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if most.Load() > 2 {
+		t.Errorf("%d requests in flight, want at most 2", most.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 requests took %v, want them spaced by the delay", elapsed)
+	}
+}
+
+func TestPoliteTransportBacksOff(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusTooManyRequests)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	policy := HostPolicy{Delay: time.Nanosecond, Jitter: time.Nanosecond, Backoff: 50 * time.Millisecond}
+	client := &http.Client{Transport: NewPoliteTransport(http.DefaultTransport, policy, nil)}
+	get := func() time.Duration {
+		t.Helper()
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return time.Since(start)
+	}
+
+	get()
+	status.Store(http.StatusOK)
+	if elapsed := get(); elapsed < 50*time.Millisecond {
+		t.Errorf("request after a 429 took %v, want the backoff", elapsed)
+	}
+	if elapsed := get(); elapsed > 40*time.Millisecond {
+		t.Errorf("request after a success took %v, want no backoff", elapsed)
+	}
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"strconv"
+	"time"
+)
+
+// Provenances of a FundReport.
+const (
+	// ProvenanceCrawled reports were published by a source, such as SEBI.
+	ProvenanceCrawled = "crawled"
+	// ProvenanceManual reports were uploaded by an admin and approved.
+	ProvenanceManual = "manual"
+	// ProvenanceMerged reports were copied from a fund merged into the report's fund.
+	ProvenanceMerged = "merged"
+)
+
+// A fund can have one report per month from each origin: the crawl, the manual
+// uploads and each fund merged into it. Reads only see the effective report of a
+// fund and month, the one with the highest precedence:
+//
+//  1. crawled, as the publisher's figures are authoritative;
+//  2. manual, filling months the publisher has not published;
+//  3. merged, filling months from before the fund was split or renamed.
+//
+// Among reports of equal precedence, the latest written (highest ID) is effective.
+// ResolveReports applies these rules in Go and store.EffectiveReports in SQL.
+var precedences = map[string]int{
+	ProvenanceCrawled: 3,
+	ProvenanceManual:  2,
+	ProvenanceMerged:  1,
+}
+
+// Precedence returns the precedence of reports with the provenance, 0 if unknown.
+func Precedence(provenance string) int {
+	return precedences[provenance]
+}
+
+// MergedOrigin returns the Origin of reports copied from the merged fund.
+func MergedOrigin(mergedFundID uint64) string {
+	return ProvenanceMerged + ":" + strconv.FormatUint(mergedFundID, 10)
+}
+
+// MarkMerged marks r as a copy of a report of the merged fund, so it only fills
+// months the fund it is copied to has no report of its own for.
+func (r *FundReport) MarkMerged(mergedFundID uint64) {
+	r.Provenance = ProvenanceMerged
+	r.Origin = MergedOrigin(mergedFundID)
+	r.MergedID = &mergedFundID
+	r.Priority = "low"
+}
+
+// Outranks reports whether r is effective over other, a report of the same fund
+// and month.
+func (r *FundReport) Outranks(other *FundReport) bool {
+	if p, q := Precedence(r.Provenance), Precedence(other.Provenance); p != q {
+		return p > q
+	}
+	return r.ID > other.ID
+}
+
+// ResolveReports returns the effective report of each fund and month among reports,
+// in the order they first appear.
+func ResolveReports(reports []*FundReport) []*FundReport {
+	type key struct {
+		fundID uint64
+		date   time.Time
+	}
+	effective := make(map[key]int)
+	resolved := make([]*FundReport, 0, len(reports))
+	for _, report := range reports {
+		var k key
+		if report.ReportDate != nil {
+			k = key{report.FundID, report.ReportDate.UTC()}
+		}
+		idx, ok := effective[k]
+		if !ok {
+			effective[k] = len(resolved)
+			resolved = append(resolved, report)
+			continue
+		}
+		if report.Outranks(resolved[idx]) {
+			resolved[idx] = report
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,36 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveReports(t *testing.T) {
+	may := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	merged := &FundReport{ID: 1, FundID: 1, ReportDate: &may}
+	merged.MarkMerged(9)
+	reports := []*FundReport{
+		merged,
+		{ID: 2, FundID: 1, ReportDate: &may, Provenance: ProvenanceManual, Origin: ProvenanceManual},
+		{ID: 3, FundID: 1, ReportDate: &june, Provenance: ProvenanceMerged, Origin: MergedOrigin(8)},
+		{ID: 4, FundID: 1, ReportDate: &june, Provenance: ProvenanceCrawled, Origin: ProvenanceCrawled},
+		{ID: 5, FundID: 1, ReportDate: &june, Provenance: ProvenanceMerged, Origin: MergedOrigin(9)},
+		{ID: 6, FundID: 2, ReportDate: &june, Provenance: ProvenanceMerged, Origin: MergedOrigin(9)},
+		{ID: 7, FundID: 2, ReportDate: &june, Provenance: ProvenanceMerged, Origin: MergedOrigin(8)},
+	}
+	if merged.Origin != "merged:9" || *merged.MergedID != 9 {
+		t.Errorf("MarkMerged() origin %q, merged_id %d", merged.Origin, *merged.MergedID)
+	}
+
+	resolved := ResolveReports(reports)
+	want := []uint64{2, 4, 7}
+	if len(resolved) != len(want) {
+		t.Fatalf("ResolveReports() returned %d reports, want %d", len(resolved), len(want))
+	}
+	for idx, report := range resolved {
+		if report.ID != want[idx] {
+			t.Errorf("ResolveReports()[%d] = report %d, want %d", idx, report.ID, want[idx])
+		}
+	}
+}
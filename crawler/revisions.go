@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"math"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// defaultRestatementThreshold is the change of a return, in percentage points, above
+// which a revision is a restatement when crawler.restatement_threshold is not set.
+const defaultRestatementThreshold = 1.0
+
+// ReportValues are the figures of a FundReport kept by a ReportRevision.
+type ReportValues struct {
+	AUM            *float64 `json:"aum"`
+	Month1Returns  *float64 `json:"1_month_return"`
+	Month3Returns  *float64 `json:"3_month_return"`
+	Month6Returns  *float64 `json:"6_month_return"`
+	Yr1Returns     *float64 `json:"1_year_return"`
+	Yr2Returns     *float64 `json:"2_year_return"`
+	Yr3Returns     *float64 `json:"3_year_return"`
+	Yr4Returns     *float64 `json:"4_year_return"`
+	Yr5Returns     *float64 `json:"5_year_return"`
+	OverAllReturns *float64 `json:"over_all_return"`
+	Strategy       string   `gorm:"not null;default:''" json:"strategy"`
+}
+
+// Values returns the figures of the report.
+func (r *FundReport) Values() ReportValues {
+	return ReportValues{
+		AUM:            r.AUM,
+		Month1Returns:  r.Month1Returns,
+		Month3Returns:  r.Month3Returns,
+		Month6Returns:  r.Month6Returns,
+		Yr1Returns:     r.Yr1Returns,
+		Yr2Returns:     r.Yr2Returns,
+		Yr3Returns:     r.Yr3Returns,
+		Yr4Returns:     r.Yr4Returns,
+		Yr5Returns:     r.Yr5Returns,
+		OverAllReturns: r.OverAllReturns,
+		Strategy:       r.Strategy,
+	}
+}
+
+func (v ReportValues) returns() []*float64 {
+	return []*float64{v.Month1Returns, v.Month3Returns, v.Month6Returns, v.Yr1Returns, v.Yr2Returns,
+		v.Yr3Returns, v.Yr4Returns, v.Yr5Returns, v.OverAllReturns}
+}
+
+// ReportRevision is a change to a saved FundReport, such as SEBI restating a past
+// month, with the figures before and after it.
+type ReportRevision struct {
+	ID           uint64    `json:"id"`
+	FundReportID uint64    `gorm:"index" json:"fund_report_id"`
+	FundID       uint64    `gorm:"index" json:"fund_id"`
+	ReportDate   time.Time `json:"report_date"`
+	Origin       string    `gorm:"not null;default:''" json:"origin"`
+
+	Previous ReportValues `gorm:"embedded;embeddedPrefix:previous_" json:"previous"`
+	Current  ReportValues `gorm:"embedded;embeddedPrefix:current_" json:"current"`
+
+	// CrawlRunID and UploadID are those of the report after the change.
+	CrawlRunID *uint   `json:"crawl_run_id"`
+	UploadID   *uint64 `json:"upload_id"`
+
+	// ReturnsChange is the largest change of a return present before and after, in
+	// percentage points.
+	ReturnsChange float64 `gorm:"not null;default:0" json:"returns_change"`
+	// Restatement is set when ReturnsChange is above RestatementThreshold.
+	Restatement bool `gorm:"not null;default:false;index" json:"restatement"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestatementThreshold returns crawler.restatement_threshold from the config, the
+// change of a return in percentage points above which a revision is a restatement.
+func RestatementThreshold() float64 {
+	if viper.IsSet("crawler.restatement_threshold") {
+		return viper.GetFloat64("crawler.restatement_threshold")
+	}
+	return defaultRestatementThreshold
+}
+
+// NewReportRevision returns the revision replacing the saved report previous by
+// current, or nil if their figures are the same.
+func NewReportRevision(previous, current *FundReport, threshold float64) *ReportRevision {
+	before, after := previous.Values(), current.Values()
+	changed := before.Strategy != after.Strategy || !sameValue(before.AUM, after.AUM)
+	var returnsChange float64
+	for idx, value := range after.returns() {
+		old := before.returns()[idx]
+		if sameValue(old, value) {
+			continue
+		}
+		changed = true
+		if old != nil && value != nil {
+			returnsChange = math.Max(returnsChange, math.Abs(*value-*old))
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return &ReportRevision{
+		FundReportID:  previous.ID,
+		FundID:        previous.FundID,
+		ReportDate:    *previous.ReportDate,
+		Origin:        previous.Origin,
+		Previous:      before,
+		Current:       after,
+		CrawlRunID:    current.CrawlRunID,
+		UploadID:      current.UploadID,
+		ReturnsChange: returnsChange,
+		Restatement:   returnsChange > threshold,
+	}
+}
+
+// Alert logs a warning about a restatement, which the log pipeline alerts on.
+func (r *ReportRevision) Alert() {
+	log.Warn().Str("alert", "restatement").
+		Uint64("fund_id", r.FundID).
+		Str("report_date", r.ReportDate.Format(time.DateOnly)).
+		Str("origin", r.Origin).
+		Float64("returns_change", r.ReturnsChange).
+		Msg("Report restated")
+}
+
+func sameValue(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewReportRevision(t *testing.T) {
+	may := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	value := func(v float64) *float64 { return &v }
+	previous := &FundReport{ID: 4, FundID: 1, ReportDate: &may, Origin: ProvenanceCrawled,
+		Month1Returns: value(1.5), Yr1Returns: value(12), AUM: value(100)}
+
+	same := *previous
+	if revision := NewReportRevision(previous, &same, 1); revision != nil {
+		t.Errorf("NewReportRevision() of an unchanged report = %+v, want nil", revision)
+	}
+
+	runID := uint(9)
+	tests := []struct {
+		name        string
+		current     FundReport
+		change      float64
+		restatement bool
+	}{
+		{name: "aum only", current: FundReport{Month1Returns: value(1.5), Yr1Returns: value(12), AUM: value(110)}},
+		{name: "small change", current: FundReport{Month1Returns: value(1.2), Yr1Returns: value(12), AUM: value(100)}, change: 0.3},
+		{name: "restated", current: FundReport{Month1Returns: value(1.5), Yr1Returns: value(14.5), AUM: value(100)}, change: 2.5, restatement: true},
+		{name: "return removed", current: FundReport{Month1Returns: value(1.5), AUM: value(100)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.current.CrawlRunID = &runID
+			revision := NewReportRevision(previous, &tt.current, 1)
+			if revision == nil {
+				t.Fatal("NewReportRevision() = nil")
+			}
+			if revision.FundReportID != 4 || *revision.CrawlRunID != runID || *revision.Previous.AUM != 100 {
+				t.Errorf("revision = %+v", revision)
+			}
+			if diff := revision.ReturnsChange - tt.change; diff > 1e-9 || diff < -1e-9 || revision.Restatement != tt.restatement {
+				t.Errorf("revision changed returns by %v, restatement %v; want %v, %v",
+					revision.ReturnsChange, revision.Restatement, tt.change, tt.restatement)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rs/zerolog/log"
+)
+
+// UserAgent is sent by requests that are not made through a colly collector.
+const UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:135.0) Gecko/20100101 Firefox/135.0"
+
+// sessionRetryAfter is how long a session that failed to start is not started
+// again; requests meanwhile fail with the same error.
+const sessionRetryAfter = time.Minute
+
+// Session holds the cookies and form token of a site that only answers requests
+// made within a session started on its landing page. A session is started on first
+// use and again once it is older than maxAge or expired. Requests sharing a Session
+// share one session.
+type Session struct {
+	landing    *url.URL
+	tokenField string
+	maxAge     time.Duration
+
+	// startMtx is held while a session starts, so concurrent requests start one.
+	// mtx guards the fields below and is not held during the request.
+	startMtx  sync.Mutex
+	mtx       sync.Mutex
+	transport http.RoundTripper
+	jar       http.CookieJar
+	token     string
+	startedAt time.Time
+	failedAt  time.Time
+	startErr  error
+}
+
+// NewSession returns a session started by loading landing. tokenField names the
+// hidden form field holding the token to send back, if the site uses one.
+func NewSession(landing, tokenField string, maxAge time.Duration) *Session {
+	landingURL, _ := url.Parse(landing)
+	return &Session{
+		landing:    landingURL,
+		tokenField: tokenField,
+		maxAge:     maxAge,
+	}
+}
+
+// WithTransport makes the session start through transport instead of Politeness().
+func (s *Session) WithTransport(transport http.RoundTripper) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.transport = transport
+}
+
+// Token returns the form token of the current session.
+func (s *Session) Token(ctx context.Context) (string, error) {
+	if err := s.ensure(ctx); err != nil {
+		return "", err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.token, nil
+}
+
+// Expire ends the session that issued token, so the next request starts a new one.
+// Tokens of earlier sessions are ignored, so requests failing together start a
+// single new session.
+func (s *Session) Expire(token string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if token == s.token {
+		s.startedAt = time.Time{}
+	}
+}
+
+// Jar returns a cookie jar with the cookies of the current session. Requests to the
+// landing page's host through it start a session when needed.
+func (s *Session) Jar() http.CookieJar {
+	return sessionJar{s}
+}
+
+// ensure starts a session unless the current one is fresh. A failed start is
+// returned again, without a request, until sessionRetryAfter has passed.
+func (s *Session) ensure(ctx context.Context) error {
+	s.startMtx.Lock()
+	defer s.startMtx.Unlock()
+
+	s.mtx.Lock()
+	fresh := !s.startedAt.IsZero() && time.Since(s.startedAt) < s.maxAge
+	failedAt, startErr, transport := s.failedAt, s.startErr, s.transport
+	s.mtx.Unlock()
+	if fresh {
+		return nil
+	}
+	if startErr != nil && time.Since(failedAt) < sessionRetryAfter {
+		return startErr
+	}
+
+	jar, token, err := s.start(ctx, transport)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err != nil {
+		// A start given up by the caller says nothing about the site.
+		if ctx.Err() == nil {
+			s.failedAt, s.startErr = time.Now(), err
+		}
+		return err
+	}
+	s.jar, s.token, s.startedAt = jar, token, time.Now()
+	s.failedAt, s.startErr = time.Time{}, nil
+	log.Info().Str("host", s.landing.Host).Msg("Started session")
+	return nil
+}
+
+// start loads the landing page and returns the cookies and token of the new session.
+func (s *Session) start(ctx context.Context, transport http.RoundTripper) (http.CookieJar, string, error) {
+	jar, _ := cookiejar.New(nil)
+	if transport == nil {
+		transport = Politeness()
+	}
+	client := &http.Client{Transport: transport, Jar: jar, Timeout: 30 * time.Second}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.landing.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("starting session on %s: %w", s.landing.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("starting session on %s: status %d", s.landing.Host, resp.StatusCode)
+	}
+
+	var token string
+	if s.tokenField != "" {
+		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		var ok bool
+		token, ok = doc.Find(fmt.Sprintf("input[name=%q]", s.tokenField)).Attr("value")
+		if !ok {
+			return nil, "", errors.New("no " + s.tokenField + " on the landing page of " + s.landing.Host)
+		}
+	}
+
+	return jar, token, nil
+}
+
+type sessionJar struct {
+	s *Session
+}
+
+func (j sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.s.mtx.Lock()
+	defer j.s.mtx.Unlock()
+
+	if j.s.jar != nil {
+		j.s.jar.SetCookies(u, cookies)
+	}
+}
+
+func (j sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Hostname() == j.s.landing.Hostname() {
+		if err := j.s.ensure(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Error while starting session")
+		}
+	}
+
+	j.s.mtx.Lock()
+	defer j.s.mtx.Unlock()
+	if j.s.jar == nil {
+		return nil
+	}
+	return j.s.jar.Cookies(u)
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSessionStartsAndExpires(t *testing.T) {
+	var started atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/report" {
+			cookie, err := r.Cookie("JSESSIONID")
+			if err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, cookie.Value)
+			return
+		}
+		n := started.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: fmt.Sprint("session-", n)})
+		fmt.Fprintf(w, `<form><input type="hidden" name="TOKEN" value="token-%d"></form>`, n)
+	}))
+	defer server.Close()
+
+	session := NewSession(server.URL+"/landing", "TOKEN", time.Hour)
+	session.WithTransport(http.DefaultTransport)
+	client := &http.Client{Jar: session.Jar()}
+	report := func() string {
+		t.Helper()
+		resp, err := client.Get(server.URL + "/report")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body [32]byte
+		n, _ := resp.Body.Read(body[:])
+		return string(body[:n])
+	}
+
+	if got := report(); got != "session-1" {
+		t.Errorf("first request sent session %q, want a new session-1", got)
+	}
+	token, err := session.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1" {
+		t.Errorf("Token() = %q, want token-1", token)
+	}
+
+	session.Expire(token)
+	if got := report(); got != "session-2" {
+		t.Errorf("request after Expire() sent session %q, want session-2", got)
+	}
+	// A second failure from the first session must not start a third.
+	session.Expire(token)
+	if token, _ := session.Token(context.Background()); token != "token-2" {
+		t.Errorf("Token() = %q after a stale Expire(), want token-2", token)
+	}
+	if n := started.Load(); n != 2 {
+		t.Errorf("started %d sessions, want 2", n)
+	}
+
+	other, _ := url.Parse("https://example.com/")
+	if cookies := session.Jar().Cookies(other); len(cookies) != 0 {
+		t.Errorf("Cookies(%s) = %v, want none", other, cookies)
+	}
+}
+
+func TestSessionDoesNotRetryFailedStart(t *testing.T) {
+	var started atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	session := NewSession(server.URL+"/landing", "TOKEN", time.Hour)
+	session.WithTransport(http.DefaultTransport)
+	for range 3 {
+		if _, err := session.Token(context.Background()); err == nil {
+			t.Fatal("Token() succeeded, want the error starting the session")
+		}
+	}
+	u, _ := url.Parse(server.URL + "/report")
+	if cookies := session.Jar().Cookies(u); len(cookies) != 0 {
+		t.Errorf("Cookies() = %v, want none", cookies)
+	}
+	if n := started.Load(); n != 1 {
+		t.Errorf("started %d sessions, want 1 until the retry delay passes", n)
+	}
+}
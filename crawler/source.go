@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v3"
+)
+
+// ErrUnknownSource is returned for a source missing from the SourceRegistry.
+var ErrUnknownSource = errors.New("unknown source")
+
+// Source is a publisher of fund data, such as SEBI's monthly PMS reports. The crawl
+// jobs in crawler/sources run against any registered Source.
+type Source interface {
+	// Name identifies the source in jobs and recorded failures.
+	Name() string
+
+	// Discover returns the IDs of the entities, such as fund houses, the source
+	// publishes data on.
+	Discover(ctx context.Context) ([]string, error)
+
+	// LatestPeriod returns the start of the latest period published by now.
+	LatestPeriod(now time.Time) time.Time
+	// NextPeriod returns the start of the period following the one starting at period.
+	NextPeriod(period time.Time) time.Time
+
+	// Fetch returns the raw data published on entity for the period.
+	Fetch(ctx context.Context, entity string, period time.Time) ([]byte, error)
+	// Parse reads raw data returned by Fetch and normalises it to funds, each with
+	// its fund house as FundManagers[0] and its FundReport for the period.
+	Parse(ctx context.Context, entity string, period time.Time, raw []byte) ([]*Fund, error)
+}
+
+var SourceRegistry = xsync.NewMapOf[string, Source]()
+
+func RegisterSource(source Source) {
+	SourceRegistry.Store(source.Name(), source)
+}
+
+// ConsistencyScheduler queues the jobs that recompute the figures of a fund house once
+// the jobs with the given keys, the crawls still queued for it, have finished.
+type ConsistencyScheduler func(fundHouseID uint64, after []string) error
+
+var consistencyScheduler ConsistencyScheduler
+
+// SetConsistencyScheduler sets the ConsistencyScheduler called by ScheduleConsistency.
+func SetConsistencyScheduler(scheduler ConsistencyScheduler) {
+	consistencyScheduler = scheduler
+}
+
+// ScheduleConsistency calls the ConsistencyScheduler set for the fund house, if any.
+// The crawl jobs call it for the fund houses whose funds they saved.
+func ScheduleConsistency(fundHouseID uint64, after []string) error {
+	if consistencyScheduler == nil {
+		return nil
+	}
+	return consistencyScheduler(fundHouseID, after)
+}
+
+// GetSource returns the registered source with the given name.
+func GetSource(name string) (Source, error) {
+	source, ok := SourceRegistry.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSource, name)
+	}
+	return source, nil
+}
+
+// Sources returns the names of the registered sources in order.
+func Sources() []string {
+	var names []string
+	SourceRegistry.Range(func(name string, _ Source) bool {
+		names = append(names, name)
+		return true
+	})
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,303 @@
+package sources
+
+import (
+	"alpha2/crawler"
+	"alpha2/jobs"
+	"alpha2/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
+)
+
+// RegisterJobs registers the crawl jobs that run against any source in the
+// crawler.SourceRegistry. The jobs read and write through st.
+func RegisterJobs(st *store.Store) {
+	jobs.RegisterJob("SourceCrawl", func() jobs.Job {
+		return &SourceCrawl{store: st}
+	})
+	jobs.RegisterJob("SourceBackfill", func() jobs.Job {
+		return &SourceBackfill{}
+	})
+	jobs.RegisterJob("SourceRetry", func() jobs.Job {
+		return &SourceRetry{store: st}
+	})
+}
+
+// SourceCrawl saves the funds a source published on one entity for one period. Unless
+// SkipNext is set, it goes on to the following periods already published.
+type SourceCrawl struct {
+	Source   string
+	Entity   string
+	Period   string // Start of the period, as a date
+	SkipNext bool
+
+	store *store.Store
+}
+
+func (j *SourceCrawl) Execute(ctx context.Context) error {
+	source, err := crawler.GetSource(j.Source)
+	if err != nil {
+		return err
+	}
+	period, err := time.Parse(time.DateOnly, j.Period)
+	if err != nil {
+		return err
+	}
+
+	fundHouseIDs, err := j.crawl(ctx, source, period)
+	if err != nil {
+		j.recordFailure(ctx, err)
+		return err
+	}
+	if err := j.store.Events.DeleteSourceFailures(ctx, j.Source, j.Entity, j.Period); err != nil {
+		log.Error().Err(err).Str("source", j.Source).Msg("Error while deleting crawl failures")
+	}
+
+	next := source.NextPeriod(period)
+	if !j.SkipNext && !next.After(source.LatestPeriod(time.Now())) {
+		if err := ScheduleCrawl(j.Source, j.Entity, next, false); err != nil {
+			return err
+		}
+	}
+	return j.scheduleDataConsistency(ctx, fundHouseIDs)
+}
+
+// crawl saves the funds of the period and returns the IDs of their fund houses.
+func (j *SourceCrawl) crawl(ctx context.Context, source crawler.Source, period time.Time) (map[uint64]bool, error) {
+	jobs.ReportStage(ctx, "fetching")
+	raw, err := source.Fetch(ctx, j.Entity, period)
+	if err != nil {
+		return nil, err
+	}
+	funds, err := source.Parse(ctx, j.Entity, period, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs.ReportStage(ctx, "saving")
+	fundHouseIDs := make(map[uint64]bool)
+	for idx, fund := range funds {
+		for _, report := range fund.FundReports {
+			if report.Source == "" {
+				report.Source = source.Name()
+			}
+		}
+		if err := j.store.Funds.SaveCrawled(ctx, fund, period); err != nil {
+			return nil, err
+		}
+		fundHouseIDs[fund.FundManagers[0].ID] = true
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(funds)))
+	}
+	return fundHouseIDs, nil
+}
+
+// scheduleDataConsistency queues the consistency job of each fund house crawled to
+// run once every crawl of the entity still queued, including this one, has finished.
+func (j *SourceCrawl) scheduleDataConsistency(ctx context.Context, fundHouseIDs map[uint64]bool) error {
+	if len(fundHouseIDs) == 0 {
+		return nil
+	}
+	queued, err := j.store.Jobs.List(ctx, store.JobFilter{Group: "SourceCrawl"})
+	if err != nil {
+		return err
+	}
+	var crawls []string
+	for _, scheduled := range queued {
+		var crawl SourceCrawl
+		if err := json.Unmarshal([]byte(scheduled.JobDescription), &crawl); err != nil {
+			log.Error().Err(err).Str("key", scheduled.JobKey).Msg("Error while reading queued crawl")
+			continue
+		}
+		if crawl.Source == j.Source && crawl.Entity == j.Entity {
+			crawls = append(crawls, scheduled.JobKey)
+		}
+	}
+	for fundHouseID := range fundHouseIDs {
+		if err := crawler.ScheduleConsistency(fundHouseID, crawls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordFailure keeps the last failure of the crawl, so SourceRetry can queue it again.
+func (j *SourceCrawl) recordFailure(ctx context.Context, cause error) {
+	err := j.store.Events.DeleteSourceFailures(ctx, j.Source, j.Entity, j.Period)
+	if err == nil {
+		err = j.store.Events.Create(ctx, &crawler.CrawlerEvent{
+			Data: crawler.JSONB{
+				"source": j.Source,
+				"entity": j.Entity,
+				"period": j.Period,
+				"error":  cause.Error(),
+				"time":   time.Now().String(),
+			},
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Str("source", j.Source).Str("entity", j.Entity).Msg("Error while recording crawl failure")
+	}
+}
+
+func (j *SourceCrawl) SetDescription(s string) {
+	if err := json.Unmarshal([]byte(s), j); err != nil {
+		log.Error().Err(err).Msg("Error while unmarshalling job")
+	}
+}
+
+func (j *SourceCrawl) Description() string {
+	data, err := json.Marshal(j)
+	if err != nil {
+		log.Error().Err(err).Msg("Error while marshalling job")
+		return ""
+	}
+	return string(data)
+}
+
+// ScheduleCrawl queues a crawl of entity for the period starting at period. A crawl
+// already queued for it is left as is.
+func ScheduleCrawl(source, entity string, period time.Time, skipNext bool) error {
+	job := &SourceCrawl{
+		Source:   source,
+		Entity:   entity,
+		Period:   period.Format(time.DateOnly),
+		SkipNext: skipNext,
+	}
+	jobDetail := quartz.NewJobDetailWithOptions(
+		job, quartz.NewJobKeyWithGroup(fmt.Sprintf("%s-%s-%s", job.Source, job.Entity, job.Period), "SourceCrawl"),
+		&quartz.JobDetailOptions{
+			MaxRetries:    10,
+			RetryInterval: time.Minute * 5,
+			Replace:       false,
+			Suspended:     false,
+		},
+	)
+
+	err := jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(time.Second))
+	if errors.Is(err, quartz.ErrJobAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// ScheduleBackfill queues a SourceBackfill of the source from the period starting at
+// from. A backfill already queued from it is left as is.
+func ScheduleBackfill(source string, from time.Time) error {
+	job := &SourceBackfill{
+		Source: source,
+		From:   from.Format(time.DateOnly),
+	}
+	jobDetail := quartz.NewJobDetailWithOptions(
+		job, quartz.NewJobKeyWithGroup(fmt.Sprintf("%s-%s", job.Source, job.From), "SourceBackfill"),
+		&quartz.JobDetailOptions{
+			MaxRetries:    10,
+			RetryInterval: time.Minute * 5,
+		},
+	)
+
+	err := jobs.Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(time.Second))
+	if errors.Is(err, quartz.ErrJobAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// SourceBackfill queues a crawl of every entity of a source from the period starting
+// at From to the latest published one. Without From, only the latest period is
+// crawled, so a schedule of SourceBackfill keeps the source up to date.
+type SourceBackfill struct {
+	Source string
+	From   string // Date within the first period to crawl
+}
+
+func (j *SourceBackfill) Execute(ctx context.Context) error {
+	source, err := crawler.GetSource(j.Source)
+	if err != nil {
+		return err
+	}
+	period, skipNext := source.LatestPeriod(time.Now()), true
+	if j.From != "" {
+		if period, err = time.Parse(time.DateOnly, j.From); err != nil {
+			return err
+		}
+		skipNext = false
+	}
+
+	jobs.ReportStage(ctx, "discovering")
+	entities, err := source.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	jobs.ReportStage(ctx, "scheduling crawls")
+	for idx, entity := range entities {
+		if err := ScheduleCrawl(j.Source, entity, period, skipNext); err != nil {
+			return err
+		}
+		jobs.ReportProgress(ctx, int64(idx+1), int64(len(entities)))
+	}
+	return nil
+}
+
+func (j *SourceBackfill) SetDescription(s string) {
+	if err := json.Unmarshal([]byte(s), j); err != nil {
+		log.Error().Err(err).Msg("Error while unmarshalling job")
+	}
+}
+
+func (j *SourceBackfill) Description() string {
+	data, err := json.Marshal(j)
+	if err != nil {
+		log.Error().Err(err).Msg("Error while marshalling job")
+		return ""
+	}
+	return string(data)
+}
+
+// SourceRetry queues again the failed crawls of a source. Their failures are
+// forgotten once they succeed.
+type SourceRetry struct {
+	Source string
+
+	store *store.Store
+}
+
+func (j *SourceRetry) Execute(ctx context.Context) error {
+	if _, err := crawler.GetSource(j.Source); err != nil {
+		return err
+	}
+	failures, err := j.store.Events.FailedSourceRequests(ctx, j.Source)
+	if err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		period, err := time.Parse(time.DateOnly, failure.Data["period"])
+		if err != nil {
+			log.Error().Err(err).Uint64("event", failure.ID).Msg("Error while reading crawl failure")
+			continue
+		}
+		if err := ScheduleCrawl(j.Source, failure.Data["entity"], period, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *SourceRetry) SetDescription(s string) {
+	if err := json.Unmarshal([]byte(s), j); err != nil {
+		log.Error().Err(err).Msg("Error while unmarshalling job")
+	}
+}
+
+func (j *SourceRetry) Description() string {
+	data, err := json.Marshal(j)
+	if err != nil {
+		log.Error().Err(err).Msg("Error while marshalling job")
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"alpha2/crawler"
+	"alpha2/jobs"
+	"alpha2/migrations/migratetest"
+	"alpha2/store"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeSource publishes one fund per entity each month, and fails for the entity
+// "broken" until fixed.
+type fakeSource struct {
+	fixed bool
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) Discover(ctx context.Context) ([]string, error) {
+	return []string{"A", "broken"}, nil
+}
+
+func (s *fakeSource) LatestPeriod(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *fakeSource) NextPeriod(period time.Time) time.Time {
+	return period.AddDate(0, 1, 0)
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, entity string, period time.Time) ([]byte, error) {
+	if entity == "broken" && !s.fixed {
+		return nil, errors.New("503 from fake")
+	}
+	return []byte(entity), nil
+}
+
+func (s *fakeSource) Parse(ctx context.Context, entity string, period time.Time, raw []byte) ([]*crawler.Fund, error) {
+	id := uint64(raw[0])
+	return []*crawler.Fund{{
+		ID:           id,
+		Name:         "Fund " + entity,
+		Type:         "PMF",
+		FundManagers: []*crawler.FundManager{{ID: id, Name: "House " + entity, UID: entity, RegisterNumber: entity}},
+		FundReports:  []*crawler.FundReport{{FundID: id, ReportDate: &period}},
+	}}, nil
+}
+
+func TestSourceCrawlSavesAndRetries(t *testing.T) {
+	ctx := context.Background()
+	db := migratetest.Open(t)
+	jobs.Init(db)
+	st := store.New(db)
+	source := &fakeSource{}
+	crawler.RegisterSource(source)
+	var consistency []uint64
+	crawler.SetConsistencyScheduler(func(fundHouseID uint64, after []string) error {
+		consistency = append(consistency, fundHouseID)
+		return nil
+	})
+	t.Cleanup(func() { crawler.SetConsistencyScheduler(nil) })
+
+	latest := source.LatestPeriod(time.Now())
+	queued := func(name string) bool {
+		t.Helper()
+		var n int64
+		if err := st.DB(ctx).Model(&jobs.ScheduledJob{}).Where("job_name = ?", name).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n > 0
+	}
+
+	previous := latest.AddDate(0, -1, 0)
+	crawl := &SourceCrawl{Source: "fake", Entity: "A", Period: previous.Format(time.DateOnly), store: st}
+	if err := crawl.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Funds.Get(ctx, 'A'); err != nil {
+		t.Errorf("crawled fund not saved: %v", err)
+	}
+	if next := "fake-A-" + latest.Format(time.DateOnly); !queued(next) {
+		t.Errorf("%s not queued after crawling %s", next, crawl.Period)
+	}
+	// fakeSource numbers the fund house of A after its first byte, 65.
+	if len(consistency) != 1 || consistency[0] != 65 {
+		t.Errorf("consistency scheduled for fund houses %v after the crawl, want [65]", consistency)
+	}
+
+	broken := &SourceCrawl{Source: "fake", Entity: "broken", Period: latest.Format(time.DateOnly), store: st}
+	for range 2 {
+		if err := broken.Execute(ctx); err == nil {
+			t.Fatal("Execute() returned no error for a failing fetch")
+		}
+	}
+	failures, err := st.Events.FailedSourceRequests(ctx, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].Data["entity"] != "broken" {
+		t.Fatalf("recorded failures %v, want the one of broken", failures)
+	}
+
+	retry := &SourceRetry{Source: "fake", store: st}
+	if err := retry.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if name := "fake-broken-" + broken.Period; !queued(name) {
+		t.Errorf("%s not queued by SourceRetry", name)
+	}
+
+	source.fixed = true
+	if err := broken.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if failures, _ := st.Events.FailedSourceRequests(ctx, "fake"); len(failures) != 0 {
+		t.Errorf("failures %v left after a successful crawl", failures)
+	}
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const sqliteDriverName = "sqlite3_alpha2"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("similarity", sqliteSimilarity, true)
+		},
+	})
+}
+
+// OpenSQLite opens the SQLite database at dsn. The connection provides a
+// similarity() function so fuzzy search queries written for pg_trgm run unchanged.
+func OpenSQLite(dsn string) (*gorm.DB, error) {
+	gormLogger := &ZeroLogger{log: log.Logger}
+	return gorm.Open(sqlite.New(sqlite.Config{DriverName: sqliteDriverName, DSN: dsn}), &gorm.Config{Logger: gormLogger})
+}
+
+func sqliteSimilarity(a, b any) float64 {
+	as, _ := a.(string)
+	bs, _ := b.(string)
+	return Similarity(as, bs)
+}
+
+// Similarity approximates pg_trgm's similarity(): the number of trigrams shared by
+// a and b divided by the number of distinct trigrams in either. Words are lower-cased
+// and padded with two spaces in front and one behind, as pg_trgm does.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,24 @@
+package crawler
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "word", b: "word", want: 1},
+		{a: "Word", b: "WORD", want: 1},
+		{a: "word", b: "two words", want: 0.3636},
+		{a: "abc", b: "xyz", want: 0},
+		{a: "", b: "xyz", want: 0},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("Similarity(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Statuses of a ReportUpload.
+const (
+	UploadPending  = "pending"
+	UploadApproved = "approved"
+	UploadRejected = "rejected"
+)
+
+// ReportUpload is a batch of monthly reports of a fund uploaded by an admin, such as
+// from a factsheet the manager sent while SEBI lags. Its reports are only saved as
+// FundReports once the upload is approved.
+type ReportUpload struct {
+	ID       uint64 `json:"id"`
+	FundID   uint64 `gorm:"index" json:"fund_id"`
+	FileName string `gorm:"not null;default:''" json:"file_name"`
+	Status   string `gorm:"not null;default:'pending';index" json:"status"`
+
+	UploadedBy string `gorm:"not null;default:''" json:"uploaded_by"`
+	ReviewedBy string `gorm:"not null;default:''" json:"reviewed_by"`
+	// Note is the reason given for rejecting the upload.
+	Note string `gorm:"not null;default:''" json:"note"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+
+	Reports []*UploadedReport `json:"reports"`
+}
+
+// UploadedReport is one month of a ReportUpload.
+type UploadedReport struct {
+	ID             uint64    `json:"id"`
+	ReportUploadID uint64    `gorm:"index" json:"-"`
+	ReportDate     time.Time `json:"report_date"`
+
+	AUM            *float64 `json:"aum"`
+	Month1Returns  *float64 `json:"1_month_return"`
+	Month3Returns  *float64 `json:"3_month_return"`
+	Month6Returns  *float64 `json:"6_month_return"`
+	Yr1Returns     *float64 `json:"1_year_return"`
+	Yr2Returns     *float64 `json:"2_year_return"`
+	Yr3Returns     *float64 `json:"3_year_return"`
+	Yr4Returns     *float64 `json:"4_year_return"`
+	Yr5Returns     *float64 `json:"5_year_return"`
+	OverAllReturns *float64 `json:"over_all_return"`
+
+	// Skipped is set on approval when the month has a report that takes precedence
+	// over a manual one, so the uploaded report is not shown.
+	Skipped bool `gorm:"not null;default:false" json:"skipped"`
+}
+
+// FundReport returns the manual report of the fund for the month.
+func (u *UploadedReport) FundReport(fundID uint64) *FundReport {
+	reportDate := u.ReportDate
+	return &FundReport{
+		FundID:         fundID,
+		ReportDate:     &reportDate,
+		AUM:            u.AUM,
+		Month1Returns:  u.Month1Returns,
+		Month3Returns:  u.Month3Returns,
+		Month6Returns:  u.Month6Returns,
+		Yr1Returns:     u.Yr1Returns,
+		Yr2Returns:     u.Yr2Returns,
+		Yr3Returns:     u.Yr3Returns,
+		Yr4Returns:     u.Yr4Returns,
+		Yr5Returns:     u.Yr5Returns,
+		OverAllReturns: u.OverAllReturns,
+		Provenance:     ProvenanceManual,
+		Origin:         ProvenanceManual,
+		OtherData:      make(JSONB),
+	}
+}
+
+// uploadColumns maps the columns of an upload, normalised by uploadColumn, to the
+// fields they fill. AUM is in INR crores and returns are in percent.
+var uploadColumns = map[string]func(*UploadedReport) **float64{
+	"aum":             func(u *UploadedReport) **float64 { return &u.AUM },
+	"1 month":         func(u *UploadedReport) **float64 { return &u.Month1Returns },
+	"3 month":         func(u *UploadedReport) **float64 { return &u.Month3Returns },
+	"6 month":         func(u *UploadedReport) **float64 { return &u.Month6Returns },
+	"1 year":          func(u *UploadedReport) **float64 { return &u.Yr1Returns },
+	"2 year":          func(u *UploadedReport) **float64 { return &u.Yr2Returns },
+	"3 year":          func(u *UploadedReport) **float64 { return &u.Yr3Returns },
+	"4 year":          func(u *UploadedReport) **float64 { return &u.Yr4Returns },
+	"5 year":          func(u *UploadedReport) **float64 { return &u.Yr5Returns },
+	"since inception": func(u *UploadedReport) **float64 { return &u.OverAllReturns },
+}
+
+// uploadMonthLayouts are the accepted formats of the month column. The last two are
+// how spreadsheets show dates by default.
+var uploadMonthLayouts = []string{"2006-01", time.DateOnly, "Jan 2006", "January 2006", "Jan-06", "01/2006", "01-02-06", "1/2/06"}
+
+// UploadError is a problem with one row of an upload. Row counts from 1 at the
+// header.
+type UploadError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// UploadErrors lists every problem found in an upload.
+type UploadErrors []UploadError
+
+func (e UploadErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = fmt.Sprintf("row %d: %s", err.Row, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ReadReportUpload reads and validates the monthly reports of a CSV or XLSX file,
+// told apart by the extension of name. The header row needs a Month column and at
+// least one of AUM, 1 Month ... 5 Years and Since Inception. All problems found are
+// returned together as UploadErrors.
+func ReadReportUpload(name string, in io.Reader, now time.Time) ([]*UploadedReport, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		reader := csv.NewReader(in)
+		reader.FieldsPerRecord = -1
+		rows, err = reader.ReadAll()
+	case ".xlsx":
+		rows, err = readWorkbookRows(in)
+	default:
+		return nil, fmt.Errorf("unsupported upload %q (use .csv or .xlsx)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("upload has no rows below the header")
+	}
+
+	columns := make([]string, len(rows[0]))
+	hasMonth, hasValue := false, false
+	for idx, header := range rows[0] {
+		columns[idx] = uploadColumn(header)
+		hasMonth = hasMonth || columns[idx] == "month"
+		_, ok := uploadColumns[columns[idx]]
+		hasValue = hasValue || ok
+	}
+	if !hasMonth || !hasValue {
+		return nil, UploadErrors{{Row: 1, Message: "header needs a Month column and an AUM or returns column"}}
+	}
+
+	var reports []*UploadedReport
+	var problems UploadErrors
+	seen := make(map[time.Time]int)
+	for idx, record := range rows[1:] {
+		row := idx + 2
+		if isBlank(record) {
+			continue
+		}
+		report, rowProblems := readUploadRow(columns, record, now)
+		for _, problem := range rowProblems {
+			problems = append(problems, UploadError{Row: row, Message: problem})
+		}
+		if report == nil {
+			continue
+		}
+		if first, ok := seen[report.ReportDate]; ok {
+			problems = append(problems, UploadError{Row: row, Message: fmt.Sprintf("month repeats row %d", first)})
+			continue
+		}
+		seen[report.ReportDate] = row
+		reports = append(reports, report)
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return reports, nil
+}
+
+// readUploadRow returns the report of a row, or nil, and the problems found in it.
+func readUploadRow(columns, record []string, now time.Time) (*UploadedReport, []string) {
+	report := &UploadedReport{}
+	var problems []string
+	hasMonth, hasValue := false, false
+	for idx, cell := range record {
+		if idx >= len(columns) {
+			break
+		}
+		cell = strings.TrimSpace(cell)
+		if columns[idx] == "month" {
+			month, err := parseUploadMonth(cell)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			if !month.Before(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+				problems = append(problems, fmt.Sprintf("month %s has not ended", month.Format("2006-01")))
+				continue
+			}
+			report.ReportDate, hasMonth = month, true
+			continue
+		}
+		field, ok := uploadColumns[columns[idx]]
+		if !ok {
+			continue
+		}
+		value, err := parseUploadNumber(cell)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", columns[idx], err))
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		switch {
+		case columns[idx] == "aum" && *value < 0:
+			problems = append(problems, "aum is negative")
+		case columns[idx] != "aum" && (*value <= -100 || *value >= 1000):
+			problems = append(problems, fmt.Sprintf("%s return %v%% is out of range", columns[idx], *value))
+		}
+		*field(report), hasValue = value, true
+	}
+	if !hasMonth && len(problems) == 0 {
+		problems = append(problems, "month is missing")
+	}
+	if !hasValue && len(problems) == 0 {
+		problems = append(problems, "row has no AUM or returns")
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return report, nil
+}
+
+// parseUploadMonth returns the first day of the month in cell.
+func parseUploadMonth(cell string) (time.Time, error) {
+	for _, layout := range uploadMonthLayouts {
+		if month, err := time.Parse(layout, cell); err == nil {
+			return time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("month %q is not like 2024-06", cell)
+}
+
+// parseUploadNumber reads a number written with thousands separators or a percent
+// sign. Empty, "-" and "NA" cells are nil.
+func parseUploadNumber(cell string) (*float64, error) {
+	cell = strings.TrimSpace(strings.TrimSuffix(strings.ReplaceAll(cell, ",", ""), "%"))
+	if cell == "" || cell == "-" || strings.EqualFold(cell, "NA") {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", cell)
+	}
+	return &value, nil
+}
+
+func uploadColumn(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	header = strings.ReplaceAll(header, "years", "year")
+	header = strings.ReplaceAll(header, "months", "month")
+	if header == "report date" {
+		return "month"
+	}
+	return header
+}
+
+func readWorkbookRows(in io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	return f.GetRows(sheets[0])
+}
+
+func isBlank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
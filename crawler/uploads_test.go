@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadReportUpload(t *testing.T) {
+	now := time.Date(2024, time.August, 10, 0, 0, 0, 0, time.UTC)
+
+	csv := "Month,AUM,1 Month,1 Year,Note\n2024-06,\"1,020.5\",1.25%,NA,factsheet\n\nJul 2024,1040,-0.5,14,\n"
+	reports, err := ReadReportUpload("factsheet.csv", strings.NewReader(csv), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("read %d reports, want 2", len(reports))
+	}
+	june := reports[0]
+	if !june.ReportDate.Equal(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)) || *june.AUM != 1020.5 ||
+		*june.Month1Returns != 1.25 || june.Yr1Returns != nil {
+		t.Errorf("June report = %v AUM %v, returns %v %v", june.ReportDate, *june.AUM, *june.Month1Returns, june.Yr1Returns)
+	}
+	if report := reports[1].FundReport(7); report.FundID != 7 || report.Provenance != ProvenanceManual || *report.Yr1Returns != 14 {
+		t.Errorf("FundReport() = %+v", report)
+	}
+
+	workbook := excelize.NewFile()
+	workbook.SetSheetRow("Sheet1", "A1", &[]any{"Report Date", "3 Months"})
+	workbook.SetSheetRow("Sheet1", "A2", &[]any{"2024-05", 4.5})
+	var xlsx bytes.Buffer
+	if err := workbook.Write(&xlsx); err != nil {
+		t.Fatal(err)
+	}
+	reports, err = ReadReportUpload("factsheet.XLSX", &xlsx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || *reports[0].Month3Returns != 4.5 {
+		t.Errorf("read %v from the workbook, want May with a 3 month return of 4.5", reports)
+	}
+
+	invalid := "Month,AUM,1 Month\n2024-08,10,1\n2024-06,-5,abc\n2024-05,10,1500\n2024-05,10,1\nlast year,,\n2024-04,,\n"
+	_, err = ReadReportUpload("bad.csv", strings.NewReader(invalid), now)
+	var problems UploadErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("ReadReportUpload() error = %v, want UploadErrors", err)
+	}
+	rows := make([]int, len(problems))
+	for idx, problem := range problems {
+		rows[idx] = problem.Row
+	}
+	want := []int{2, 3, 3, 4, 6, 7}
+	if len(rows) != len(want) {
+		t.Fatalf("problems on rows %v (%v), want rows %v", rows, err, want)
+	}
+	for idx := range want {
+		if rows[idx] != want[idx] {
+			t.Fatalf("problems on rows %v (%v), want rows %v", rows, err, want)
+		}
+	}
+	if !strings.Contains(err.Error(), "row 2: month 2024-08 has not ended") {
+		t.Errorf("error %q does not reject the current month", err)
+	}
+
+	if _, err := ReadReportUpload("factsheet.pdf", strings.NewReader(""), now); err == nil {
+		t.Error("ReadReportUpload() accepted a PDF")
+	}
+}
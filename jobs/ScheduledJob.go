@@ -1,25 +1,61 @@
 package jobs
 
 import (
+	"bytes"
 	"context"
-	"strings"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/puzpuzpuz/xsync/v3"
 	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
+const (
+	StatusScheduled = "scheduled"
+	StatusRunning   = "running"
+	StatusPaused    = "paused"
+	StatusBlocked   = "blocked" // Waiting for the jobs it depends on
+)
+
+// ErrUnknownGroup is returned for a job group missing from the JobRegistry.
+var ErrUnknownGroup = errors.New("unknown job group")
+
+// PausedGroup marks a job group as paused. Its jobs stay in the queue, including
+// ones scheduled while paused, but are not run until the group is resumed.
+type PausedGroup struct {
+	JobGroup  string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
 type ScheduledJob struct {
 	gorm.Model
-	JobKey             string `gorm:"not null"`  // Unique identifier for the job
-	JobName            string `gorm:"not null"`  // Name of the job
-	JobGroup           string `gorm:"not null"`  // Group of the job
-	JobDescription     string `gorm:"not null"`  // Group of the job
-	JobData            string `gorm:"type:text"` // Additional job data
-	NxtRunTime         int64  `gorm:"not null"`  // Next execution time as Unix timestamp
-	JobStatus          string `gorm:"not null"`  // Status of the job (e.g., "scheduled", "executed")
-	TriggerDescription string `gorm:"not null"`  // Status of the job (e.g., "scheduled", "executed")
+	// JobKey identifies the job. It is unique among the jobs waiting to run, so a
+	// job is queued once but may be pushed again while it runs.
+	JobKey string `gorm:"not null;uniqueIndex:idx_scheduled_jobs_waiting_key,where:job_status != 'running' AND deleted_at IS NULL"`
+
+	JobName            string `gorm:"not null"`                                           // Name of the job
+	JobGroup           string `gorm:"not null"`                                           // Group of the job
+	JobDescription     string `gorm:"not null"`                                           // Group of the job
+	JobData            string `gorm:"type:text"`                                          // JSON fields of the job, see jobPayload
+	NxtRunTime         int64  `gorm:"not null;index:idx_scheduled_jobs_claim,priority:2"` // Next execution time as Unix timestamp
+	JobStatus          string `gorm:"not null;index:idx_scheduled_jobs_claim,priority:1"` // StatusScheduled, StatusRunning, StatusPaused or StatusBlocked
+	TriggerDescription string `gorm:"not null"`                                           // Trigger().Description(), for display
+	TriggerType        string `gorm:"not null;default:''"`                                // TriggerRunOnce, TriggerSimple or TriggerCron
+	TriggerInterval    int64  `gorm:"not null;default:0"`                                 // Run once delay or simple interval, in nanoseconds
+	TriggerCron        string `gorm:"not null;default:''"`                                // Cron expression
+	TriggerTimezone    string `gorm:"not null;default:''"`                                // IANA time zone of the cron expression
+	TriggerExpired     bool   `gorm:"not null;default:false"`                             // Whether a run once trigger has fired
+	LeaseOwner         string `gorm:"not null;default:''"`                                // Instance running the job
+	LeaseExpiresAt     int64  `gorm:"not null;default:0"`                                 // Unix nanoseconds; renewed by heartbeats
+	HeartbeatAt        int64  `gorm:"not null;default:0"`                                 // Unix nanoseconds of the last heartbeat
+
+	// queue is set by GormJobQueue.Pop for the jobs it claims, so executions are
+	// recorded as JobRuns and release the claim when they finish.
+	queue *GormJobQueue `gorm:"-"`
 }
 
 type Job interface {
@@ -37,35 +73,27 @@ func (sj *ScheduledJob) NextRunTime() int64 {
 	return sj.NxtRunTime
 }
 
-// Trigger implements quartz.ScheduledJob.
-func (sj *ScheduledJob) Trigger() quartz.Trigger {
-	triggerOpts := strings.Split(sj.TriggerDescription, quartz.Sep)
-	interval, err := time.ParseDuration(triggerOpts[1])
-	if err != nil {
-		return quartz.NewRunOnceTrigger(time.Second * 5)
-	}
-	if strings.Contains(triggerOpts[0], "SimpleTrigger") {
-		return quartz.NewSimpleTrigger(interval)
-	} else if strings.Contains(triggerOpts[0], "CronTrigger") {
-		t, _ := quartz.NewCronTrigger(triggerOpts[1])
-		return t
-	} else {
-
-		return &quartz.RunOnceTrigger{
-			Delay:   interval,
-			Expired: triggerOpts[2] == "expired",
-		}
-	}
-}
+const maxRetries = 10
 
 func (sj *ScheduledJob) JobDetail() *quartz.JobDetail {
 	j := GetJob(sj.JobGroup)
 	j.SetDescription(sj.JobDescription)
+	if sj.JobData != "" {
+		if err := json.Unmarshal([]byte(sj.JobData), j); err != nil {
+			log.Error().Err(err).Str("key", sj.JobKey).Msg("Error while reading job data")
+		}
+	}
+	key := quartz.NewJobKeyWithGroup(sj.JobName, sj.JobGroup)
+	if sj.queue != nil {
+		id := sj.ID
+		j = &recordedJob{Job: j, db: sj.queue.db, key: key, maxRetries: maxRetries,
+			done: func(attempts int, err error) { sj.queue.done(id, attempts, err) }}
+	}
 	job := quartz.NewJobDetailWithOptions(
 		j,
-		quartz.NewJobKeyWithGroup(sj.JobName, sj.JobGroup),
+		key,
 		&quartz.JobDetailOptions{
-			MaxRetries:    10,
+			MaxRetries:    maxRetries,
 			RetryInterval: time.Minute * 5,
 			Replace:       false,
 			Suspended:     false,
@@ -88,3 +116,38 @@ func GetJob(group string) Job {
 	supplier, _ := JobRegistry.Load(group)
 	return supplier()
 }
+
+// jobPayload returns the JSON of the exported fields of a job, the payload NewJob
+// reads. It is kept with the job, as Description does not hold the fields of every
+// job.
+func jobPayload(job quartz.Job) (string, error) {
+	if recorded, ok := job.(*recordedJob); ok {
+		job = recorded.Job
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// NewJob returns a job of the registered group with its fields decoded from the JSON
+// payload. Fields the job does not have are rejected. An empty payload leaves the
+// fields zero.
+func NewJob(group string, payload []byte) (Job, error) {
+	supplier, ok := JobRegistry.Load(group)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownGroup, group)
+	}
+	job := supplier()
+	if len(payload) == 0 {
+		return job, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(job); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", group, err)
+	}
+	return job, nil
+}
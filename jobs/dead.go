@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// DeadJob is a job whose last attempt failed. It stays here until it is replayed.
+type DeadJob struct {
+	ID             uint      `gorm:"primaryKey"`
+	JobKey         string    `gorm:"not null;index"`
+	JobName        string    `gorm:"not null"`
+	JobGroup       string    `gorm:"not null;index"`
+	JobDescription string    `gorm:"type:text"`
+	JobData        string    `gorm:"type:text"` // Payload of the job, as in ScheduledJob
+	Attempts       int       `gorm:"not null"`
+	Error          string    `gorm:"type:text"`
+	FailedAt       time.Time `gorm:"not null;index"`
+}
+
+// Replay queues the job to run once more, now. It runs under a new name, as the
+// original key may already be taken by the next run of a recurring job, and jobs
+// depending on it wait for the replay. The job gets the payload it failed with.
+func (d *DeadJob) Replay() error {
+	job, err := NewJob(d.JobGroup, []byte(d.JobData))
+	if err != nil {
+		return err
+	}
+	job.SetDescription(d.JobDescription)
+
+	name := fmt.Sprintf("%s-replay-%d", d.JobName, d.ID)
+	jobDetail := quartz.NewJobDetailWithOptions(job, quartz.NewJobKeyWithGroup(name, d.JobGroup),
+		&quartz.JobDetailOptions{
+			MaxRetries:    maxRetries,
+			RetryInterval: time.Minute * 5,
+			Replace:       false,
+			Suspended:     false,
+		})
+	if err := Scheduler.ScheduleJob(jobDetail, quartz.NewRunOnceTrigger(0)); err != nil {
+		return err
+	}
+	// Jobs blocked on the dead job now wait for its replay.
+	queue.repointDependencies(d.JobKey, jobDetail.JobKey().String())
+	return nil
+}
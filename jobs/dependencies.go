@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"errors"
+
+	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobDependency records that the job with JobKey runs only after the job with
+// DependsOnKey has finished. A prerequisite counts as finished once it is no longer
+// queued, unless it ended up in the dead jobs.
+type JobDependency struct {
+	JobKey       string `gorm:"primaryKey"`
+	DependsOnKey string `gorm:"primaryKey;index"`
+}
+
+// ScheduleAfter queues a job that stays blocked until every job in after has
+// finished. If the job is already queued and not running, the prerequisites are
+// added to it.
+func ScheduleAfter(jobDetail *quartz.JobDetail, trigger quartz.Trigger, after []string) error {
+	key := jobDetail.JobKey().String()
+	if len(after) > 0 {
+		dependencies := make([]JobDependency, 0, len(after))
+		for _, dependsOn := range after {
+			dependencies = append(dependencies, JobDependency{JobKey: key, DependsOnKey: dependsOn})
+		}
+		err := queue.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&dependencies).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	err := Scheduler.ScheduleJob(jobDetail, trigger)
+	if errors.Is(err, quartz.ErrJobAlreadyExists) {
+		err = queue.db.Model(&ScheduledJob{}).
+			Where("job_key = ? AND job_status = ?", key, StatusScheduled).
+			Update("job_status", StatusBlocked).Error
+	}
+	if err != nil {
+		return err
+	}
+	return queue.resolveDependencies()
+}
+
+// resolveDependencies unblocks the jobs whose prerequisites have all finished and
+// forgets their dependencies.
+func (gq *GormJobQueue) resolveDependencies() error {
+	return gq.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&ScheduledJob{}).
+			Where("job_status = ?", StatusBlocked).
+			Where(`NOT EXISTS (SELECT 1 FROM job_dependencies d WHERE d.job_key = scheduled_jobs.job_key AND (
+				EXISTS (SELECT 1 FROM scheduled_jobs p WHERE p.job_key = d.depends_on_key AND p.deleted_at IS NULL)
+				OR EXISTS (SELECT 1 FROM dead_jobs x WHERE x.job_key = d.depends_on_key)))`).
+			Update("job_status", StatusScheduled).Error
+		if err != nil {
+			return err
+		}
+		// Rows for jobs still being pushed are kept; they have no queued job yet.
+		return tx.Where(`EXISTS (SELECT 1 FROM scheduled_jobs s
+			WHERE s.job_key = job_dependencies.job_key AND s.job_status <> ? AND s.deleted_at IS NULL)`, StatusBlocked).
+			Delete(&JobDependency{}).Error
+	})
+}
+
+// repointDependencies makes the jobs waiting for from wait for to instead.
+func (gq *GormJobQueue) repointDependencies(from, to string) {
+	err := gq.db.Model(&JobDependency{}).Where("depends_on_key = ?", from).Update("depends_on_key", to).Error
+	if err != nil {
+		log.Error().Err(err).Str("from", from).Str("to", to).Msg("Error while moving job dependencies")
+	}
+}
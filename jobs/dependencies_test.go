@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+func TestScheduleAfterWaitsForPrerequisites(t *testing.T) {
+	db := newTestDB(t)
+	queue = NewGormJobQueue(db)
+	Scheduler, _ = quartz.NewStdScheduler(quartz.WithQueue(queue, &sync.Mutex{}))
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+
+	insertJob(t, db, "crawl-1", time.Now().Add(-time.Second))
+	insertJob(t, db, "crawl-2", time.Now().Add(-time.Second))
+	crawl, err := queue.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := func() string {
+		t.Helper()
+		var job ScheduledJob
+		if err := db.Where("job_name = ?", "consistency").First(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+		return job.JobStatus
+	}
+	jd := quartz.NewJobDetail(&payloadJob{}, quartz.NewJobKeyWithGroup("consistency", "payloadJob"))
+	after := []string{"CrawlPMFFunds::crawl-1", "CrawlPMFFunds::crawl-2"}
+	if err := ScheduleAfter(jd, quartz.NewRunOnceTrigger(0), after); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != StatusBlocked {
+		t.Fatalf("status = %q with prerequisites queued, want %q", got, StatusBlocked)
+	}
+
+	// A failed prerequisite keeps it blocked until it is replayed or discarded.
+	queue.done(crawl.(*ScheduledJob).ID, maxRetries+1, errors.New("503 from SEBI"))
+	queue.Remove(quartz.NewJobKeyWithGroup("crawl-2", "CrawlPMFFunds"))
+	if err := queue.resolveDependencies(); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != StatusBlocked {
+		t.Fatalf("status = %q with a dead prerequisite, want %q", got, StatusBlocked)
+	}
+
+	db.Where("1 = 1").Delete(&DeadJob{})
+	if err := queue.resolveDependencies(); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != StatusScheduled {
+		t.Errorf("status = %q with prerequisites finished, want %q", got, StatusScheduled)
+	}
+	var left int64
+	db.Model(&JobDependency{}).Count(&left)
+	if left != 0 {
+		t.Errorf("%d dependencies left after unblocking, want 0", left)
+	}
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+)
+
+// JobRun records one execution attempt of a scheduled job.
+type JobRun struct {
+	ID             uint      `gorm:"primaryKey"`
+	JobKey         string    `gorm:"not null;index"`
+	JobName        string    `gorm:"not null"`
+	JobGroup       string    `gorm:"not null;index"`
+	JobDescription string    `gorm:"type:text"`
+	Attempt        int       `gorm:"not null"`
+	StartedAt      time.Time `gorm:"not null;index"`
+	FinishedAt     time.Time `gorm:"not null"`
+	DurationMs     int64     `gorm:"not null"`
+	Outcome        string    `gorm:"not null;index"` // RunRunning, RunCompleted or RunFailed
+	Error          string    `gorm:"type:text"`
+	Stage          string    `gorm:"not null;default:''"` // Set by ReportStage
+	ProgressDone   int64     `gorm:"not null;default:0"`  // Set by ReportProgress
+	ProgressTotal  int64     `gorm:"not null;default:0"`
+}
+
+// recordedJob wraps a Job and writes a JobRun for every call to Execute, updated
+// with the progress the job reports while it runs. The
+// scheduler retries by calling Execute on the same instance, so calls are counted
+// as attempts. done, when set, is called with the last error once no further
+// attempt will follow.
+type recordedJob struct {
+	Job
+	db         *gorm.DB
+	key        *quartz.JobKey
+	attempt    int
+	maxRetries int
+	done       func(attempts int, err error)
+}
+
+func (j *recordedJob) Execute(ctx context.Context) (err error) {
+	j.attempt++
+	run := &JobRun{
+		JobKey:         j.key.String(),
+		JobName:        j.key.Name(),
+		JobGroup:       j.key.Group(),
+		JobDescription: j.Job.Description(),
+		Attempt:        j.attempt,
+		StartedAt:      time.Now(),
+		Outcome:        RunRunning,
+	}
+	if err := j.db.WithContext(ctx).Create(run).Error; err != nil {
+		log.Error().Err(err).Str("key", run.JobKey).Msg("Error while recording job run")
+	}
+	p := &progress{db: j.db, run: run}
+	ctx = context.WithValue(ctx, progressKey{}, p)
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+		p.finish(ctx, err)
+		// The scheduler does not retry a job that panicked.
+		if j.done != nil && (err == nil || r != nil || j.attempt > j.maxRetries) {
+			j.done(j.attempt, err)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	return j.Job.Execute(ctx)
+}
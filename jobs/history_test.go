@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+type stubJob struct {
+	description string
+	errs        []error
+}
+
+func (j *stubJob) Execute(context.Context) error {
+	err := j.errs[0]
+	j.errs = j.errs[1:]
+	return err
+}
+
+func (j *stubJob) Description() string               { return j.description }
+func (j *stubJob) SetDescription(description string) { j.description = description }
+
+func TestRecordedJobWritesRuns(t *testing.T) {
+	db := newTestDB(t)
+
+	errTimeout := errors.New("timeout")
+	job := &recordedJob{
+		Job: &stubJob{description: `{"fund":1}`, errs: []error{errTimeout, nil}},
+		db:  db,
+		key: quartz.NewJobKeyWithGroup("crawl", "CrawlPMFFunds"),
+	}
+	ctx := context.Background()
+	if err := job.Execute(ctx); !errors.Is(err, errTimeout) {
+		t.Fatalf("first Execute() returned %v, want %v", err, errTimeout)
+	}
+	if err := job.Execute(ctx); err != nil {
+		t.Fatalf("second Execute() returned %v", err)
+	}
+
+	var runs []JobRun
+	if err := db.Order("id").Find(&runs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("recorded %d runs, want 2", len(runs))
+	}
+	if runs[0].Attempt != 1 || runs[0].Outcome != RunFailed || runs[0].Error != "timeout" {
+		t.Errorf("first run = %+v, want a failed attempt 1", runs[0])
+	}
+	if runs[1].Attempt != 2 || runs[1].Outcome != RunCompleted || runs[1].Error != "" {
+		t.Errorf("second run = %+v, want a completed attempt 2", runs[1])
+	}
+	if runs[1].JobGroup != "CrawlPMFFunds" || runs[1].JobDescription != `{"fund":1}` {
+		t.Errorf("second run = %+v, want the job's group and description", runs[1])
+	}
+}
+
+type funcJob struct {
+	stubJob
+	execute func(context.Context) error
+}
+
+func (j *funcJob) Execute(ctx context.Context) error { return j.execute(ctx) }
+
+func TestRecordedJobReportsProgress(t *testing.T) {
+	db := newTestDB(t)
+
+	var during JobRun
+	var key string
+	job := &recordedJob{
+		Job: &funcJob{execute: func(ctx context.Context) error {
+			key = RunKey(ctx)
+			ReportStage(ctx, "saving")
+			ReportProgress(ctx, 1, 3)
+			ReportProgress(ctx, 3, 3)
+			return db.First(&during).Error
+		}},
+		db:  db,
+		key: quartz.NewJobKeyWithGroup("sync", "MFSync"),
+	}
+	if err := job.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := quartz.NewJobKeyWithGroup("sync", "MFSync").String(); key != want {
+		t.Errorf("RunKey() = %q, want %q", key, want)
+	}
+	if during.Outcome != RunRunning || during.Stage != "saving" || during.ProgressDone != 3 || during.ProgressTotal != 3 {
+		t.Errorf("run while executing = %+v, want running at saving 3/3", during)
+	}
+
+	var run JobRun
+	if err := db.First(&run).Error; err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != during.ID || run.Outcome != RunCompleted || run.ProgressDone != 3 {
+		t.Errorf("run = %+v, want the same run completed with its progress", run)
+	}
+
+	// Outside a job reporting does nothing.
+	ReportProgress(context.Background(), 1, 2)
+	if key := RunKey(context.Background()); key != "" {
+		t.Errorf("RunKey() outside a job = %q", key)
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// progressInterval is the least time between two writes of a run's progress.
+const progressInterval = time.Second
+
+type progressKey struct{}
+
+// progress holds the JobRun of a running job and writes the progress it reports.
+type progress struct {
+	db *gorm.DB
+
+	mtx   sync.Mutex
+	run   *JobRun
+	saved time.Time
+}
+
+// ReportProgress records that done of total items of the running job are processed.
+// It does nothing when ctx is not the context of a job run by the scheduler.
+func ReportProgress(ctx context.Context, done, total int64) {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.update(ctx, func(run *JobRun) {
+			run.ProgressDone = done
+			run.ProgressTotal = total
+		}, done == total)
+	}
+}
+
+// RunID returns the ID of the JobRun of the running job, or 0 when ctx is not the
+// context of a job run by the scheduler.
+func RunID(ctx context.Context) uint {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return p.run.ID
+	}
+	return 0
+}
+
+// RunKey returns the key of the running job, or "" when ctx is not the context of a
+// job run by the scheduler.
+func RunKey(ctx context.Context) string {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return p.run.JobKey
+	}
+	return ""
+}
+
+// ReportStage records the stage the running job is in and resets its progress.
+func ReportStage(ctx context.Context, stage string) {
+	if p, ok := ctx.Value(progressKey{}).(*progress); ok {
+		p.update(ctx, func(run *JobRun) {
+			run.Stage = stage
+			run.ProgressDone = 0
+			run.ProgressTotal = 0
+		}, true)
+	}
+}
+
+// update applies set to the run and writes it, at most once per progressInterval
+// unless force is set. Jobs may report often, e.g. once per item.
+func (p *progress) update(ctx context.Context, set func(*JobRun), force bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	set(p.run)
+	if p.run.ID == 0 || (!force && time.Since(p.saved) < progressInterval) {
+		return
+	}
+	p.saved = time.Now()
+	err := p.db.WithContext(ctx).Model(p.run).
+		Select("stage", "progress_done", "progress_total").
+		Updates(p.run).Error
+	if err != nil {
+		log.Error().Err(err).Str("key", p.run.JobKey).Msg("Error while recording job progress")
+	}
+}
+
+// finish writes the outcome of the run with its last progress.
+func (p *progress) finish(ctx context.Context, err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	run := p.run
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Outcome = RunCompleted
+	if err != nil {
+		run.Outcome = RunFailed
+		run.Error = err.Error()
+	}
+
+	// Record the run even when the scheduler is shutting down.
+	if err := p.db.WithContext(context.WithoutCancel(ctx)).Save(run).Error; err != nil {
+		log.Error().Err(err).Str("key", run.JobKey).Msg("Error while recording job run")
+	}
+}
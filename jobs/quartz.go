@@ -1,38 +1,95 @@
 package jobs
 
 import (
-	"alpha2/crawler"
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var Scheduler quartz.Scheduler
+const (
+	// leaseDuration is how long a claimed job stays with its instance without a heartbeat.
+	leaseDuration     = 2 * time.Minute
+	heartbeatInterval = 30 * time.Second
+	outdatedThreshold = 7 * 24 * time.Hour
+)
+
+var (
+	Scheduler quartz.Scheduler
+	queue     *GormJobQueue
+)
 
-func Init() {
-	jobQueue := NewGormJobQueue(crawler.Conn())
+// Init creates the global Scheduler backed by the scheduled_jobs table in db.
+func Init(db *gorm.DB) {
+	queue = NewGormJobQueue(db)
 	Scheduler, _ = quartz.NewStdScheduler(
-		quartz.WithQueue(jobQueue, &sync.Mutex{}),
+		quartz.WithQueue(queue, &sync.Mutex{}),
 		quartz.WithLogger(&JobZeroLogger{}),
-		quartz.WithOutdatedThreshold(time.Hour*24*7),
+		quartz.WithOutdatedThreshold(outdatedThreshold),
 		quartz.WithRetryInterval(time.Minute*5),
 		quartz.WithWorkerLimit(10),
 	)
 }
 
-// GormJobQueue implements the JobQueue interface using Gorm and PostgreSQL.
+// Start starts the Scheduler and, until ctx is done, renews the leases of the jobs it
+// claimed and requeues jobs whose instance stopped renewing theirs.
+func Start(ctx context.Context) {
+	Scheduler.Start(ctx)
+	go queue.keepLeases(ctx, Wake)
+}
+
+// Wake makes the Scheduler re-read the head of the queue after jobs were changed
+// without going through it.
+func Wake() {
+	if sched, ok := Scheduler.(*quartz.StdScheduler); ok {
+		sched.Reset()
+	}
+}
+
+// GormJobQueue implements the JobQueue interface using Gorm. Several instances may
+// share the scheduled_jobs table: Pop claims a due job by marking it running under a
+// lease owned by this instance, and the job is deleted once its execution finishes.
 type GormJobQueue struct {
-	db  *gorm.DB
-	mtx sync.Mutex
+	db    *gorm.DB
+	mtx   sync.Mutex
+	owner string
 }
 
 // NewGormJobQueue initializes a new GormJobQueue.
 func NewGormJobQueue(db *gorm.DB) *GormJobQueue {
-	return &GormJobQueue{db: db}
+	return &GormJobQueue{db: db, owner: instanceID()}
+}
+
+// instanceID identifies this process as a lease owner.
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// scheduled selects the jobs waiting to run, as opposed to those claimed by an instance
+// or paused.
+func (gq *GormJobQueue) scheduled() *gorm.DB {
+	return gq.db.Where("job_status = ?", StatusScheduled)
+}
+
+// waitingKey is the condition of idx_scheduled_jobs_waiting_key, which allows one
+// waiting job per key.
+const waitingKey = "job_status != 'running' AND deleted_at IS NULL"
+
+// waiting selects the jobs not claimed by an instance, whether paused, blocked or not.
+func (gq *GormJobQueue) waiting() *gorm.DB {
+	return gq.db.Where("job_status IN ?", []string{StatusScheduled, StatusPaused, StatusBlocked})
 }
 
 // Push inserts a new scheduled job into the queue.
@@ -40,56 +97,228 @@ func (gq *GormJobQueue) Push(job quartz.ScheduledJob) error {
 	gq.mtx.Lock()
 	defer gq.mtx.Unlock()
 
-	options := job.JobDetail().Options()
-	jobData, err := json.Marshal(options) // Replace with serialized job options if needed
+	jobDetail := job.JobDetail()
+	jobData, err := jobPayload(jobDetail.Job())
 	if err != nil {
 		return err
 	}
-	jobDetail := job.JobDetail()
 	scheduledJob := ScheduledJob{
-		JobKey:             jobDetail.JobKey().String(),
-		JobName:            jobDetail.JobKey().Name(),
-		JobGroup:           jobDetail.JobKey().Group(),
-		JobDescription:     jobDetail.Job().Description(),
-		JobData:            string(jobData),   // Replace with serialized job data if needed
-		NxtRunTime:         job.NextRunTime(), // int64 Unix timestamp
-		JobStatus:          "scheduled",
-		TriggerDescription: job.Trigger().Description(),
-	}
-
-	// Check if the job already exists
-	var existingJob ScheduledJob
-	if err := gq.db.Where("job_key = ?", scheduledJob.JobKey).First(&existingJob).Error; err == nil {
-		if jobDetail.Options().Replace {
-			// Update the existing job
-			return gq.db.Model(&existingJob).Updates(scheduledJob).Error
-		}
-		return quartz.ErrJobAlreadyExists
+		JobKey:         jobDetail.JobKey().String(),
+		JobName:        jobDetail.JobKey().Name(),
+		JobGroup:       jobDetail.JobKey().Group(),
+		JobDescription: jobDetail.Job().Description(),
+		JobData:        jobData,
+		NxtRunTime:     job.NextRunTime(), // int64 Unix timestamp
+		JobStatus:      StatusScheduled,
+	}
+	if err := scheduledJob.SetTrigger(job.Trigger()); err != nil {
+		return err
+	}
+
+	var paused int64
+	if err := gq.db.Model(&PausedGroup{}).Where("job_group = ?", scheduledJob.JobGroup).Count(&paused).Error; err != nil {
+		return err
+	}
+	if paused > 0 {
+		scheduledJob.JobStatus = StatusPaused
+	}
+	var dependencies int64
+	if err := gq.db.Model(&JobDependency{}).Where("job_key = ?", scheduledJob.JobKey).Count(&dependencies).Error; err != nil {
+		return err
+	}
+	if dependencies > 0 {
+		scheduledJob.JobStatus = StatusBlocked
 	}
 
-	// Insert the new job
-	return gq.db.Create(&scheduledJob).Error
+	// Insert the job unless one with the same key is waiting to run, or replace that
+	// one. A running job with the same key does not count, as the scheduler pushes the
+	// next run of a job while the current one executes. The check is the unique index
+	// on the keys of waiting jobs, so it holds across instances.
+	onConflict := clause.OnConflict{
+		Columns:     []clause.Column{{Name: "job_key"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: waitingKey}}},
+		DoNothing:   true,
+	}
+	if jobDetail.Options().Replace {
+		// Update the existing job, including columns reset to their zero value
+		onConflict.DoNothing = false
+		onConflict.DoUpdates = clause.AssignmentColumns([]string{"updated_at", "job_description", "job_data",
+			"nxt_run_time", "job_status", "trigger_description",
+			"trigger_type", "trigger_interval", "trigger_cron", "trigger_timezone", "trigger_expired"})
+	}
+	result := gq.db.Clauses(onConflict).Create(&scheduledJob)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return quartz.ErrJobAlreadyExists
+	}
+	return nil
 }
 
-// Pop removes and returns the next scheduled job from the queue.
+// Pop claims and returns the next due job. Jobs that are not due yet are left for
+// a later tick, so an instance never takes a job early because another one claimed
+// the job it was waiting for.
 func (gq *GormJobQueue) Pop() (quartz.ScheduledJob, error) {
 	gq.mtx.Lock()
 	defer gq.mtx.Unlock()
 
 	var scheduledJob ScheduledJob
-	if err := gq.db.Order("nxt_run_time").First(&scheduledJob).Error; err != nil {
+	err := gq.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		claim := tx.Where("job_status = ? AND nxt_run_time <= ?", StatusScheduled, now.UnixNano()).Order("nxt_run_time")
+		if tx.Dialector.Name() == "postgres" {
+			claim = claim.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := claim.First(&scheduledJob).Error; err != nil {
+			return err
+		}
+
+		// The scheduler reschedules outdated jobs without running them, so nothing would
+		// release their lease. The margin keeps the check on the safe side of the
+		// scheduler's own: a job dropped here but run anyway only loses its lease.
+		if scheduledJob.NxtRunTime < now.Add(-outdatedThreshold+time.Minute).UnixNano() {
+			return tx.Delete(&scheduledJob).Error
+		}
+
+		scheduledJob.JobStatus = StatusRunning
+		scheduledJob.LeaseOwner = gq.owner
+		scheduledJob.LeaseExpiresAt = now.Add(leaseDuration).UnixNano()
+		scheduledJob.HeartbeatAt = now.UnixNano()
+		return tx.Model(&scheduledJob).
+			Select("job_status", "lease_owner", "lease_expires_at", "heartbeat_at").
+			Updates(&scheduledJob).Error
+	})
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, quartz.ErrQueueEmpty
 		}
 		return nil, err
 	}
 
-	// Remove the job from the queue
-	if err := gq.db.Delete(&scheduledJob).Error; err != nil {
-		return nil, err
+	scheduledJob.queue = gq
+	return &scheduledJob, nil
+}
+
+// claimed selects the job with the given ID if this instance still holds its claim.
+func (gq *GormJobQueue) claimed(tx *gorm.DB, id uint) *gorm.DB {
+	return tx.Where("id = ? AND job_status = ? AND lease_owner = ?", id, StatusRunning, gq.owner)
+}
+
+// done removes a job claimed by this instance once its execution has finished. A job
+// whose last attempt failed moves to the dead jobs.
+func (gq *GormJobQueue) done(id uint, attempts int, err error) {
+	if err == nil {
+		if err := gq.claimed(gq.db, id).Delete(&ScheduledJob{}).Error; err != nil {
+			log.Error().Err(err).Uint("id", id).Msg("Error while releasing job lease")
+		}
+		if err := gq.resolveDependencies(); err != nil {
+			log.Error().Err(err).Msg("Error while resolving job dependencies")
+		}
+		return
 	}
 
-	return &scheduledJob, nil
+	txErr := gq.db.Transaction(func(tx *gorm.DB) error {
+		var scheduledJob ScheduledJob
+		if err := gq.claimed(tx, id).First(&scheduledJob).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// The claim was lost and the job requeued elsewhere.
+				return nil
+			}
+			return err
+		}
+		dead := &DeadJob{
+			JobKey:         scheduledJob.JobKey,
+			JobName:        scheduledJob.JobName,
+			JobGroup:       scheduledJob.JobGroup,
+			JobDescription: scheduledJob.JobDescription,
+			JobData:        scheduledJob.JobData,
+			Attempts:       attempts,
+			Error:          err.Error(),
+			FailedAt:       time.Now(),
+		}
+		if err := tx.Create(dead).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&scheduledJob).Error
+	})
+	if txErr != nil {
+		log.Error().Err(txErr).Uint("id", id).Msg("Error while moving job to dead jobs")
+	}
+}
+
+// heartbeat extends the leases of the jobs claimed by this instance.
+func (gq *GormJobQueue) heartbeat(now time.Time) error {
+	return gq.db.Model(&ScheduledJob{}).
+		Where("job_status = ? AND lease_owner = ?", StatusRunning, gq.owner).
+		Updates(map[string]any{
+			"lease_expires_at": now.Add(leaseDuration).UnixNano(),
+			"heartbeat_at":     now.UnixNano(),
+		}).Error
+}
+
+// recoverExpired returns jobs whose lease has expired, because the instance running
+// them died, to the queue so they run again. As only one job per key may wait, an
+// expired job is dropped instead when its key is already queued, such as by the next
+// run of a recurring job, and of several expired runs only the last is requeued.
+func (gq *GormJobQueue) recoverExpired(now time.Time) (int64, error) {
+	var recovered int64
+	err := gq.db.Transaction(func(tx *gorm.DB) error {
+		expired := func() *gorm.DB {
+			return tx.Model(&ScheduledJob{}).Where("job_status = ? AND lease_expires_at < ?", StatusRunning, now.UnixNano())
+		}
+		queued := tx.Model(&ScheduledJob{}).Select("job_key").Where("job_status != ?", StatusRunning)
+		if err := expired().Where("job_key IN (?)", queued).Delete(&ScheduledJob{}).Error; err != nil {
+			return err
+		}
+		later := tx.Table("scheduled_jobs AS later").Select("1").
+			Where("later.job_key = scheduled_jobs.job_key AND later.id > scheduled_jobs.id").
+			Where("later.job_status = ? AND later.lease_expires_at < ? AND later.deleted_at IS NULL", StatusRunning, now.UnixNano())
+		if err := expired().Where("EXISTS (?)", later).Delete(&ScheduledJob{}).Error; err != nil {
+			return err
+		}
+
+		result := expired().Updates(map[string]any{
+			"job_status":       StatusScheduled,
+			"lease_owner":      "",
+			"lease_expires_at": 0,
+		})
+		recovered = result.RowsAffected
+		return result.Error
+	})
+	return recovered, err
+}
+
+// keepLeases runs heartbeat, recoverExpired and resolveDependencies every
+// heartbeatInterval until ctx is done. wake is called after every round, so the
+// scheduler also picks up recovered and unblocked jobs and jobs queued or triggered
+// by other instances.
+func (gq *GormJobQueue) keepLeases(ctx context.Context, wake func()) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if err := gq.heartbeat(now); err != nil {
+			log.Error().Err(err).Msg("Error while renewing job leases")
+		}
+		n, err := gq.recoverExpired(now)
+		if err != nil {
+			log.Error().Err(err).Msg("Error while recovering expired jobs")
+		} else if n > 0 {
+			log.Warn().Int64("count", n).Msg("Requeued jobs with expired leases")
+		}
+		// Catches prerequisites that finished while their dependents were being queued.
+		if err := gq.resolveDependencies(); err != nil {
+			log.Error().Err(err).Msg("Error while resolving job dependencies")
+		}
+		wake()
+	}
 }
 
 // Head returns the first scheduled job without removing it from the queue.
@@ -98,7 +327,7 @@ func (gq *GormJobQueue) Head() (quartz.ScheduledJob, error) {
 	defer gq.mtx.Unlock()
 
 	var scheduledJob ScheduledJob
-	if err := gq.db.Order("nxt_run_time").First(&scheduledJob).Error; err != nil {
+	if err := gq.scheduled().Order("nxt_run_time").First(&scheduledJob).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, quartz.ErrQueueEmpty
 		}
@@ -114,7 +343,7 @@ func (gq *GormJobQueue) Get(jobKey *quartz.JobKey) (quartz.ScheduledJob, error)
 	defer gq.mtx.Unlock()
 
 	var scheduledJob ScheduledJob
-	if err := gq.db.Where("job_key = ?", jobKey.String()).First(&scheduledJob).Error; err != nil {
+	if err := gq.scheduled().Where("job_key = ?", jobKey.String()).First(&scheduledJob).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, quartz.ErrJobNotFound
 		}
@@ -130,7 +359,7 @@ func (gq *GormJobQueue) Remove(jobKey *quartz.JobKey) (quartz.ScheduledJob, erro
 	defer gq.mtx.Unlock()
 
 	var scheduledJob ScheduledJob
-	if err := gq.db.Where("job_key = ?", jobKey.String()).First(&scheduledJob).Error; err != nil {
+	if err := gq.scheduled().Where("job_key = ?", jobKey.String()).First(&scheduledJob).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, quartz.ErrJobNotFound
 		}
@@ -151,7 +380,7 @@ func (gq *GormJobQueue) ScheduledJobs(matchers []quartz.Matcher[quartz.Scheduled
 	defer gq.mtx.Unlock()
 
 	var scheduledJobs []ScheduledJob
-	if err := gq.db.Find(&scheduledJobs).Error; err != nil {
+	if err := gq.scheduled().Find(&scheduledJobs).Error; err != nil {
 		return nil, err
 	}
 
@@ -173,13 +402,13 @@ func (gq *GormJobQueue) ScheduledJobs(matchers []quartz.Matcher[quartz.Scheduled
 	return result, nil
 }
 
-// Size returns the size of the job queue.
+// Size returns the number of jobs waiting to run.
 func (gq *GormJobQueue) Size() (int, error) {
 	gq.mtx.Lock()
 	defer gq.mtx.Unlock()
 
 	var count int64
-	if err := gq.db.Model(&ScheduledJob{}).Count(&count).Error; err != nil {
+	if err := gq.scheduled().Model(&ScheduledJob{}).Count(&count).Error; err != nil {
 		return 0, err
 	}
 	return int(count), nil
@@ -0,0 +1,285 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a separate database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&ScheduledJob{}, &JobRun{}, &PausedGroup{}, &DeadJob{}, &JobDependency{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insertJob(t *testing.T, db *gorm.DB, name string, runAt time.Time) {
+	t.Helper()
+	job := &ScheduledJob{
+		JobKey:     "CrawlPMFFunds" + quartz.Sep + name,
+		JobName:    name,
+		JobGroup:   "CrawlPMFFunds",
+		NxtRunTime: runAt.UnixNano(),
+		JobStatus:  StatusScheduled,
+	}
+	if err := db.Create(job).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPopClaimsOnlyDueJobsOnce(t *testing.T) {
+	db := newTestDB(t)
+	insertJob(t, db, "due", time.Now().Add(-time.Second))
+	insertJob(t, db, "later", time.Now().Add(time.Hour))
+
+	// Two queues on one table stand in for two server instances.
+	a, b := NewGormJobQueue(db), NewGormJobQueue(db)
+	job, err := a.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := job.(*ScheduledJob).JobName; got != "due" {
+		t.Errorf("Pop() claimed %q, want the due job", got)
+	}
+	if _, err := b.Pop(); !errors.Is(err, quartz.ErrQueueEmpty) {
+		t.Errorf("second Pop() returned %v, want ErrQueueEmpty", err)
+	}
+	if size, _ := b.Size(); size != 1 {
+		t.Errorf("Size() = %d with one job claimed, want 1", size)
+	}
+
+	// Finishing a job claimed by another instance is a no-op.
+	claimed := job.(*ScheduledJob)
+	b.done(claimed.ID, 1, nil)
+	var running int64
+	db.Model(&ScheduledJob{}).Where("job_status = ?", StatusRunning).Count(&running)
+	if running != 1 {
+		t.Fatalf("%d running jobs after a foreign release, want 1", running)
+	}
+	a.done(claimed.ID, 1, nil)
+	db.Model(&ScheduledJob{}).Where("job_status = ?", StatusRunning).Count(&running)
+	if running != 0 {
+		t.Errorf("%d running jobs after done, want 0", running)
+	}
+}
+
+func TestRecoverExpiredLeases(t *testing.T) {
+	db := newTestDB(t)
+	insertJob(t, db, "crashed", time.Now().Add(-time.Second))
+
+	dead, alive := NewGormJobQueue(db), NewGormJobQueue(db)
+	if _, err := dead.Pop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A lease kept alive by heartbeats is not recovered.
+	now := time.Now()
+	if n, err := alive.recoverExpired(now.Add(leaseDuration / 2)); err != nil || n != 0 {
+		t.Fatalf("recoverExpired() = %d, %v before the lease expired, want 0", n, err)
+	}
+	if err := dead.heartbeat(now.Add(leaseDuration / 2)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := alive.recoverExpired(now.Add(leaseDuration)); n != 0 {
+		t.Fatalf("recoverExpired() = %d after a heartbeat, want 0", n)
+	}
+
+	n, err := alive.recoverExpired(now.Add(2 * leaseDuration))
+	if err != nil || n != 1 {
+		t.Fatalf("recoverExpired() = %d, %v after the lease expired, want 1", n, err)
+	}
+	job, err := alive.Pop()
+	if err != nil {
+		t.Fatalf("Pop() of the recovered job returned %v", err)
+	}
+	if got := job.(*ScheduledJob).JobName; got != "crashed" {
+		t.Errorf("Pop() claimed %q, want the recovered job", got)
+	}
+}
+
+func TestRecoverExpiredDropsJobQueuedAgain(t *testing.T) {
+	db := newTestDB(t)
+	insertJob(t, db, "recurring", time.Now().Add(-time.Second))
+
+	dead := NewGormJobQueue(db)
+	if _, err := dead.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	// The scheduler queued the next run while the first one was running.
+	insertJob(t, db, "recurring", time.Now().Add(time.Hour))
+
+	n, err := dead.recoverExpired(time.Now().Add(2 * leaseDuration))
+	if err != nil || n != 0 {
+		t.Fatalf("recoverExpired() = %d, %v with the next run queued, want 0", n, err)
+	}
+	var jobs []ScheduledJob
+	db.Find(&jobs)
+	if len(jobs) != 1 || jobs[0].JobStatus != StatusScheduled {
+		t.Errorf("jobs = %+v, want only the next run", jobs)
+	}
+}
+
+type payloadJob struct {
+	UID     string
+	ForDate string
+}
+
+func (j *payloadJob) Execute(context.Context) error { return nil }
+func (j *payloadJob) Description() string           { return j.UID }
+func (j *payloadJob) SetDescription(string)         {}
+
+func TestNewJob(t *testing.T) {
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+
+	job, err := NewJob("payloadJob", []byte(`{"UID": "PM1", "ForDate": "2024-01-31"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := job.(*payloadJob); got.UID != "PM1" || got.ForDate != "2024-01-31" {
+		t.Errorf("NewJob() = %+v, want the payload fields set", got)
+	}
+
+	if _, err := NewJob("payloadJob", []byte(`{"Uid2": "PM1"}`)); err == nil {
+		t.Error("NewJob() accepted a payload with an unknown field")
+	}
+	if _, err := NewJob("missing", nil); !errors.Is(err, ErrUnknownGroup) {
+		t.Errorf("NewJob() of an unregistered group returned %v, want ErrUnknownGroup", err)
+	}
+}
+
+// detailedJob is a job pushed with its own JobDetail, as the scheduler pushes new jobs.
+type detailedJob struct {
+	detail  *quartz.JobDetail
+	trigger quartz.Trigger
+}
+
+func (j detailedJob) JobDetail() *quartz.JobDetail { return j.detail }
+func (j detailedJob) Trigger() quartz.Trigger      { return j.trigger }
+func (j detailedJob) NextRunTime() int64           { return time.Now().Add(-time.Second).UnixNano() }
+
+func TestPushKeepsJobPayload(t *testing.T) {
+	db := newTestDB(t)
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+
+	q := NewGormJobQueue(db)
+	job := &payloadJob{UID: "PM1", ForDate: "2024-01-31"}
+	detail := quartz.NewJobDetail(job, quartz.NewJobKeyWithGroup("a", "payloadJob"))
+	if err := q.Push(detailedJob{detail: detail, trigger: quartz.NewRunOnceTrigger(0)}); err != nil {
+		t.Fatal(err)
+	}
+	popped, err := q.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The description holds only the UID; the payload brings back the rest.
+	got := popped.JobDetail().Job().(*recordedJob).Job.(*payloadJob)
+	if got.UID != "PM1" || got.ForDate != "2024-01-31" {
+		t.Errorf("popped job = %+v, want the pushed fields", got)
+	}
+}
+
+func TestPushIntoPausedGroup(t *testing.T) {
+	db := newTestDB(t)
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+	if err := db.Create(&PausedGroup{JobGroup: "payloadJob"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewGormJobQueue(db)
+	if err := q.Push(&ScheduledJob{JobName: "a", JobGroup: "payloadJob", TriggerDescription: quartz.NewRunOnceTrigger(0).Description()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Pop(); !errors.Is(err, quartz.ErrQueueEmpty) {
+		t.Errorf("Pop() returned %v for a job of a paused group, want ErrQueueEmpty", err)
+	}
+}
+
+func TestDoneMovesFailedJobToDead(t *testing.T) {
+	db := newTestDB(t)
+	insertJob(t, db, "failing", time.Now().Add(-time.Second))
+	if err := db.Model(&ScheduledJob{}).Where("job_name = ?", "failing").Update("job_data", `{"UID":"PM1"}`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewGormJobQueue(db)
+	job, err := q.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.done(job.(*ScheduledJob).ID, maxRetries+1, errors.New("503 from SEBI"))
+
+	var queued int64
+	db.Model(&ScheduledJob{}).Count(&queued)
+	if queued != 0 {
+		t.Errorf("%d jobs queued after the last attempt failed, want 0", queued)
+	}
+	var dead []DeadJob
+	if err := db.Find(&dead).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].JobName != "failing" || dead[0].Error != "503 from SEBI" || dead[0].Attempts != maxRetries+1 ||
+		dead[0].JobData != `{"UID":"PM1"}` {
+		t.Errorf("dead jobs = %+v, want the failed job with its error and payload", dead)
+	}
+}
+
+// replacingJob is pushed with the Replace option.
+type replacingJob struct{ *ScheduledJob }
+
+func (j replacingJob) JobDetail() *quartz.JobDetail {
+	detail := j.ScheduledJob.JobDetail()
+	return quartz.NewJobDetailWithOptions(detail.Job(), detail.JobKey(), &quartz.JobDetailOptions{Replace: true})
+}
+
+func TestPushQueuesOneWaitingJobPerKey(t *testing.T) {
+	db := newTestDB(t)
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+	trigger := quartz.NewRunOnceTrigger(0).Description()
+	due := time.Now().Add(-time.Second).UnixNano()
+
+	// Two queues on one table stand in for two server instances.
+	a, b := NewGormJobQueue(db), NewGormJobQueue(db)
+	if err := a.Push(&ScheduledJob{JobName: "a", JobGroup: "payloadJob", TriggerDescription: trigger, NxtRunTime: due + 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := b.Push(&ScheduledJob{JobName: "a", JobGroup: "payloadJob", TriggerDescription: trigger, NxtRunTime: due + 2})
+	if !errors.Is(err, quartz.ErrJobAlreadyExists) {
+		t.Fatalf("second Push() returned %v, want ErrJobAlreadyExists", err)
+	}
+	err = b.Push(replacingJob{&ScheduledJob{JobName: "a", JobGroup: "payloadJob", TriggerDescription: trigger, NxtRunTime: due}})
+	if err != nil {
+		t.Fatalf("Push() with Replace returned %v", err)
+	}
+	var queued []ScheduledJob
+	db.Find(&queued)
+	if len(queued) != 1 || queued[0].NxtRunTime != due {
+		t.Fatalf("queued jobs = %+v, want the replaced job", queued)
+	}
+
+	// A running job does not keep its next run from being queued.
+	if _, err := a.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Push(&ScheduledJob{JobName: "a", JobGroup: "payloadJob", TriggerDescription: trigger, NxtRunTime: due}); err != nil {
+		t.Errorf("Push() while the job runs returned %v", err)
+	}
+}
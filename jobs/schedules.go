@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// Schedule declares a job that runs whenever its cron expression fires. Expressions
+// start with a seconds field: "0 0 2 21 * *" is 02:00 UTC on the 21st of each month.
+type Schedule struct {
+	Name    string
+	Group   string
+	Cron    string
+	Payload string // JSON fields of the job, as accepted by NewJob
+}
+
+// ScheduleRecurring queues every schedule under its name with a cron trigger. A
+// schedule already queued is replaced, so changes to its expression or payload take
+// effect the next time the schedules are loaded.
+func ScheduleRecurring(schedules []Schedule) error {
+	for _, schedule := range schedules {
+		job, err := NewJob(schedule.Group, []byte(schedule.Payload))
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+		trigger, err := quartz.NewCronTrigger(schedule.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+
+		jobDetail := quartz.NewJobDetailWithOptions(job, quartz.NewJobKeyWithGroup(schedule.Name, schedule.Group),
+			&quartz.JobDetailOptions{
+				MaxRetries:    maxRetries,
+				RetryInterval: time.Minute * 5,
+				Replace:       true,
+				Suspended:     false,
+			})
+		if err := Scheduler.ScheduleJob(jobDetail, trigger); err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+func TestScheduleRecurringReplaces(t *testing.T) {
+	db := newTestDB(t)
+	Scheduler, _ = quartz.NewStdScheduler(quartz.WithQueue(NewGormJobQueue(db), &sync.Mutex{}))
+	RegisterJob("payloadJob", func() Job { return &payloadJob{} })
+
+	schedule := Schedule{Name: "nightly", Group: "payloadJob", Cron: "0 0 1 * * *", Payload: `{"UID": "PM1"}`}
+	if err := ScheduleRecurring([]Schedule{schedule}); err != nil {
+		t.Fatal(err)
+	}
+	schedule.Cron = "0 0 2 * * *"
+	if err := ScheduleRecurring([]Schedule{schedule}); err != nil {
+		t.Fatal(err)
+	}
+
+	var queued []ScheduledJob
+	if err := db.Find(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("%d jobs queued after scheduling twice, want 1", len(queued))
+	}
+	trigger, ok := queued[0].Trigger().(*quartz.CronTrigger)
+	if !ok {
+		t.Fatalf("Trigger() = %T, want a cron trigger", queued[0].Trigger())
+	}
+	if trigger.Description() != "CronTrigger::0 0 2 * * *::UTC" {
+		t.Errorf("Trigger() = %q, want the replaced expression", trigger.Description())
+	}
+
+	schedule.Group = "missing"
+	if err := ScheduleRecurring([]Schedule{schedule}); err == nil {
+		t.Error("ScheduleRecurring() accepted an unregistered group")
+	}
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Cron time zones must load on hosts without a zoneinfo database.
+
+	"github.com/reugn/go-quartz/quartz"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TriggerRunOnce = "run_once"
+	TriggerSimple  = "simple"
+	TriggerCron    = "cron"
+)
+
+// SetTrigger stores t in the trigger columns of sj. Only the trigger types of
+// go-quartz are supported.
+func (sj *ScheduledJob) SetTrigger(t quartz.Trigger) error {
+	sj.TriggerDescription = t.Description()
+	sj.TriggerInterval, sj.TriggerCron, sj.TriggerTimezone, sj.TriggerExpired = 0, "", "", false
+
+	switch t := t.(type) {
+	case *quartz.RunOnceTrigger:
+		sj.TriggerType = TriggerRunOnce
+		sj.TriggerInterval = int64(t.Delay)
+		sj.TriggerExpired = t.Expired
+	case *quartz.SimpleTrigger:
+		sj.TriggerType = TriggerSimple
+		sj.TriggerInterval = int64(t.Interval)
+	case *quartz.CronTrigger:
+		// The expression and location are only exposed through the description,
+		// "CronTrigger::<expression>::<location>".
+		opts := strings.Split(t.Description(), quartz.Sep)
+		if len(opts) != 3 {
+			return fmt.Errorf("unexpected cron trigger description %q", t.Description())
+		}
+		sj.TriggerType = TriggerCron
+		sj.TriggerCron = opts[1]
+		sj.TriggerTimezone = opts[2]
+	default:
+		return fmt.Errorf("unsupported trigger type %T", t)
+	}
+	return nil
+}
+
+// Trigger implements quartz.ScheduledJob.
+func (sj *ScheduledJob) Trigger() quartz.Trigger {
+	switch sj.TriggerType {
+	case TriggerRunOnce:
+		return &quartz.RunOnceTrigger{
+			Delay:   time.Duration(sj.TriggerInterval),
+			Expired: sj.TriggerExpired,
+		}
+	case TriggerSimple:
+		return quartz.NewSimpleTrigger(time.Duration(sj.TriggerInterval))
+	case TriggerCron:
+		location, err := time.LoadLocation(sj.TriggerTimezone)
+		if err != nil {
+			log.Error().Err(err).Str("key", sj.JobKey).Msg("Error while loading cron time zone, using UTC")
+			location = time.UTC
+		}
+		t, err := quartz.NewCronTriggerWithLoc(sj.TriggerCron, location)
+		if err == nil {
+			return t
+		}
+		log.Error().Err(err).Str("key", sj.JobKey).Msg("Error while parsing cron expression")
+	default:
+		if t := parseTriggerDescription(sj.TriggerDescription); t != nil {
+			return t
+		}
+		log.Error().Str("key", sj.JobKey).Str("trigger", sj.TriggerDescription).Msg("Unknown job trigger")
+	}
+	// Run the job once more rather than never, as the trigger did before it broke.
+	return quartz.NewRunOnceTrigger(time.Second * 5)
+}
+
+// parseTriggerDescription rebuilds a trigger from its description, for jobs queued
+// before the trigger columns existed. It returns nil if the description is invalid.
+func parseTriggerDescription(description string) quartz.Trigger {
+	opts := strings.Split(description, quartz.Sep)
+	switch {
+	case len(opts) == 3 && opts[0] == "CronTrigger":
+		location, err := time.LoadLocation(opts[2])
+		if err != nil {
+			return nil
+		}
+		if t, err := quartz.NewCronTriggerWithLoc(opts[1], location); err == nil {
+			return t
+		}
+	case len(opts) == 2 && opts[0] == "SimpleTrigger":
+		if interval, err := time.ParseDuration(opts[1]); err == nil {
+			return quartz.NewSimpleTrigger(interval)
+		}
+	case len(opts) == 3 && opts[0] == "RunOnceTrigger":
+		if delay, err := time.ParseDuration(opts[1]); err == nil {
+			return &quartz.RunOnceTrigger{Delay: delay, Expired: opts[2] == "expired"}
+		}
+	}
+	return nil
+}
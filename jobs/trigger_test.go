@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+func TestTriggerRoundTrip(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron, err := quartz.NewCronTriggerWithLoc("0 0 2 21 * *", kolkata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	triggers := []quartz.Trigger{
+		quartz.NewRunOnceTrigger(90 * time.Second),
+		&quartz.RunOnceTrigger{Delay: time.Second, Expired: true},
+		quartz.NewSimpleTrigger(time.Hour),
+		cron,
+	}
+	for _, want := range triggers {
+		var sj ScheduledJob
+		if err := sj.SetTrigger(want); err != nil {
+			t.Fatalf("SetTrigger(%s) = %v", want.Description(), err)
+		}
+		got := sj.Trigger()
+		if got.Description() != want.Description() {
+			t.Errorf("Trigger() = %s, want %s", got.Description(), want.Description())
+		}
+
+		prev := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+		gotNext, gotErr := got.NextFireTime(prev)
+		wantNext, wantErr := want.NextFireTime(prev)
+		if gotNext != wantNext || (gotErr == nil) != (wantErr == nil) {
+			t.Errorf("%s: NextFireTime() = %d, %v, want %d, %v", want.Description(), gotNext, gotErr, wantNext, wantErr)
+		}
+	}
+}
+
+func TestTriggerFromLegacyDescription(t *testing.T) {
+	sj := ScheduledJob{TriggerDescription: "CronTrigger::0 0 3 * * *::UTC"}
+	if _, ok := sj.Trigger().(*quartz.CronTrigger); !ok {
+		t.Errorf("Trigger() = %T for a cron description, want a cron trigger", sj.Trigger())
+	}
+}
@@ -0,0 +1,176 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The baseline brings an empty database to the schema previously created by the
+// autoMigrate command. Databases that were already auto-migrated can run it safely.
+func init() {
+	// The models as they were at this version. Later migrations change them, so
+	// the current models must not be used here.
+	type Manager struct {
+		ID            uint64 `gorm:"primarykey"`
+		FundManagerID uint64
+		Name          string
+		Title         string
+		About         string
+		Image         string
+		Email         string
+		Contact       string
+
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		DeletedAt gorm.DeletedAt `gorm:"index"`
+	}
+	type FundManager struct {
+		ID uint64
+
+		Name    string
+		Email   string
+		Contact string
+
+		RegisterNumber string `gorm:"unique"`
+		RegisteredDate *time.Time
+		Address        string
+
+		TotalNoOfClient *float64
+		TotalAUM        *float64
+
+		RefreshedDate *time.Time
+
+		OtherData string `gorm:"type:jsonb"`
+
+		Managers []*Manager
+	}
+	type FundReport struct {
+		ID         uint64
+		FundID     uint64     `gorm:"uniqueIndex:idx_report_date_fund_id"`
+		ReportDate *time.Time `gorm:"uniqueIndex:idx_report_date_fund_id"`
+
+		Month1Returns  *float64
+		Month3Returns  *float64
+		Month6Returns  *float64
+		Yr1Returns     *float64
+		Yr2Returns     *float64
+		Yr3Returns     *float64
+		Yr4Returns     *float64
+		Yr5Returns     *float64
+		OverAllReturns *float64
+
+		OtherData string `gorm:"type:jsonb"`
+	}
+	type Fund struct {
+		ID       uint64
+		Name     string
+		AUM      *float64
+		IsHidden bool `gorm:"not null;default:false;"`
+
+		FundManagers []*FundManager `gorm:"many2many:fund_x_fund_managers"`
+		FundReports  []*FundReport
+
+		Type string
+
+		Month1Returns *float64
+		Month3Returns *float64
+		Month6Returns *float64
+		Yr1Returns    *float64
+		Yr2Returns    *float64
+		Yr3Returns    *float64
+		Yr4Returns    *float64
+		Yr5Returns    *float64
+
+		Yr2Cagr        *float64
+		Yr3Cagr        *float64
+		Yr4Cagr        *float64
+		Yr5Cagr        *float64
+		OverAllReturns *float64
+
+		MaxDrawdown3Yrs *float64
+		MaxDrawdown5Yr  *float64
+		SharpeRatio3Yrs *float64
+		SharpeRatio5Yrs *float64
+
+		OtherData string `gorm:"type:jsonb"`
+	}
+	type CrawlerEvent struct {
+		ID   uint64
+		Data string `gorm:"type:jsonb"`
+	}
+	type MutualFundNav struct {
+		gorm.Model
+		MutualFundDataID uint
+		Nav              *float64
+		Date             *time.Time
+	}
+	type MutualFundData struct {
+		gorm.Model
+		Name   string `gorm:"unique"`
+		NavURl string
+
+		Navs []*MutualFundNav `gorm:"foreignKey:MutualFundDataID"`
+	}
+	type ScheduledJob struct {
+		gorm.Model
+		JobKey             string `gorm:"not null"`
+		JobName            string `gorm:"not null"`
+		JobGroup           string `gorm:"not null"`
+		JobDescription     string `gorm:"not null"`
+		JobData            string `gorm:"type:text"`
+		NxtRunTime         int64  `gorm:"not null"`
+		JobStatus          string `gorm:"not null"`
+		TriggerDescription string `gorm:"not null"`
+	}
+	type Image struct {
+		ID          uint `gorm:"primaryKey"`
+		Filename    string
+		Content     []byte `gorm:"type:bytea"`
+		IsUnused    bool   `gorm:"not null;default:true"`
+		ContentType string
+		UploadedAt  time.Time
+	}
+
+	Register(&Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			if err := PostgresOnly(SQL("CREATE EXTENSION IF NOT EXISTS pg_trgm"))(tx); err != nil {
+				return err
+			}
+
+			models := []any{
+				&FundManager{},
+				&Manager{},
+				&Fund{},
+				&FundReport{},
+				&CrawlerEvent{},
+				&MutualFundData{},
+				&MutualFundNav{},
+				&ScheduledJob{},
+				&Image{},
+			}
+			for _, model := range models {
+				if err := tx.AutoMigrate(model); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&Image{},
+				&ScheduledJob{},
+				&MutualFundNav{},
+				&MutualFundData{},
+				&CrawlerEvent{},
+				&FundReport{},
+				"fund_x_fund_managers",
+				&Fund{},
+				&Manager{},
+				&FundManager{},
+			)
+		},
+	})
+}
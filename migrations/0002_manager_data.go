@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Creates Manager rows for the principal and compliance officers of fund houses that
+// have none yet. Replaces the migrateManagerData command.
+func init() {
+	// The models as they were at this version, with only the columns read or written.
+	type Manager struct {
+		ID            uint64 `gorm:"primarykey"`
+		FundManagerID uint64
+		Name          string
+		Title         string
+		Email         string
+		Contact       string
+
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type FundManager struct {
+		ID      uint64
+		Name    string
+		Email   string
+		Contact string
+
+		OtherData map[string]string `gorm:"serializer:json"`
+
+		Managers []*Manager
+	}
+
+	Register(&Migration{
+		Version: 2,
+		Name:    "manager_data",
+		Up: func(tx *gorm.DB) error {
+			var fundHouses []*FundManager
+			return tx.Model(&FundManager{}).Preload("Managers").FindInBatches(&fundHouses, 100, func(batchTx *gorm.DB, batch int) error {
+				managers := make([]*Manager, 0)
+				for _, fundHouse := range fundHouses {
+					if len(fundHouse.Managers) > 0 {
+						continue
+					}
+
+					managers = append(managers, &Manager{
+						FundManagerID: fundHouse.ID,
+						Name:          fundHouse.Name,
+						Title:         "Principal Officer",
+						Email:         fundHouse.Email,
+						Contact:       fundHouse.Contact,
+					})
+
+					if fundHouse.OtherData != nil && fundHouse.OtherData["ComplianceOfficer"] != "" {
+						managers = append(managers, &Manager{
+							FundManagerID: fundHouse.ID,
+							Name:          fundHouse.OtherData["ComplianceOfficer"],
+							Title:         "Compliance Officer",
+							Email:         fundHouse.OtherData["ComplianceOfficerEmail"],
+						})
+					}
+				}
+				if len(managers) == 0 {
+					return nil
+				}
+				return batchTx.Session(&gorm.Session{NewDB: true}).Create(&managers).Error
+			}).Error
+		},
+	})
+}
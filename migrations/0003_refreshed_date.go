@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Backfills refreshed_date for fund houses crawled before the column existed, so the
+// crawl upsert (which only overwrites older rows) treats them as stale. Replaces the
+// migrateRefrshedData command.
+func init() {
+	Register(&Migration{
+		Version: 3,
+		Name:    "refreshed_date_backfill",
+		Up: func(tx *gorm.DB) error {
+			return tx.Table("fund_managers").
+				Where("refreshed_date IS NULL").
+				Update("refreshed_date", time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)).Error
+		},
+	})
+}
@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Promotes the well-known other_data keys to typed, indexed columns and removes them
+// from other_data. Numeric keys whose value does not parse stay in other_data. SQLite
+// support came later, so only Postgres databases have data to move.
+func init() {
+	// The columns added at this version.
+	type FundManager struct {
+		UID              string `gorm:"not null;default:'';index"`
+		RegistrationName string `gorm:"not null;default:''"`
+		Slug             string `gorm:"not null;default:'';index"`
+		DisplayName      string `gorm:"not null;default:''"`
+		LogoURL          string `gorm:"not null;default:''"`
+		Description      string `gorm:"not null;default:''"`
+	}
+	type Fund struct {
+		Label      string  `gorm:"not null;default:'';index"`
+		OriginalID *uint64 `gorm:"index"`
+	}
+	type FundReport struct {
+		AUM      *float64
+		Strategy string  `gorm:"not null;default:''"`
+		MergedID *uint64 `gorm:"index"`
+		Priority string  `gorm:"not null;default:''"`
+	}
+
+	Register(&Migration{
+		Version: 4,
+		Name:    "typed_metadata",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&FundManager{}, &Fund{}, &FundReport{}); err != nil {
+				return err
+			}
+			return PostgresOnly(SQL(
+				`UPDATE fund_managers SET
+					uid = COALESCE(other_data->>'UID', ''),
+					registration_name = COALESCE(other_data->>'RegistrationName', ''),
+					slug = COALESCE(other_data->>'slug', ''),
+					display_name = COALESCE(other_data->>'display_name', ''),
+					logo_url = COALESCE(other_data->>'logo_url', ''),
+					description = COALESCE(other_data->>'description', ''),
+					other_data = other_data - 'UID' - 'RegistrationName' - 'slug' - 'display_name' - 'logo_url' - 'description'
+				WHERE jsonb_typeof(other_data) = 'object'`,
+				`UPDATE funds SET
+					label = COALESCE(other_data->>'label', ''),
+					original_id = CASE WHEN other_data->>'original_id' ~ '^[0-9]+$' THEN (other_data->>'original_id')::bigint END,
+					other_data = other_data - 'label'
+						- array_remove(ARRAY[CASE WHEN other_data->>'original_id' ~ '^[0-9]+$' THEN 'original_id' END], NULL)
+				WHERE jsonb_typeof(other_data) = 'object'`,
+				`UPDATE fund_reports SET
+					aum = CASE WHEN other_data->>'AUM' ~ '^-?[0-9]+(\.[0-9]+)?$' THEN (other_data->>'AUM')::double precision END,
+					strategy = COALESCE(other_data->>'Strategy', ''),
+					merged_id = CASE WHEN other_data->>'merged_id' ~ '^[0-9]+$' THEN (other_data->>'merged_id')::bigint END,
+					priority = COALESCE(other_data->>'priority', ''),
+					other_data = other_data - 'Strategy' - 'priority'
+						- array_remove(ARRAY[
+							CASE WHEN other_data->>'AUM' ~ '^-?[0-9]+(\.[0-9]+)?$' THEN 'AUM' END,
+							CASE WHEN other_data->>'merged_id' ~ '^[0-9]+$' THEN 'merged_id' END], NULL)
+				WHERE jsonb_typeof(other_data) = 'object'`,
+			))(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			err := PostgresOnly(SQL(
+				`UPDATE fund_managers SET other_data = COALESCE(NULLIF(other_data, 'null'::jsonb), '{}'::jsonb) || jsonb_strip_nulls(jsonb_build_object(
+					'UID', NULLIF(uid, ''),
+					'RegistrationName', NULLIF(registration_name, ''),
+					'slug', NULLIF(slug, ''),
+					'display_name', NULLIF(display_name, ''),
+					'logo_url', NULLIF(logo_url, ''),
+					'description', NULLIF(description, '')))`,
+				`UPDATE funds SET other_data = COALESCE(NULLIF(other_data, 'null'::jsonb), '{}'::jsonb) || jsonb_strip_nulls(jsonb_build_object(
+					'label', NULLIF(label, ''),
+					'original_id', original_id::text))`,
+				`UPDATE fund_reports SET other_data = COALESCE(NULLIF(other_data, 'null'::jsonb), '{}'::jsonb) || jsonb_strip_nulls(jsonb_build_object(
+					'AUM', aum::text,
+					'Strategy', NULLIF(strategy, ''),
+					'merged_id', merged_id::text,
+					'priority', NULLIF(priority, '')))`,
+			))(tx)
+			if err != nil {
+				return err
+			}
+			drops := []struct {
+				model   any
+				columns []string
+			}{
+				{&FundManager{}, []string{"uid", "registration_name", "slug", "display_name", "logo_url", "description"}},
+				{&Fund{}, []string{"label", "original_id"}},
+				{&FundReport{}, []string{"aum", "strategy", "merged_id", "priority"}},
+			}
+			for _, drop := range drops {
+				for _, column := range drop.columns {
+					if err := tx.Migrator().DropColumn(drop.model, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	})
+}
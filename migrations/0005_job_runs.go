@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the job_runs table, which records every execution attempt of a scheduled job.
+func init() {
+	type JobRun struct {
+		ID             uint      `gorm:"primaryKey"`
+		JobKey         string    `gorm:"not null;index"`
+		JobName        string    `gorm:"not null"`
+		JobGroup       string    `gorm:"not null;index"`
+		JobDescription string    `gorm:"type:text"`
+		Attempt        int       `gorm:"not null"`
+		StartedAt      time.Time `gorm:"not null;index"`
+		FinishedAt     time.Time `gorm:"not null"`
+		DurationMs     int64     `gorm:"not null"`
+		Outcome        string    `gorm:"not null;index"`
+		Error          string    `gorm:"type:text"`
+	}
+
+	Register(&Migration{
+		Version: 5,
+		Name:    "job_runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&JobRun{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&JobRun{})
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Adds the lease columns that let several instances share scheduled_jobs, and the
+// index Pop claims due jobs by.
+func init() {
+	type ScheduledJob struct {
+		NxtRunTime     int64  `gorm:"not null;index:idx_scheduled_jobs_claim,priority:2"`
+		JobStatus      string `gorm:"not null;index:idx_scheduled_jobs_claim,priority:1"`
+		LeaseOwner     string `gorm:"not null;default:''"`
+		LeaseExpiresAt int64  `gorm:"not null;default:0"`
+		HeartbeatAt    int64  `gorm:"not null;default:0"`
+	}
+
+	Register(&Migration{
+		Version: 6,
+		Name:    "job_leases",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&ScheduledJob{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&ScheduledJob{}, "idx_scheduled_jobs_claim"); err != nil {
+				return err
+			}
+			for _, column := range []string{"lease_owner", "lease_expires_at", "heartbeat_at"} {
+				if err := m.DropColumn(&ScheduledJob{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds paused_groups, which holds the job groups paused from the admin API.
+func init() {
+	type PausedGroup struct {
+		JobGroup  string `gorm:"primaryKey"`
+		CreatedAt time.Time
+	}
+
+	Register(&Migration{
+		Version: 7,
+		Name:    "paused_job_groups",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&PausedGroup{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&PausedGroup{})
+		},
+	})
+}
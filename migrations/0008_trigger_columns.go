@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"alpha2/jobs"
+
+	"gorm.io/gorm"
+)
+
+// Stores job triggers in typed columns instead of only their description, and fills
+// the columns of queued jobs from their description.
+func init() {
+	type ScheduledJob struct {
+		TriggerType     string `gorm:"not null;default:''"`
+		TriggerInterval int64  `gorm:"not null;default:0"`
+		TriggerCron     string `gorm:"not null;default:''"`
+		TriggerTimezone string `gorm:"not null;default:''"`
+		TriggerExpired  bool   `gorm:"not null;default:false"`
+	}
+
+	Register(&Migration{
+		Version: 8,
+		Name:    "trigger_columns",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&ScheduledJob{}); err != nil {
+				return err
+			}
+
+			var queued []jobs.ScheduledJob
+			if err := tx.Where("trigger_type = ''").Find(&queued).Error; err != nil {
+				return err
+			}
+			for _, job := range queued {
+				if err := job.SetTrigger(job.Trigger()); err != nil {
+					return err
+				}
+				err := tx.Model(&job).
+					Select("trigger_type", "trigger_interval", "trigger_cron", "trigger_timezone", "trigger_expired").
+					Updates(&job).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"trigger_type", "trigger_interval", "trigger_cron", "trigger_timezone", "trigger_expired"} {
+				if err := tx.Migrator().DropColumn(&ScheduledJob{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds dead_jobs, where jobs go once their retries are exhausted.
+func init() {
+	type DeadJob struct {
+		ID             uint      `gorm:"primaryKey"`
+		JobKey         string    `gorm:"not null;index"`
+		JobName        string    `gorm:"not null"`
+		JobGroup       string    `gorm:"not null;index"`
+		JobDescription string    `gorm:"type:text"`
+		Attempts       int       `gorm:"not null"`
+		Error          string    `gorm:"type:text"`
+		FailedAt       time.Time `gorm:"not null;index"`
+	}
+
+	Register(&Migration{
+		Version: 9,
+		Name:    "dead_jobs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&DeadJob{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&DeadJob{})
+		},
+	})
+}
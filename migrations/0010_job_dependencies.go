@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Adds job_dependencies, which tracks the jobs a blocked job waits for.
+func init() {
+	type JobDependency struct {
+		JobKey       string `gorm:"primaryKey"`
+		DependsOnKey string `gorm:"primaryKey;index"`
+	}
+
+	Register(&Migration{
+		Version: 10,
+		Name:    "job_dependencies",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&JobDependency{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&JobDependency{})
+		},
+	})
+}
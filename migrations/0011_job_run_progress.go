@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Adds the progress columns that running jobs update through jobs.ReportProgress and
+// jobs.ReportStage.
+func init() {
+	type JobRun struct {
+		Stage         string `gorm:"not null;default:''"`
+		ProgressDone  int64  `gorm:"not null;default:0"`
+		ProgressTotal int64  `gorm:"not null;default:0"`
+	}
+
+	Register(&Migration{
+		Version: 11,
+		Name:    "job_run_progress",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&JobRun{})
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"stage", "progress_done", "progress_total"} {
+				if err := tx.Migrator().DropColumn(&JobRun{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds fund_reports.provenance, set to crawled for the existing reports, and the
+// tables holding manual report uploads until they are approved.
+func init() {
+	type FundReport struct {
+		Provenance string `gorm:"not null;default:'crawled'"`
+	}
+	type UploadedReport struct {
+		ID             uint64
+		ReportUploadID uint64 `gorm:"index"`
+		ReportDate     time.Time
+
+		AUM            *float64
+		Month1Returns  *float64
+		Month3Returns  *float64
+		Month6Returns  *float64
+		Yr1Returns     *float64
+		Yr2Returns     *float64
+		Yr3Returns     *float64
+		Yr4Returns     *float64
+		Yr5Returns     *float64
+		OverAllReturns *float64
+
+		Skipped bool `gorm:"not null;default:false"`
+	}
+	type ReportUpload struct {
+		ID       uint64
+		FundID   uint64 `gorm:"index"`
+		FileName string `gorm:"not null;default:''"`
+		Status   string `gorm:"not null;default:'pending';index"`
+
+		UploadedBy string `gorm:"not null;default:''"`
+		ReviewedBy string `gorm:"not null;default:''"`
+		Note       string `gorm:"not null;default:''"`
+
+		CreatedAt  time.Time
+		ReviewedAt *time.Time
+
+		Reports []*UploadedReport
+	}
+
+	Register(&Migration{
+		Version: 12,
+		Name:    "report_uploads",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&FundReport{}, &ReportUpload{}, &UploadedReport{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&UploadedReport{}, &ReportUpload{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&FundReport{}, "provenance")
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Lets a fund have a report per month from each origin, as picked between by
+// store.EffectiveReports: fund_reports gains origin, source, crawl_run_id and
+// upload_id, and the unique index on (fund_id, report_date) moves to
+// (fund_id, report_date, origin). Reports copied from merged funds become merged.
+func init() {
+	type FundReport struct {
+		FundID     uint64     `gorm:"uniqueIndex:idx_fund_report_origin"`
+		ReportDate *time.Time `gorm:"uniqueIndex:idx_fund_report_origin"`
+		Origin     string     `gorm:"not null;default:'crawled';uniqueIndex:idx_fund_report_origin"`
+		Source     string     `gorm:"not null;default:''"`
+		CrawlRunID *uint
+		UploadID   *uint64
+	}
+
+	Register(&Migration{
+		Version: 13,
+		Name:    "report_provenance",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&FundReport{}); err != nil {
+				return err
+			}
+			return SQL(
+				`DROP INDEX IF EXISTS idx_report_date_fund_id`,
+				`UPDATE fund_reports SET provenance = 'merged', origin = 'merged:' || merged_id
+				WHERE merged_id IS NOT NULL`,
+				`UPDATE fund_reports SET origin = 'manual' WHERE provenance = 'manual'`,
+				`UPDATE fund_reports SET source = CASE (SELECT type FROM funds WHERE funds.id = fund_reports.fund_id)
+					WHEN 'PMF' THEN 'sebi-pms' WHEN 'AIF' THEN 'aif' WHEN 'MF' THEN 'advisorkhoj' ELSE '' END
+				WHERE provenance = 'crawled' AND source = ''`,
+			)(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			// Only the effective report of each fund and month fits the old index.
+			err := SQL(`DELETE FROM fund_reports WHERE EXISTS (
+				SELECT 1 FROM fund_reports better
+				WHERE better.fund_id = fund_reports.fund_id AND better.report_date = fund_reports.report_date
+				AND (better.provenance = 'crawled' AND fund_reports.provenance != 'crawled'
+					OR better.provenance = 'manual' AND fund_reports.provenance = 'merged'
+					OR better.provenance = fund_reports.provenance AND better.id > fund_reports.id))`,
+				`DROP INDEX IF EXISTS idx_fund_report_origin`,
+			)(tx)
+			if err != nil {
+				return err
+			}
+			for _, column := range []string{"origin", "source", "crawl_run_id", "upload_id"} {
+				if err := tx.Migrator().DropColumn(&FundReport{}, column); err != nil {
+					return err
+				}
+			}
+			return SQL(`CREATE UNIQUE INDEX IF NOT EXISTS idx_report_date_fund_id ON fund_reports (fund_id, report_date)`)(tx)
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the table keeping the figures of fund reports before each change to them.
+func init() {
+	type ReportValues struct {
+		AUM            *float64
+		Month1Returns  *float64
+		Month3Returns  *float64
+		Month6Returns  *float64
+		Yr1Returns     *float64
+		Yr2Returns     *float64
+		Yr3Returns     *float64
+		Yr4Returns     *float64
+		Yr5Returns     *float64
+		OverAllReturns *float64
+		Strategy       string `gorm:"not null;default:''"`
+	}
+	type ReportRevision struct {
+		ID           uint64
+		FundReportID uint64 `gorm:"index"`
+		FundID       uint64 `gorm:"index"`
+		ReportDate   time.Time
+		Origin       string `gorm:"not null;default:''"`
+
+		Previous ReportValues `gorm:"embedded;embeddedPrefix:previous_"`
+		Current  ReportValues `gorm:"embedded;embeddedPrefix:current_"`
+
+		CrawlRunID *uint
+		UploadID   *uint64
+
+		ReturnsChange float64 `gorm:"not null;default:0"`
+		Restatement   bool    `gorm:"not null;default:false;index"`
+
+		CreatedAt time.Time
+	}
+
+	Register(&Migration{
+		Version: 14,
+		Name:    "report_revisions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&ReportRevision{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&ReportRevision{})
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the table recording fund merges, so they can be undone.
+func init() {
+	type MergeEvent struct {
+		ID       uint64
+		FundID   uint64 `gorm:"index"`
+		TargetID uint64 `gorm:"index"`
+		MergedBy string `gorm:"not null;default:''"`
+
+		PreviousOriginalID *uint64
+		PreviousLabel      string `gorm:"not null;default:''"`
+		PreviousIsHidden   bool   `gorm:"not null;default:false"`
+
+		ReportsCopied int `gorm:"not null;default:0"`
+
+		CreatedAt  time.Time
+		UnmergedBy string `gorm:"not null;default:''"`
+		UnmergedAt *time.Time
+	}
+
+	Register(&Migration{
+		Version: 15,
+		Name:    "merge_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&MergeEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&MergeEvent{})
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the queue of funds suggested as duplicates, with the decisions made on them.
+func init() {
+	type DuplicateSuggestion struct {
+		ID          uint64
+		FundID      uint64 `gorm:"uniqueIndex:idx_duplicate_pair"`
+		DuplicateID uint64 `gorm:"uniqueIndex:idx_duplicate_pair"`
+		FundHouseID uint64 `gorm:"index"`
+
+		Score             float64 `gorm:"not null;default:0;index"`
+		NameScore         float64 `gorm:"not null;default:0"`
+		ReturnsScore      float64 `gorm:"not null;default:0"`
+		OverlappingMonths int     `gorm:"not null;default:0"`
+		AUMScore          float64 `gorm:"not null;default:0"`
+		StrategyScore     float64 `gorm:"not null;default:0"`
+
+		Status       string `gorm:"not null;default:'pending';index"`
+		ReviewedBy   string `gorm:"not null;default:''"`
+		ReviewedAt   *time.Time
+		MergeEventID *uint64
+
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	Register(&Migration{
+		Version: 16,
+		Name:    "duplicate_suggestions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&DuplicateSuggestion{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&DuplicateSuggestion{})
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the curated collections of funds that replace static/filters.csv. They are
+// filled once with the importCollections command.
+func init() {
+	type Collection struct {
+		ID          uint64
+		Name        string `gorm:"not null"`
+		Slug        string `gorm:"not null;uniqueIndex"`
+		Description string `gorm:"not null;default:''"`
+
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type CollectionFund struct {
+		CollectionID uint64 `gorm:"primaryKey"`
+		FundID       uint64 `gorm:"primaryKey;index"`
+		Position     int    `gorm:"not null;default:0"`
+	}
+
+	Register(&Migration{
+		Version: 17,
+		Name:    "collections",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Collection{}, &CollectionFund{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&CollectionFund{}, &Collection{})
+		},
+	})
+}
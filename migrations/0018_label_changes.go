@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Adds the audit trail of fund label and canonical fund changes made through the
+// admin API and label imports.
+func init() {
+	type LabelChange struct {
+		ID     uint64
+		FundID uint64 `gorm:"index"`
+
+		PreviousLabel      string `gorm:"not null;default:''"`
+		Label              string `gorm:"not null;default:''"`
+		PreviousOriginalID *uint64
+		OriginalID         *uint64
+
+		Source    string    `gorm:"not null;default:''"`
+		ChangedBy string    `gorm:"not null;default:''"`
+		CreatedAt time.Time `gorm:"index"`
+	}
+
+	Register(&Migration{
+		Version: 18,
+		Name:    "label_changes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&LabelChange{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&LabelChange{})
+		},
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+// Allows one waiting job per key in scheduled_jobs, so instances pushing the same job
+// at once cannot queue it twice. Of the waiting jobs already sharing a key, the first
+// queued is kept.
+func init() {
+	Register(&Migration{
+		Version: 19,
+		Name:    "waiting_job_keys",
+		Up: SQL(
+			`UPDATE scheduled_jobs SET deleted_at = CURRENT_TIMESTAMP
+			WHERE job_status != 'running' AND deleted_at IS NULL AND EXISTS (
+				SELECT 1 FROM scheduled_jobs kept
+				WHERE kept.job_key = scheduled_jobs.job_key AND kept.id < scheduled_jobs.id
+				AND kept.job_status != 'running' AND kept.deleted_at IS NULL)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduled_jobs_waiting_key ON scheduled_jobs (job_key)
+			WHERE job_status != 'running' AND deleted_at IS NULL`,
+		),
+		Down: SQL(`DROP INDEX IF EXISTS idx_scheduled_jobs_waiting_key`),
+	})
+}
@@ -0,0 +1,12 @@
+package migrations
+
+// scheduled_jobs.job_data now holds the JSON fields of a job instead of its scheduler
+// options, which nothing reads. The options of jobs already queued are cleared so they
+// are not decoded as fields.
+func init() {
+	Register(&Migration{
+		Version: 20,
+		Name:    "job_payloads",
+		Up:      SQL(`UPDATE scheduled_jobs SET job_data = '' WHERE job_data LIKE '{"MaxRetries":%'`),
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Keeps the payload of a job that exhausted its retries, so it is shown with the
+// dead job and replayed with the fields it failed with.
+func init() {
+	type DeadJob struct {
+		JobData string `gorm:"type:text"`
+	}
+
+	Register(&Migration{
+		Version: 21,
+		Name:    "dead_job_payloads",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&DeadJob{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&DeadJob{}, "job_data")
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Records the funds a merge moved from the merged fund to the target, so an
+// unmerge can move them back.
+func init() {
+	type MergeEvent struct {
+		MovedFundIDs string `gorm:"type:text"`
+	}
+
+	Register(&Migration{
+		Version: 22,
+		Name:    "merge_moved_funds",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&MergeEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&MergeEvent{}, "moved_fund_ids")
+		},
+	})
+}
@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Migration is a single versioned schema or data change. Up and Down run inside a
+// transaction; Down may be nil for irreversible data migrations.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationStatus is the state of a registered migration.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+var ErrIrreversible = errors.New("migration has no down step")
+
+var registry = map[int64]*Migration{}
+
+// Register adds a migration to the registry. It panics on duplicate versions.
+func Register(m *Migration) {
+	if _, ok := registry[m.Version]; ok {
+		panic(fmt.Sprintf("migration %d already registered", m.Version))
+	}
+	registry[m.Version] = m
+}
+
+// All returns the registered migrations ordered by version.
+func All() []*Migration {
+	all := make([]*Migration, 0, len(registry))
+	for _, m := range registry {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// SQL returns a step that executes the given statements in order.
+func SQL(stmts ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// PostgresOnly runs fn only on Postgres. It wraps steps that have no SQLite
+// equivalent and are not needed there, such as backfills of legacy data.
+func PostgresOnly(fn func(tx *gorm.DB) error) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if tx.Dialector.Name() != "postgres" {
+			return nil
+		}
+		return fn(tx)
+	}
+}
+
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		res[row.Version] = row
+	}
+	return res, nil
+}
+
+// Status returns every registered migration with its applied time, if any.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(registry))
+	for _, m := range All() {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			s.AppliedAt = &row.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Up applies pending migrations in order, up to and including target. A target of 0
+// applies everything.
+func Up(db *gorm.DB, target int64) error {
+	done, err := applied(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending(All(), done, target) {
+		log.Info().Int64("version", m.Version).Str("name", m.Name).Msg("Applying migration")
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts the most recently applied migrations, one per step.
+func Down(db *gorm.DB, steps int) error {
+	done, err := applied(db)
+	if err != nil {
+		return err
+	}
+	all := All()
+	for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+		m := all[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, ErrIrreversible)
+		}
+		log.Info().Int64("version", m.Version).Str("name", m.Name).Msg("Reverting migration")
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		steps--
+	}
+	return nil
+}
+
+func pending(all []*Migration, done map[int64]SchemaMigration, target int64) []*Migration {
+	res := make([]*Migration, 0)
+	for _, m := range all {
+		if target != 0 && m.Version > target {
+			break
+		}
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		res = append(res, m)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"alpha2/crawler"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestPending(t *testing.T) {
+	all := []*Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	done := map[int64]SchemaMigration{1: {Version: 1}, 3: {Version: 3}}
+
+	tests := []struct {
+		name   string
+		target int64
+		want   []int64
+	}{
+		{name: "all pending", target: 0, want: []int64{2, 4}},
+		{name: "up to target", target: 3, want: []int64{2}},
+		{name: "target already applied", target: 1, want: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pending(all, done, tt.target)
+			if len(got) != len(tt.want) {
+				t.Fatalf("pending() returned %d migrations, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				if m.Version != tt.want[i] {
+					t.Errorf("pending()[%d] = %d, want %d", i, m.Version, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAllIsOrdered(t *testing.T) {
+	all := All()
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Version >= all[i].Version {
+			t.Fatalf("All() not ordered: %d before %d", all[i-1].Version, all[i].Version)
+		}
+	}
+}
+
+func TestUpOnSQLite(t *testing.T) {
+	db, err := crawler.OpenSQLite("file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	defer sqlDB.Close()
+
+	if err := Up(db, 0); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+	status, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d not applied", s.Version)
+		}
+	}
+}
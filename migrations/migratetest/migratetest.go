@@ -0,0 +1,35 @@
+// Package migratetest opens databases with the schema the migrations build, for
+// tests of the packages reading and writing it.
+package migratetest
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns an in-memory SQLite database migrated to the latest version, closed
+// when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := crawler.OpenSQLite("file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	// Every connection to :memory: is a separate database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := migrations.Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
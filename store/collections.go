@@ -0,0 +1,180 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSlugTaken is returned when saving a collection with the slug of another.
+	ErrSlugTaken = errors.New("slug already taken")
+	// ErrUnknownFund is returned when saving a collection listing a fund that does not
+	// exist.
+	ErrUnknownFund = errors.New("unknown fund")
+)
+
+// CollectionRepository keeps the curated lists of funds.
+type CollectionRepository interface {
+	// List returns every collection by name, with its funds.
+	List(ctx context.Context) ([]*crawler.Collection, error)
+	Get(ctx context.Context, id uint64) (*crawler.Collection, error)
+	GetBySlug(ctx context.Context, slug string) (*crawler.Collection, error)
+	// Create saves a new collection and its funds.
+	Create(ctx context.Context, collection *crawler.Collection) error
+	// Update saves a collection and replaces its funds with FundIDs.
+	Update(ctx context.Context, collection *crawler.Collection) error
+	Delete(ctx context.Context, id uint64) error
+}
+
+type gormCollectionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCollectionRepository) List(ctx context.Context) ([]*crawler.Collection, error) {
+	db := r.db.WithContext(ctx)
+	collections := []*crawler.Collection{}
+	if err := db.Order("name, id").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	if err := loadCollectionFunds(db, collections...); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (r *gormCollectionRepository) Get(ctx context.Context, id uint64) (*crawler.Collection, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *gormCollectionRepository) GetBySlug(ctx context.Context, slug string) (*crawler.Collection, error) {
+	return r.first(ctx, "slug = ?", slug)
+}
+
+func (r *gormCollectionRepository) first(ctx context.Context, query string, arg any) (*crawler.Collection, error) {
+	db := r.db.WithContext(ctx)
+	collection := &crawler.Collection{}
+	if err := db.Where(query, arg).First(collection).Error; err != nil {
+		return nil, notFound(err)
+	}
+	if err := loadCollectionFunds(db, collection); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+func (r *gormCollectionRepository) Create(ctx context.Context, collection *crawler.Collection) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkCollection(tx, collection); err != nil {
+			return err
+		}
+		if err := tx.Create(collection).Error; err != nil {
+			return err
+		}
+		return saveCollectionFunds(tx, collection)
+	})
+}
+
+func (r *gormCollectionRepository) Update(ctx context.Context, collection *crawler.Collection) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing := &crawler.Collection{}
+		if err := tx.First(existing, collection.ID).Error; err != nil {
+			return notFound(err)
+		}
+		if err := checkCollection(tx, collection); err != nil {
+			return err
+		}
+		collection.CreatedAt = existing.CreatedAt
+		if err := tx.Save(collection).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&crawler.CollectionFund{}).Error; err != nil {
+			return err
+		}
+		return saveCollectionFunds(tx, collection)
+	})
+}
+
+func (r *gormCollectionRepository) Delete(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", id).Delete(&crawler.CollectionFund{}).Error; err != nil {
+			return err
+		}
+		deleted := tx.Delete(&crawler.Collection{}, id)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// checkCollection returns ErrSlugTaken when another collection has the slug and
+// ErrUnknownFund when a listed fund does not exist.
+func checkCollection(tx *gorm.DB, collection *crawler.Collection) error {
+	var taken int64
+	err := tx.Model(&crawler.Collection{}).
+		Where("slug = ? AND id != ?", collection.Slug, collection.ID).
+		Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("%w: %s", ErrSlugTaken, collection.Slug)
+	}
+
+	if len(collection.FundIDs) == 0 {
+		return nil
+	}
+	var found []uint64
+	if err := tx.Model(&crawler.Fund{}).Where("id in ?", collection.FundIDs).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	for _, id := range collection.FundIDs {
+		if !slices.Contains(found, id) {
+			return fmt.Errorf("%w: %d", ErrUnknownFund, id)
+		}
+	}
+	return nil
+}
+
+func saveCollectionFunds(tx *gorm.DB, collection *crawler.Collection) error {
+	if len(collection.FundIDs) == 0 {
+		return nil
+	}
+	funds := make([]crawler.CollectionFund, len(collection.FundIDs))
+	for idx, fundID := range collection.FundIDs {
+		funds[idx] = crawler.CollectionFund{CollectionID: collection.ID, FundID: fundID, Position: idx}
+	}
+	return tx.Create(&funds).Error
+}
+
+// loadCollectionFunds fills the FundIDs of the collections in order.
+func loadCollectionFunds(tx *gorm.DB, collections ...*crawler.Collection) error {
+	byID := make(map[uint64]*crawler.Collection, len(collections))
+	for _, collection := range collections {
+		collection.FundIDs = []uint64{}
+		byID[collection.ID] = collection
+	}
+	if len(byID) == 0 {
+		return nil
+	}
+	funds := []crawler.CollectionFund{}
+	err := tx.Where("collection_id in ?", slices.Collect(maps.Keys(byID))).
+		Order("collection_id, position").
+		Find(&funds).Error
+	if err != nil {
+		return err
+	}
+	for _, fund := range funds {
+		byID[fund.CollectionID].FundIDs = append(byID[fund.CollectionID].FundIDs, fund.FundID)
+	}
+	return nil
+}
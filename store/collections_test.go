@@ -0,0 +1,80 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCollections(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	for _, fund := range []*crawler.Fund{
+		{ID: 1, Name: "Alpha", Type: "PMF"},
+		{ID: 2, Name: "Beta", Type: "PMF"},
+		{ID: 3, Name: "Gamma", Type: "PMF"},
+	} {
+		if err := st.Funds.Save(ctx, fund); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, fundID := range []uint64{1, 2, 3} {
+		report := &crawler.FundReport{FundID: fundID, ReportDate: date(2024, time.May, 31), AUM: float(100)}
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collection := &crawler.Collection{Name: "Top Picks", Slug: "top-picks", FundIDs: []uint64{3, 1}}
+	if err := st.Collections.Create(ctx, collection); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Collections.Create(ctx, &crawler.Collection{Name: "Top", Slug: "top-picks"}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("Create() with a taken slug = %v, want ErrSlugTaken", err)
+	}
+	if err := st.Collections.Create(ctx, &crawler.Collection{Name: "New", Slug: "new", FundIDs: []uint64{9}}); !errors.Is(err, ErrUnknownFund) {
+		t.Errorf("Create() with an unknown fund = %v, want ErrUnknownFund", err)
+	}
+
+	collection.FundIDs = []uint64{2, 3}
+	collection.Description = "Our favourites"
+	if err := st.Collections.Update(ctx, collection); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Collections.GetBySlug(ctx, "top-picks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got.FundIDs) != "[2 3]" || got.Description != "Our favourites" {
+		t.Errorf("collection after update = %+v", got)
+	}
+
+	// A fund merged into another brings the other into the explore filter.
+	if _, err := st.Merges.Commit(ctx, 2, 1, "curator"); err != nil {
+		t.Fatal(err)
+	}
+	reports, total, err := st.Reports.Explore(ctx, ExploreQuery{
+		From:         *date(2024, time.May, 1),
+		To:           *date(2024, time.May, 31),
+		CollectionID: collection.ID,
+		Limit:        10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(reports) != 2 || reports[0].FundID != 1 || reports[1].FundID != 3 {
+		t.Errorf("Explore() of the collection = %d reports %+v, want funds 1 and 3", total, reports)
+	}
+
+	if err := st.Collections.Delete(ctx, collection.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Collections.Get(ctx, collection.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Dialect renders the SQL constructs that differ between Postgres and SQLite.
+// similarity() needs no translation: SQLite connections opened by crawler.OpenSQLite
+// register a Go implementation of it.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// DialectOf returns the dialect of db's driver.
+func DialectOf(db *gorm.DB) Dialect {
+	if db.Dialector.Name() == "sqlite" {
+		return SQLite
+	}
+	return Postgres
+}
+
+// MonthStart truncates the date column to the start of its month.
+func (d Dialect) MonthStart(column string) string {
+	if d == SQLite {
+		return fmt.Sprintf("strftime('%%Y-%%m-01', %s)", column)
+	}
+	return fmt.Sprintf("DATE_TRUNC('month', %s)", column)
+}
+
+// Year extracts the year of the date column as a number.
+func (d Dialect) Year(column string) string {
+	if d == SQLite {
+		return fmt.Sprintf("CAST(strftime('%%Y', %s) AS INTEGER)", column)
+	}
+	return fmt.Sprintf("EXTRACT(YEAR FROM %s)", column)
+}
+
+// Month extracts the month (1-12) of the date column as a number.
+func (d Dialect) Month(column string) string {
+	if d == SQLite {
+		return fmt.Sprintf("CAST(strftime('%%m', %s) AS INTEGER)", column)
+	}
+	return fmt.Sprintf("EXTRACT(MONTH FROM %s)", column)
+}
+
+// JSONText extracts key from the JSON column as text, or NULL when it is absent.
+func (d Dialect) JSONText(column, key string) string {
+	if d == SQLite {
+		// JSONB values are stored as blobs, which SQLite would read as its binary JSON format.
+		return fmt.Sprintf("json_extract(CAST(%s AS TEXT), '$.%s')", column, key)
+	}
+	return fmt.Sprintf("%s->>'%s'", column, key)
+}
+
+// FirstPerGroup returns a query selecting, from the rows of table matching where,
+// the first row of each group as ordered by orderBy. Results are ordered by group.
+// where may contain ? placeholders.
+func (d Dialect) FirstPerGroup(table, where string, groupBy []string, orderBy string) string {
+	groups := strings.Join(groupBy, ", ")
+	if d == SQLite {
+		return fmt.Sprintf(`SELECT * FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS row_num
+			FROM %s WHERE %s
+		) WHERE row_num = 1 ORDER BY %s`, groups, orderBy, table, where, groups)
+	}
+	return fmt.Sprintf(`SELECT DISTINCT ON (%s) * FROM %s WHERE %s ORDER BY %s, %s`,
+		groups, table, where, groups, orderBy)
+}
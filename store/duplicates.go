@@ -0,0 +1,187 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDecided is returned when accepting or rejecting a suggestion that is no longer
+// pending.
+var ErrDecided = errors.New("suggestion already decided")
+
+// DuplicateRepository keeps the queue of funds suggested as duplicates.
+type DuplicateRepository interface {
+	// Suggest scores every pair of visible, unmerged funds of a type within the fund
+	// house and queues the pairs scoring at least minScore. Scores of pending pairs
+	// are updated; decided pairs are not suggested again. It returns the pairs queued.
+	Suggest(ctx context.Context, fundHouseID uint64, minScore float64) ([]crawler.DuplicateSuggestion, error)
+	// List returns the suggestions with the given status, or all, highest score
+	// first, with both funds loaded.
+	List(ctx context.Context, status string) ([]crawler.DuplicateSuggestion, error)
+	// Accept merges the duplicate of a pending suggestion into its fund. Other pending
+	// suggestions of the merged duplicate are rejected.
+	Accept(ctx context.Context, id uint64, reviewer string) (*crawler.DuplicateSuggestion, error)
+	// Reject marks a pending suggestion rejected, so the pair is not suggested again.
+	Reject(ctx context.Context, id uint64, reviewer string) (*crawler.DuplicateSuggestion, error)
+}
+
+type gormDuplicateRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormDuplicateRepository) Suggest(ctx context.Context, fundHouseID uint64, minScore float64) ([]crawler.DuplicateSuggestion, error) {
+	db := r.db.WithContext(ctx)
+	funds := []*crawler.Fund{}
+	err := db.Where("original_id IS NULL AND is_hidden = false").
+		Where("id in (SELECT fund_id FROM fund_x_fund_managers WHERE fund_manager_id = ?)", fundHouseID).
+		Order("id").
+		Find(&funds).Error
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[uint64][]*crawler.FundReport, len(funds))
+	for _, fund := range funds {
+		if reports[fund.ID], err = effectiveForFund(db, fund.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	suggestions := []crawler.DuplicateSuggestion{}
+	for idx, fund := range funds {
+		for _, duplicate := range funds[idx+1:] {
+			if fund.Type != duplicate.Type {
+				continue
+			}
+			score := crawler.ScoreDuplicate(fund, duplicate, reports[fund.ID], reports[duplicate.ID])
+			if score.Score < minScore {
+				continue
+			}
+			suggestion := crawler.DuplicateSuggestion{
+				FundID:         fund.ID,
+				DuplicateID:    duplicate.ID,
+				FundHouseID:    fundHouseID,
+				DuplicateScore: score,
+				Status:         crawler.SuggestionPending,
+			}
+			saved := db.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "fund_id"}, {Name: "duplicate_id"}},
+				Where: clause.Where{Exprs: []clause.Expression{
+					clause.Eq{Column: "duplicate_suggestions.status", Value: crawler.SuggestionPending},
+				}},
+				DoUpdates: clause.AssignmentColumns([]string{"score", "name_score", "returns_score",
+					"overlapping_months", "aum_score", "strategy_score", "updated_at"}),
+			}).Create(&suggestion)
+			if saved.Error != nil {
+				return nil, saved.Error
+			}
+			if saved.RowsAffected > 0 {
+				suggestion.Fund, suggestion.Duplicate = fund, duplicate
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+func (r *gormDuplicateRepository) List(ctx context.Context, status string) ([]crawler.DuplicateSuggestion, error) {
+	db := r.db.WithContext(ctx)
+	tx := db.Model(&crawler.DuplicateSuggestion{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	suggestions := []crawler.DuplicateSuggestion{}
+	if err := tx.Order("score DESC, id").Find(&suggestions).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, suggestion := range suggestions {
+		ids = append(ids, suggestion.FundID, suggestion.DuplicateID)
+	}
+	funds := []*crawler.Fund{}
+	if err := db.Where("id in ?", ids).Find(&funds).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint64]*crawler.Fund, len(funds))
+	for _, fund := range funds {
+		byID[fund.ID] = fund
+	}
+	for idx := range suggestions {
+		suggestions[idx].Fund = byID[suggestions[idx].FundID]
+		suggestions[idx].Duplicate = byID[suggestions[idx].DuplicateID]
+	}
+	return suggestions, nil
+}
+
+func (r *gormDuplicateRepository) Accept(ctx context.Context, id uint64, reviewer string) (*crawler.DuplicateSuggestion, error) {
+	var suggestion *crawler.DuplicateSuggestion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if suggestion, err = decide(tx, id, crawler.SuggestionAccepted, reviewer); err != nil {
+			return err
+		}
+		event, err := New(tx).Merges.Commit(ctx, suggestion.DuplicateID, suggestion.FundID, reviewer)
+		if err != nil {
+			return err
+		}
+		suggestion.MergeEventID = &event.ID
+		if err := tx.Model(suggestion).Update("merge_event_id", event.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&crawler.DuplicateSuggestion{}).
+			Where("id <> ? AND status = ?", id, crawler.SuggestionPending).
+			Where("fund_id = ? OR duplicate_id = ?", suggestion.DuplicateID, suggestion.DuplicateID).
+			Updates(map[string]any{
+				"status":      crawler.SuggestionRejected,
+				"reviewed_by": reviewer,
+				"reviewed_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestion, nil
+}
+
+func (r *gormDuplicateRepository) Reject(ctx context.Context, id uint64, reviewer string) (*crawler.DuplicateSuggestion, error) {
+	var suggestion *crawler.DuplicateSuggestion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		suggestion, err = decide(tx, id, crawler.SuggestionRejected, reviewer)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestion, nil
+}
+
+// decide moves a pending suggestion to status and returns it. The status is only
+// changed from pending, so a pair is decided once.
+func decide(tx *gorm.DB, id uint64, status, reviewer string) (*crawler.DuplicateSuggestion, error) {
+	suggestion := &crawler.DuplicateSuggestion{}
+	if err := tx.First(suggestion, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	now := time.Now()
+	decided := tx.Model(&crawler.DuplicateSuggestion{}).
+		Where("id = ? AND status = ?", id, crawler.SuggestionPending).
+		Updates(map[string]any{
+			"status":      status,
+			"reviewed_by": reviewer,
+			"reviewed_at": now,
+		})
+	if decided.Error != nil {
+		return nil, decided.Error
+	}
+	if decided.RowsAffected == 0 {
+		return nil, ErrDecided
+	}
+	suggestion.Status, suggestion.ReviewedBy, suggestion.ReviewedAt = status, reviewer, &now
+	return suggestion, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDuplicatesSuggestAndDecide(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	fundHouse := []*crawler.FundManager{{ID: 1, RegisterNumber: "INP1"}}
+	for _, fund := range []*crawler.Fund{
+		{ID: 1, Name: "Alpha Growth", Type: "PMF", FundManagers: fundHouse},
+		{ID: 2, Name: "Alpha  Growth", Type: "PMF", FundManagers: fundHouse},
+		{ID: 3, Name: "Alpha Growth.", Type: "PMF", FundManagers: fundHouse},
+		{ID: 4, Name: "Alpha Growth", Type: "MF", FundManagers: fundHouse},
+	} {
+		if err := st.Funds.Save(ctx, fund); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	suggestions, err := st.Duplicates.Suggest(ctx, 1, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 3 {
+		t.Fatalf("Suggest() queued %d pairs, want the 3 PMF pairs", len(suggestions))
+	}
+	pending, err := st.Duplicates.List(ctx, crawler.SuggestionPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := make(map[[2]uint64]uint64)
+	for _, suggestion := range pending {
+		if suggestion.Fund == nil || suggestion.Duplicate == nil {
+			t.Fatalf("suggestion %d has no funds loaded", suggestion.ID)
+		}
+		pairs[[2]uint64{suggestion.FundID, suggestion.DuplicateID}] = suggestion.ID
+	}
+
+	accepted, err := st.Duplicates.Accept(ctx, pairs[[2]uint64{1, 2}], "curator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Status != crawler.SuggestionAccepted || accepted.MergeEventID == nil {
+		t.Errorf("accepted suggestion = %+v", accepted)
+	}
+	if merged, _ := st.Funds.Get(ctx, 2); merged.OriginalID == nil || *merged.OriginalID != 1 {
+		t.Errorf("fund 2 not merged into 1: %+v", merged)
+	}
+	if _, err := st.Duplicates.Reject(ctx, accepted.ID, "curator"); !errors.Is(err, ErrDecided) {
+		t.Errorf("Reject() of an accepted suggestion = %v, want ErrDecided", err)
+	}
+
+	// The other pair of the merged fund is rejected with the merge.
+	pending, err = st.Duplicates.List(ctx, crawler.SuggestionPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != pairs[[2]uint64{1, 3}] {
+		t.Errorf("pending after accept = %+v, want only the pair 1 and 3", pending)
+	}
+	if _, err := st.Duplicates.Reject(ctx, pairs[[2]uint64{2, 3}], "curator"); !errors.Is(err, ErrDecided) {
+		t.Errorf("Reject() of the pair of the merged fund = %v, want ErrDecided", err)
+	}
+
+	// The rejected pair is not suggested again; the merged fund is no candidate.
+	suggestions, err = st.Duplicates.Suggest(ctx, 1, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].FundID != 1 || suggestions[0].DuplicateID != 3 {
+		t.Errorf("Suggest() again = %+v, want only the pending pair 1 and 3", suggestions)
+	}
+}
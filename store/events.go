@@ -0,0 +1,62 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// EventRepository stores crawler failures so they can be retried.
+type EventRepository interface {
+	Create(ctx context.Context, event *crawler.CrawlerEvent) error
+	// FailedPMFRequests returns the recorded failures of SEBI PMS requests.
+	FailedPMFRequests(ctx context.Context) ([]crawler.CrawlerEvent, error)
+	DeletePMFFailure(ctx context.Context, uid, year, month string) error
+	// FailedSourceRequests returns the recorded failures of crawls of the named source.
+	FailedSourceRequests(ctx context.Context, source string) ([]crawler.CrawlerEvent, error)
+	// DeleteSourceFailures forgets the failures of crawls of entity for period, a date.
+	DeleteSourceFailures(ctx context.Context, source, entity, period string) error
+}
+
+type gormEventRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormEventRepository) Create(ctx context.Context, event *crawler.CrawlerEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *gormEventRepository) FailedPMFRequests(ctx context.Context) ([]crawler.CrawlerEvent, error) {
+	var events []crawler.CrawlerEvent
+	err := r.db.WithContext(ctx).Model(&crawler.CrawlerEvent{}).
+		Where(DialectOf(r.db).JSONText("data", "UID") + " is not null").
+		Find(&events).Error
+	return events, err
+}
+
+func (r *gormEventRepository) DeletePMFFailure(ctx context.Context, uid, year, month string) error {
+	d := DialectOf(r.db)
+	return r.db.WithContext(ctx).
+		Where(d.JSONText("data", "UID")+" = ?", uid).
+		Where(d.JSONText("data", "year")+" = ?", year).
+		Where(d.JSONText("data", "month")+" = ?", month).
+		Delete(&crawler.CrawlerEvent{}).Error
+}
+
+func (r *gormEventRepository) FailedSourceRequests(ctx context.Context, source string) ([]crawler.CrawlerEvent, error) {
+	var events []crawler.CrawlerEvent
+	err := r.db.WithContext(ctx).Model(&crawler.CrawlerEvent{}).
+		Where(DialectOf(r.db).JSONText("data", "source")+" = ?", source).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *gormEventRepository) DeleteSourceFailures(ctx context.Context, source, entity, period string) error {
+	d := DialectOf(r.db)
+	return r.db.WithContext(ctx).
+		Where(d.JSONText("data", "source")+" = ?", source).
+		Where(d.JSONText("data", "entity")+" = ?", entity).
+		Where(d.JSONText("data", "period")+" = ?", period).
+		Delete(&crawler.CrawlerEvent{}).Error
+}
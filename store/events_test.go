@@ -0,0 +1,35 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"testing"
+)
+
+func TestEventsPMFFailures(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	events := []*crawler.CrawlerEvent{
+		{Data: crawler.JSONB{"UID": "PM1", "year": "2024", "month": "1"}},
+		{Data: crawler.JSONB{"UID": "PM1", "year": "2024", "month": "2"}},
+		{Data: crawler.JSONB{"FundID": "7"}},
+	}
+	for _, event := range events {
+		if err := st.Events.Create(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := st.Events.DeletePMFFailure(ctx, "PM1", "2024", "1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Events.FailedPMFRequests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Data["month"] != "2" {
+		t.Errorf("FailedPMFRequests() = %v, want only the month 2 failure", got)
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// FundHouseFilter filters the admin fund house list. IDs restricts the result when
+// non-nil; Unverified keeps fund houses with funds in the unlabelled queue of
+// LabelRepository.Unlabelled. A zero Limit returns every fund house.
+type FundHouseFilter struct {
+	IDs        []uint64
+	Unverified bool
+	Limit      int
+	Offset     int
+}
+
+type FundHouseRepository interface {
+	// Get returns a fund house with its managers.
+	Get(ctx context.Context, id uint64) (*crawler.FundManager, error)
+	// GetWithFunds returns a fund house with its funds.
+	GetWithFunds(ctx context.Context, id uint64) (*crawler.FundManager, error)
+	// GetBySlug returns a fund house with its managers and funds.
+	GetBySlug(ctx context.Context, slug string) (*crawler.FundManager, error)
+	// GetByUID returns the fund house with the SEBI portfolio manager UID, with its funds.
+	GetByUID(ctx context.Context, uid string) (*crawler.FundManager, error)
+	List(ctx context.Context, filter FundHouseFilter) ([]crawler.FundManager, error)
+	ListAll(ctx context.Context) ([]crawler.FundManager, error)
+	// Update saves the fund house and its managers, and marks the logo image as used.
+	Update(ctx context.Context, fundHouse *crawler.FundManager, managers []*crawler.Manager, logoImageID uint) error
+}
+
+type gormFundHouseRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormFundHouseRepository) Get(ctx context.Context, id uint64) (*crawler.FundManager, error) {
+	fundHouse := &crawler.FundManager{}
+	if err := r.db.WithContext(ctx).Preload("Managers").First(fundHouse, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return fundHouse, nil
+}
+
+func (r *gormFundHouseRepository) GetWithFunds(ctx context.Context, id uint64) (*crawler.FundManager, error) {
+	fundHouse := &crawler.FundManager{}
+	if err := r.db.WithContext(ctx).Preload("Funds").First(fundHouse, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return fundHouse, nil
+}
+
+func (r *gormFundHouseRepository) GetBySlug(ctx context.Context, slug string) (*crawler.FundManager, error) {
+	fundHouse := &crawler.FundManager{}
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).Preload("Managers").Preload("Funds").First(fundHouse).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return fundHouse, nil
+}
+
+func (r *gormFundHouseRepository) GetByUID(ctx context.Context, uid string) (*crawler.FundManager, error) {
+	fundHouse := &crawler.FundManager{}
+	err := r.db.WithContext(ctx).Where("uid = ?", uid).Preload("Funds").First(fundHouse).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return fundHouse, nil
+}
+
+func (r *gormFundHouseRepository) List(ctx context.Context, filter FundHouseFilter) ([]crawler.FundManager, error) {
+	tx := r.db.WithContext(ctx).Model(&crawler.FundManager{})
+	if filter.IDs != nil {
+		tx = tx.Where("id in ?", filter.IDs)
+	}
+	if filter.Unverified {
+		tx = tx.Where(`id in (
+			SELECT fxfm.fund_manager_id FROM fund_x_fund_managers fxfm
+			JOIN funds ON funds.id = fxfm.fund_id
+			WHERE funds.type in ('PMF', 'AIF') and funds.label = '' and funds.original_id IS NULL)`)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	fundHouses := []crawler.FundManager{}
+	err := tx.Order("id").Find(&fundHouses).Error
+	return fundHouses, err
+}
+
+func (r *gormFundHouseRepository) ListAll(ctx context.Context) ([]crawler.FundManager, error) {
+	fundHouses := []crawler.FundManager{}
+	err := r.db.WithContext(ctx).Order("id").Find(&fundHouses).Error
+	return fundHouses, err
+}
+
+func (r *gormFundHouseRepository) Update(ctx context.Context, fundHouse *crawler.FundManager, managers []*crawler.Manager, logoImageID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Managers", "Funds").Save(fundHouse).Error; err != nil {
+			return err
+		}
+
+		for _, manager := range managers {
+			if err := tx.Save(manager).Error; err != nil {
+				return err
+			}
+		}
+
+		if logoImageID != 0 {
+			return (&gormImageRepository{db: tx}).MarkUsed(ctx, logoImageID)
+		}
+		return nil
+	})
+}
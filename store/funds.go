@@ -0,0 +1,132 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/jobs"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FundSearch filters the public fund search. PMF and AIF funds are only found once
+// labelled.
+type FundSearch struct {
+	Type  string
+	Name  string
+	Limit int
+}
+
+type FundRepository interface {
+	Get(ctx context.Context, id uint64) (*crawler.Fund, error)
+	// ListByIDs returns the funds with their fund houses preloaded.
+	ListByIDs(ctx context.Context, ids []uint64) ([]crawler.Fund, error)
+	// Search returns visible funds of a type ordered by similarity to the name.
+	Search(ctx context.Context, q FundSearch) ([]crawler.Fund, error)
+	Save(ctx context.Context, fund *crawler.Fund) error
+	SaveAll(ctx context.Context, funds []*crawler.Fund) error
+	SetHidden(ctx context.Context, id uint64, hidden bool) error
+	// SaveCrawled upserts a crawled fund with its fund house and reports. The fund
+	// house is only overwritten when refreshedDate is newer than the stored one.
+	SaveCrawled(ctx context.Context, fund *crawler.Fund, refreshedDate time.Time) error
+}
+
+type gormFundRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormFundRepository) Get(ctx context.Context, id uint64) (*crawler.Fund, error) {
+	fund := &crawler.Fund{}
+	if err := r.db.WithContext(ctx).First(fund, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return fund, nil
+}
+
+func (r *gormFundRepository) ListByIDs(ctx context.Context, ids []uint64) ([]crawler.Fund, error) {
+	funds := []crawler.Fund{}
+	err := r.db.WithContext(ctx).Model(&crawler.Fund{}).Where("id in ?", ids).Preload("FundManagers").Find(&funds).Error
+	return funds, err
+}
+
+func (r *gormFundRepository) Search(ctx context.Context, q FundSearch) ([]crawler.Fund, error) {
+	var tx *gorm.DB
+	if q.Type == "PMF" || q.Type == "AIF" {
+		tx = r.db.WithContext(ctx).Model(&crawler.Fund{}).Preload("FundManagers").Where("type = ? and label != '' and is_hidden = false", q.Type)
+		if q.Name != "" {
+			tx = tx.Order(clause.OrderBy{
+				Expression: clause.Expr{SQL: "similarity(label, ?) DESC", Vars: []any{q.Name}},
+			})
+		}
+	} else {
+		tx = r.db.WithContext(ctx).Model(&crawler.Fund{}).Where("type = ? and is_hidden = false", q.Type)
+		if q.Name != "" {
+			tx = tx.Order(clause.OrderBy{
+				Expression: clause.Expr{SQL: "similarity(name, ?) DESC", Vars: []any{q.Name}},
+			})
+		}
+	}
+
+	funds := []crawler.Fund{}
+	err := tx.Limit(q.Limit).Find(&funds).Error
+	return funds, err
+}
+
+func (r *gormFundRepository) Save(ctx context.Context, fund *crawler.Fund) error {
+	return r.db.WithContext(ctx).Save(fund).Error
+}
+
+func (r *gormFundRepository) SaveAll(ctx context.Context, funds []*crawler.Fund) error {
+	if len(funds) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Save(&funds).Error
+}
+
+func (r *gormFundRepository) SetHidden(ctx context.Context, id uint64, hidden bool) error {
+	return r.db.WithContext(ctx).Model(&crawler.Fund{}).Where("id = ?", id).Update("is_hidden", hidden).Error
+}
+
+func (r *gormFundRepository) SaveCrawled(ctx context.Context, fund *crawler.Fund, refreshedDate time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fundHouse := fund.FundManagers[0]
+		fundHouse.RefreshedDate = &refreshedDate
+
+		err := tx.Model(&crawler.FundManager{}).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "id"}},
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.And(
+					clause.Eq{Column: "fund_managers.id", Value: fundHouse.ID},
+					clause.Lt{Column: "fund_managers.refreshed_date", Value: refreshedDate},
+				),
+			}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "email", "contact", "address", "total_no_of_client", "other_data", "total_aum",
+				"refreshed_date", "uid", "registration_name"}),
+		}).Omit("Funds").Create(fundHouse).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&crawler.Fund{}).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoNothing: true,
+		}).Omit("FundReports").Create(fund).Error
+		if err != nil {
+			return err
+		}
+
+		for _, fundReport := range fund.FundReports {
+			fundReport.FundID = fund.ID
+			fundReport.Provenance = crawler.ProvenanceCrawled
+			fundReport.Origin = crawler.ProvenanceCrawled
+			if runID := jobs.RunID(ctx); runID != 0 {
+				fundReport.CrawlRunID = &runID
+			}
+			if err := upsertReport(tx, fundReport); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFundsGetAndSetHidden(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	fund := &crawler.Fund{ID: 1, Name: "Alpha Growth", Type: "PMF"}
+	if err := st.Funds.Save(ctx, fund); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Funds.SetHidden(ctx, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Funds.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsHidden {
+		t.Error("Get() returned a visible fund after SetHidden(true)")
+	}
+
+	if _, err := st.Funds.Get(ctx, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing fund returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestFundsSearchBySimilarity(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	funds := []*crawler.Fund{
+		{ID: 1, Name: "Value Fund", Type: "MF"},
+		{ID: 2, Name: "Growth Opportunities", Type: "MF"},
+		{ID: 3, Name: "Hidden Growth", Type: "MF", IsHidden: true},
+	}
+	if err := st.Funds.SaveAll(ctx, funds); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.Funds.Search(ctx, FundSearch{Type: "MF", Name: "growth", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Search() returned %d funds, want 2", len(got))
+	}
+	if got[0].ID != 2 {
+		t.Errorf("Search() ranked fund %d first, want 2", got[0].ID)
+	}
+}
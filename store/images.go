@@ -0,0 +1,41 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type ImageRepository interface {
+	Get(ctx context.Context, id uint) (*crawler.Image, error)
+	Create(ctx context.Context, image *crawler.Image) error
+	MarkUsed(ctx context.Context, id uint) error
+}
+
+type gormImageRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormImageRepository) Get(ctx context.Context, id uint) (*crawler.Image, error) {
+	image := &crawler.Image{}
+	if err := r.db.WithContext(ctx).First(image, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return image, nil
+}
+
+func (r *gormImageRepository) Create(ctx context.Context, image *crawler.Image) error {
+	return r.db.WithContext(ctx).Create(image).Error
+}
+
+func (r *gormImageRepository) MarkUsed(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Model(&crawler.Image{}).Where("id = ?", id).Update("is_unused", false)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestImagesMarkUsed(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	image := &crawler.Image{Filename: "logo.png", IsUnused: true}
+	if err := st.Images.Create(ctx, image); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Images.MarkUsed(ctx, image.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Images.Get(ctx, image.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsUnused {
+		t.Error("image is still unused after MarkUsed")
+	}
+
+	if err := st.Images.MarkUsed(ctx, image.ID+1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkUsed() of a missing image returned %v, want ErrNotFound", err)
+	}
+}
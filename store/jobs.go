@@ -0,0 +1,199 @@
+package store
+
+import (
+	"alpha2/jobs"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobFilter filters queued jobs by key, group and status. Zero values are ignored.
+type JobFilter struct {
+	Key    string
+	Group  string
+	Status string
+}
+
+// RunFilter filters job runs by group, outcome and finish time. Zero values are ignored.
+type RunFilter struct {
+	Group      string
+	Outcome    string
+	Start, End time.Time
+}
+
+type JobRepository interface {
+	// Upcoming returns the scheduled jobs due to run after the given time. Running,
+	// paused and blocked jobs are left out.
+	Upcoming(ctx context.Context, after time.Time) ([]jobs.ScheduledJob, error)
+	// Runs returns the recorded job runs matching filter, most recent first.
+	Runs(ctx context.Context, filter RunFilter) ([]jobs.JobRun, error)
+	// LatestRun returns the most recent run of the job with the given key, running or
+	// finished, or ErrNotFound.
+	LatestRun(ctx context.Context, key string) (*jobs.JobRun, error)
+	// List returns the queued jobs matching filter in run order.
+	List(ctx context.Context, filter JobFilter) ([]jobs.ScheduledJob, error)
+	// TriggerNow makes the scheduled job with the given key due immediately, or returns
+	// ErrNotFound. Paused and running jobs are not found.
+	TriggerNow(ctx context.Context, key string) error
+	// Cancel removes the waiting job with the given key, or returns ErrNotFound. Jobs
+	// already running are left to finish.
+	Cancel(ctx context.Context, key string) error
+	// PauseGroup stops the jobs of group, including ones queued later, from running
+	// until ResumeGroup is called.
+	PauseGroup(ctx context.Context, group string) error
+	ResumeGroup(ctx context.Context, group string) error
+	// Dead returns the jobs that exhausted their retries, optionally of one group,
+	// most recent first.
+	Dead(ctx context.Context, group string) ([]jobs.DeadJob, error)
+	GetDead(ctx context.Context, id uint) (*jobs.DeadJob, error)
+	// ReplayDead removes a dead job and queues it to run again, restoring it when it
+	// cannot be queued. It returns ErrNotFound when the job was already replayed.
+	ReplayDead(ctx context.Context, dead *jobs.DeadJob) error
+}
+
+type gormJobRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormJobRepository) Upcoming(ctx context.Context, after time.Time) ([]jobs.ScheduledJob, error) {
+	var scheduled []jobs.ScheduledJob
+	err := r.db.WithContext(ctx).
+		Where("nxt_run_time > ? AND job_status = ?", after.UnixNano(), jobs.StatusScheduled).
+		Find(&scheduled).Error
+	return scheduled, err
+}
+
+func (r *gormJobRepository) Runs(ctx context.Context, filter RunFilter) ([]jobs.JobRun, error) {
+	tx := r.db.WithContext(ctx)
+	if filter.Group != "" {
+		tx = tx.Where("job_group = ?", filter.Group)
+	}
+	if filter.Outcome != "" {
+		tx = tx.Where("outcome = ?", filter.Outcome)
+	}
+	if !filter.Start.IsZero() {
+		tx = tx.Where("finished_at >= ?", filter.Start)
+	}
+	if !filter.End.IsZero() {
+		tx = tx.Where("finished_at <= ?", filter.End)
+	}
+
+	var runs []jobs.JobRun
+	err := tx.Order("started_at DESC").Find(&runs).Error
+	return runs, err
+}
+
+func (r *gormJobRepository) LatestRun(ctx context.Context, key string) (*jobs.JobRun, error) {
+	run := &jobs.JobRun{}
+	if err := r.db.WithContext(ctx).Where("job_key = ?", key).Order("id DESC").First(run).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return run, nil
+}
+
+func (r *gormJobRepository) List(ctx context.Context, filter JobFilter) ([]jobs.ScheduledJob, error) {
+	tx := r.db.WithContext(ctx)
+	if filter.Key != "" {
+		tx = tx.Where("job_key = ?", filter.Key)
+	}
+	if filter.Group != "" {
+		tx = tx.Where("job_group = ?", filter.Group)
+	}
+	if filter.Status != "" {
+		tx = tx.Where("job_status = ?", filter.Status)
+	}
+
+	var scheduled []jobs.ScheduledJob
+	err := tx.Order("nxt_run_time").Find(&scheduled).Error
+	return scheduled, err
+}
+
+func (r *gormJobRepository) TriggerNow(ctx context.Context, key string) error {
+	tx := r.db.WithContext(ctx).Model(&jobs.ScheduledJob{}).
+		Where("job_key = ? AND job_status = ?", key, jobs.StatusScheduled).
+		Update("nxt_run_time", time.Now().UnixNano())
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormJobRepository) Cancel(ctx context.Context, key string) error {
+	tx := r.db.WithContext(ctx).
+		Where("job_key = ? AND job_status IN ?", key, []string{jobs.StatusScheduled, jobs.StatusPaused, jobs.StatusBlocked}).
+		Delete(&jobs.ScheduledJob{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormJobRepository) PauseGroup(ctx context.Context, group string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&jobs.PausedGroup{JobGroup: group}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&jobs.ScheduledJob{}).
+			Where("job_group = ? AND job_status = ?", group, jobs.StatusScheduled).
+			Update("job_status", jobs.StatusPaused).Error
+	})
+}
+
+func (r *gormJobRepository) ResumeGroup(ctx context.Context, group string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_group = ?", group).Delete(&jobs.PausedGroup{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&jobs.ScheduledJob{}).
+			Where("job_group = ? AND job_status = ?", group, jobs.StatusPaused).
+			Update("job_status", jobs.StatusScheduled).Error
+	})
+}
+
+func (r *gormJobRepository) Dead(ctx context.Context, group string) ([]jobs.DeadJob, error) {
+	tx := r.db.WithContext(ctx)
+	if group != "" {
+		tx = tx.Where("job_group = ?", group)
+	}
+
+	var dead []jobs.DeadJob
+	err := tx.Order("failed_at DESC").Find(&dead).Error
+	return dead, err
+}
+
+func (r *gormJobRepository) GetDead(ctx context.Context, id uint) (*jobs.DeadJob, error) {
+	dead := &jobs.DeadJob{}
+	if err := r.db.WithContext(ctx).First(dead, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return dead, nil
+}
+
+func (r *gormJobRepository) ReplayDead(ctx context.Context, dead *jobs.DeadJob) error {
+	// The scheduler queues through its own connection, so the row is removed first:
+	// of concurrent replays only the one removing it queues the job.
+	result := r.db.WithContext(ctx).Delete(&jobs.DeadJob{}, dead.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	if err := dead.Replay(); err != nil {
+		if restoreErr := r.db.WithContext(ctx).Create(dead).Error; restoreErr != nil {
+			return fmt.Errorf("%w, and restoring the dead job: %v", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
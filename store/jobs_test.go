@@ -0,0 +1,84 @@
+package store
+
+import (
+	"alpha2/jobs"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobsControl(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	later := time.Now().Add(time.Hour).UnixNano()
+	for _, job := range []*jobs.ScheduledJob{
+		{JobKey: "CrawlPMFFunds::a", JobGroup: "CrawlPMFFunds", NxtRunTime: later, JobStatus: jobs.StatusScheduled},
+		{JobKey: "CrawlPMFFunds::b", JobGroup: "CrawlPMFFunds", NxtRunTime: later, JobStatus: jobs.StatusRunning},
+		{JobKey: "MFSync::c", JobGroup: "MFSync", NxtRunTime: later, JobStatus: jobs.StatusScheduled},
+	} {
+		if err := st.DB(ctx).Create(job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if upcoming, _ := st.Jobs.Upcoming(ctx, time.Now()); len(upcoming) != 2 {
+		t.Errorf("Upcoming() = %v, want the 2 scheduled jobs", upcoming)
+	}
+
+	if err := st.Jobs.PauseGroup(ctx, "CrawlPMFFunds"); err != nil {
+		t.Fatal(err)
+	}
+	paused, err := st.Jobs.List(ctx, JobFilter{Status: jobs.StatusPaused})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paused) != 1 || paused[0].JobKey != "CrawlPMFFunds::a" {
+		t.Errorf("List(paused) = %v, want only the scheduled job of the group", paused)
+	}
+	if err := st.Jobs.TriggerNow(ctx, "CrawlPMFFunds::a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TriggerNow() of a paused job returned %v, want ErrNotFound", err)
+	}
+
+	if err := st.Jobs.ResumeGroup(ctx, "CrawlPMFFunds"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Jobs.TriggerNow(ctx, "CrawlPMFFunds::a"); err != nil {
+		t.Fatalf("TriggerNow() of a resumed job returned %v", err)
+	}
+	got, err := st.Jobs.List(ctx, JobFilter{Key: "CrawlPMFFunds::a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].JobStatus != jobs.StatusScheduled || got[0].NxtRunTime >= later {
+		t.Errorf("List(key) = %v, want a scheduled job due now", got)
+	}
+
+	if err := st.Jobs.Cancel(ctx, "CrawlPMFFunds::b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() of a running job returned %v, want ErrNotFound", err)
+	}
+	if err := st.Jobs.Cancel(ctx, "MFSync::c"); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := st.Jobs.List(ctx, JobFilter{Group: "MFSync"}); len(left) != 0 {
+		t.Errorf("List() after Cancel() = %v, want no jobs", left)
+	}
+}
+
+func TestJobsReplayDeadRestoresUnqueuedJob(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	dead := &jobs.DeadJob{JobKey: "Unknown::a", JobName: "a", JobGroup: "Unknown", Attempts: 3, FailedAt: time.Now()}
+	if err := st.DB(ctx).Create(dead).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Jobs.ReplayDead(ctx, dead); !errors.Is(err, jobs.ErrUnknownGroup) {
+		t.Errorf("ReplayDead() of an unknown group returned %v, want ErrUnknownGroup", err)
+	}
+	if _, err := st.Jobs.GetDead(ctx, dead.ID); err != nil {
+		t.Errorf("GetDead() after a failed replay returned %v, want the restored job", err)
+	}
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidLabel is returned when setting a label that fails validation or on a
+	// fund merged into another.
+	ErrInvalidLabel = errors.New("invalid label")
+	// ErrInvalidLabels is returned by Import when any row is invalid; nothing is
+	// changed and the rows are listed in the result.
+	ErrInvalidLabels = errors.New("invalid label rows")
+
+	// errDryRun rolls back the transaction of an import made as a dry run.
+	errDryRun = errors.New("dry run")
+)
+
+// LabelUpdate is a change of the label of a fund or of its canonical fund.
+type LabelUpdate struct {
+	Label string `json:"label"`
+	// CanonicalID merges the fund into the given fund, which takes Label when set.
+	CanonicalID *uint64 `json:"canonical_id"`
+}
+
+// LabelImport is the outcome of importing label rows.
+type LabelImport struct {
+	DryRun    bool `json:"dry_run"`
+	Labelled  int  `json:"labelled"`
+	Merged    int  `json:"merged"`
+	Unmerged  int  `json:"unmerged"`
+	Unchanged int  `json:"unchanged"`
+	// Skipped are the rows of funds that do not exist.
+	Skipped []LabelRowError `json:"skipped"`
+	Errors  []LabelRowError `json:"errors"`
+}
+
+// LabelRowError is a row of a label import that was skipped or failed.
+type LabelRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// UnlabelledFilter pages the queue of unlabelled funds. Type keeps one fund type,
+// PMS strategies and AIFs when empty.
+type UnlabelledFilter struct {
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// LabelRepository keeps the curated labels of funds and the canonical fund each
+// duplicate is merged into. Every change is recorded as a LabelChange.
+type LabelRepository interface {
+	// Set labels a fund or, with a CanonicalID, merges it into the canonical fund.
+	Set(ctx context.Context, fundID uint64, update LabelUpdate, by string) (*crawler.Fund, error)
+	// Import applies label rows all or nothing. Within a fund house, the funds sharing
+	// a label are merged into the row marked canonical, else the visible fund that
+	// already has the label, else the first row. A dry run reports what would change.
+	Import(ctx context.Context, rows []crawler.LabelRow, by string, dryRun bool) (*LabelImport, error)
+	// Export returns a row for every labelled fund, by label with canonical funds
+	// first, in the form Import reads.
+	Export(ctx context.Context) ([]crawler.LabelRow, error)
+	// Unlabelled returns the unmerged funds without a label, newest first, with
+	// their fund houses. New strategies found by the crawlers land here.
+	Unlabelled(ctx context.Context, filter UnlabelledFilter) ([]crawler.Fund, error)
+	// History returns the label changes of a fund, newest first.
+	History(ctx context.Context, fundID uint64) ([]crawler.LabelChange, error)
+}
+
+type gormLabelRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormLabelRepository) Set(ctx context.Context, fundID uint64, update LabelUpdate, by string) (*crawler.Fund, error) {
+	label := crawler.NormalizeLabel(update.Label)
+	fund := &crawler.Fund{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(fund, fundID).Error; err != nil {
+			return notFound(err)
+		}
+
+		if update.CanonicalID != nil {
+			canonical := &crawler.Fund{}
+			if err := tx.First(canonical, *update.CanonicalID).Error; err != nil {
+				return notFound(err)
+			}
+			if label != "" {
+				if err := crawler.ValidateLabel(label); err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidLabel, err)
+				}
+				if _, err := relabel(tx, canonical, label, crawler.LabelSourceAPI, by); err != nil {
+					return err
+				}
+			}
+			_, err := remap(ctx, tx, fund, canonical, crawler.LabelSourceAPI, by)
+			return err
+		}
+
+		if err := crawler.ValidateLabel(label); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidLabel, err)
+		}
+		if fund.OriginalID != nil {
+			return fmt.Errorf("%w: fund is merged into %d, label that fund", ErrInvalidLabel, *fund.OriginalID)
+		}
+		_, err := relabel(tx, fund, label, crawler.LabelSourceAPI, by)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fund, nil
+}
+
+// labelGroup is the rows of a label file sharing a label within a fund house.
+type labelGroup struct {
+	fundHouseID uint64
+	label       string
+	rows        []crawler.LabelRow
+	funds       []*crawler.Fund
+}
+
+func (r *gormLabelRepository) Import(ctx context.Context, rows []crawler.LabelRow, by string, dryRun bool) (*LabelImport, error) {
+	result := &LabelImport{DryRun: dryRun, Skipped: []LabelRowError{}, Errors: []LabelRowError{}}
+	fail := func(row crawler.LabelRow, err error) {
+		result.Errors = append(result.Errors, LabelRowError{Line: row.Line, Error: err.Error()})
+	}
+
+	if dryRun {
+		// A dry run is rolled back, so the restatements its merges save are not alerted on.
+		ctx = withoutAlerts(ctx)
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var groups []*labelGroup
+		byKey := make(map[[2]any]*labelGroup)
+		lines := make(map[uint64]int, len(rows))
+		for _, row := range rows {
+			row.Label = crawler.NormalizeLabel(row.Label)
+			if err := crawler.ValidateLabel(row.Label); err != nil {
+				fail(row, err)
+				continue
+			}
+			fund, err := labelledFund(tx, row)
+			if errors.Is(err, ErrNotFound) {
+				result.Skipped = append(result.Skipped, LabelRowError{Line: row.Line, Error: "fund not found"})
+				continue
+			} else if err != nil {
+				fail(row, err)
+				continue
+			}
+			if line, ok := lines[fund.ID]; ok {
+				fail(row, fmt.Errorf("fund %d is also on line %d", fund.ID, line))
+				continue
+			}
+			lines[fund.ID] = row.Line
+
+			var fundHouseID uint64
+			if len(fund.FundManagers) > 0 {
+				fundHouseID = fund.FundManagers[0].ID
+			}
+			key := [2]any{fundHouseID, row.Label}
+			group, ok := byKey[key]
+			if !ok {
+				group = &labelGroup{fundHouseID: fundHouseID, label: row.Label}
+				byKey[key] = group
+				groups = append(groups, group)
+			}
+			group.rows = append(group.rows, row)
+			group.funds = append(group.funds, fund)
+		}
+		if len(result.Errors) > 0 {
+			return ErrInvalidLabels
+		}
+
+		for _, group := range groups {
+			if err := importGroup(ctx, tx, group, by, result, fail); err != nil {
+				return err
+			}
+		}
+		if len(result.Errors) > 0 {
+			return ErrInvalidLabels
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		if errors.Is(err, ErrInvalidLabels) {
+			return result, err
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// importGroup labels the canonical fund of a group and the funds marked canonical,
+// then merges the other funds into the canonical fund. Funds that cannot be merged
+// are reported through fail.
+func importGroup(ctx context.Context, tx *gorm.DB, group *labelGroup, by string, result *LabelImport, fail func(crawler.LabelRow, error)) error {
+	canonical, err := groupCanonical(tx, group)
+	if err != nil {
+		return err
+	}
+	standalone := func(idx int) bool {
+		return group.funds[idx].ID == canonical.ID || group.rows[idx].Canonical
+	}
+
+	for idx, fund := range group.funds {
+		if !standalone(idx) {
+			continue
+		}
+		changed := false
+		if fund.OriginalID != nil {
+			if err := unmerge(ctx, tx, fund, crawler.LabelSourceImport, by); err != nil {
+				return err
+			}
+			result.Unmerged++
+			changed = true
+		}
+		labelled, err := relabel(tx, fund, group.label, crawler.LabelSourceImport, by)
+		if err != nil {
+			return err
+		}
+		if labelled {
+			result.Labelled++
+		} else if !changed {
+			result.Unchanged++
+		}
+	}
+
+	for idx, fund := range group.funds {
+		if standalone(idx) {
+			continue
+		}
+		merged, err := remap(ctx, tx, fund, canonical, crawler.LabelSourceImport, by)
+		switch {
+		case errors.Is(err, ErrInvalidMerge):
+			fail(group.rows[idx], fmt.Errorf("fund %d cannot be merged into %d", fund.ID, canonical.ID))
+		case err != nil:
+			return err
+		case merged:
+			result.Merged++
+		default:
+			result.Unchanged++
+		}
+	}
+	return nil
+}
+
+// groupCanonical returns the fund of a group the others are merged into.
+func groupCanonical(tx *gorm.DB, group *labelGroup) (*crawler.Fund, error) {
+	for idx, row := range group.rows {
+		if row.Canonical {
+			return group.funds[idx], nil
+		}
+	}
+
+	existing := []*crawler.Fund{}
+	query := tx.Where("label = ? AND original_id IS NULL AND is_hidden = false", group.label)
+	if group.fundHouseID != 0 {
+		query = query.Where("id in (SELECT fund_id FROM fund_x_fund_managers WHERE fund_manager_id = ?)", group.fundHouseID)
+	}
+	if err := query.Order("id").Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		for _, fund := range group.funds {
+			if fund.ID == existing[0].ID {
+				return fund, nil
+			}
+		}
+		return existing[0], nil
+	}
+	return group.funds[0], nil
+}
+
+// labelledFund returns the fund of a label row with its fund houses, found by ID or
+// else by name.
+func labelledFund(tx *gorm.DB, row crawler.LabelRow) (*crawler.Fund, error) {
+	funds := []*crawler.Fund{}
+	if row.FundID != 0 {
+		if err := tx.Preload("FundManagers").Where("id = ?", row.FundID).Find(&funds).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(funds) == 0 && row.Name != "" {
+		if err := tx.Preload("FundManagers").Where("name = ?", row.Name).Limit(2).Find(&funds).Error; err != nil {
+			return nil, err
+		}
+		if len(funds) > 1 {
+			return nil, fmt.Errorf("more than one fund is named %q", row.Name)
+		}
+	}
+	if len(funds) == 0 {
+		return nil, ErrNotFound
+	}
+	return funds[0], nil
+}
+
+// relabel sets the label of a fund and of the funds merged into it, recording each
+// change. It reports whether the label of the fund changed.
+func relabel(tx *gorm.DB, fund *crawler.Fund, label, source, by string) (bool, error) {
+	if fund.Label == label {
+		return false, nil
+	}
+	change := &crawler.LabelChange{
+		FundID:             fund.ID,
+		PreviousLabel:      fund.Label,
+		Label:              label,
+		PreviousOriginalID: fund.OriginalID,
+		OriginalID:         fund.OriginalID,
+		Source:             source,
+		ChangedBy:          by,
+	}
+	if err := tx.Model(fund).Update("label", label).Error; err != nil {
+		return false, err
+	}
+	fund.Label = label
+	if err := tx.Create(change).Error; err != nil {
+		return false, err
+	}
+
+	merged := []*crawler.Fund{}
+	if err := tx.Where("original_id = ?", fund.ID).Find(&merged).Error; err != nil {
+		return false, err
+	}
+	for _, duplicate := range merged {
+		if _, err := relabel(tx, duplicate, label, source, by); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// remap merges a fund into the canonical fund through the merge service, undoing
+// its merge into another fund first, and records the change. It reports whether the
+// fund was merged.
+func remap(ctx context.Context, tx *gorm.DB, fund, canonical *crawler.Fund, source, by string) (bool, error) {
+	if fund.OriginalID != nil && *fund.OriginalID == canonical.ID {
+		return false, nil
+	}
+	change := &crawler.LabelChange{
+		FundID:             fund.ID,
+		PreviousLabel:      fund.Label,
+		PreviousOriginalID: fund.OriginalID,
+		Source:             source,
+		ChangedBy:          by,
+	}
+	merges := New(tx).Merges
+	if fund.OriginalID != nil {
+		if _, err := merges.Unmerge(ctx, fund.ID, by); err != nil {
+			return false, err
+		}
+	}
+	if _, err := merges.Commit(ctx, fund.ID, canonical.ID, by); err != nil {
+		return false, err
+	}
+	if err := tx.First(fund, fund.ID).Error; err != nil {
+		return false, err
+	}
+	change.Label, change.OriginalID = fund.Label, fund.OriginalID
+	return true, tx.Create(change).Error
+}
+
+// unmerge undoes the merge of a fund and records the change.
+func unmerge(ctx context.Context, tx *gorm.DB, fund *crawler.Fund, source, by string) error {
+	change := &crawler.LabelChange{
+		FundID:             fund.ID,
+		PreviousLabel:      fund.Label,
+		PreviousOriginalID: fund.OriginalID,
+		Source:             source,
+		ChangedBy:          by,
+	}
+	if _, err := New(tx).Merges.Unmerge(ctx, fund.ID, by); err != nil {
+		return err
+	}
+	if err := tx.First(fund, fund.ID).Error; err != nil {
+		return err
+	}
+	change.Label, change.OriginalID = fund.Label, fund.OriginalID
+	return tx.Create(change).Error
+}
+
+func (r *gormLabelRepository) Export(ctx context.Context) ([]crawler.LabelRow, error) {
+	funds := []crawler.Fund{}
+	err := r.db.WithContext(ctx).
+		Where("label != ''").
+		Order("label, original_id IS NOT NULL, id").
+		Find(&funds).Error
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]crawler.LabelRow, len(funds))
+	for idx, fund := range funds {
+		rows[idx] = crawler.LabelRow{
+			FundID:    fund.ID,
+			Name:      fund.Name,
+			Label:     fund.Label,
+			Canonical: fund.OriginalID == nil,
+		}
+	}
+	return rows, nil
+}
+
+func (r *gormLabelRepository) Unlabelled(ctx context.Context, filter UnlabelledFilter) ([]crawler.Fund, error) {
+	tx := r.db.WithContext(ctx).Preload("FundManagers").
+		Where("label = '' AND original_id IS NULL")
+	if filter.Type != "" {
+		tx = tx.Where("type = ?", filter.Type)
+	} else {
+		tx = tx.Where("type in ('PMF', 'AIF')")
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	funds := []crawler.Fund{}
+	err := tx.Order("id DESC").Find(&funds).Error
+	return funds, err
+}
+
+func (r *gormLabelRepository) History(ctx context.Context, fundID uint64) ([]crawler.LabelChange, error) {
+	changes := []crawler.LabelChange{}
+	err := r.db.WithContext(ctx).Where("fund_id = ?", fundID).Order("id DESC").Find(&changes).Error
+	return changes, err
+}
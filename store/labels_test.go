@@ -0,0 +1,89 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLabelsImportAndSet(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	fundHouse := []*crawler.FundManager{{ID: 1, RegisterNumber: "INP1"}}
+	for _, fund := range []*crawler.Fund{
+		{ID: 1, Name: "Alpha", Type: "PMF", FundManagers: fundHouse},
+		{ID: 2, Name: "Alpha 2", Type: "PMF", FundManagers: fundHouse},
+		{ID: 3, Name: "Beta", Type: "PMF", FundManagers: fundHouse},
+		{ID: 4, Name: "Gamma", Type: "PMF", FundManagers: []*crawler.FundManager{{ID: 2, RegisterNumber: "INP2"}}},
+	} {
+		if err := st.Funds.Save(ctx, fund); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows := []crawler.LabelRow{
+		{Line: 1, FundID: 1, Label: "House : Alpha"},
+		{Line: 2, FundID: 2, Label: "House : Alpha", Canonical: true},
+		{Line: 3, Name: "Beta", Label: "House : Beta"},
+		{Line: 4, FundID: 9, Label: "House : Missing"},
+	}
+
+	result, err := st.Labels.Import(ctx, rows, "curator", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Labelled != 2 || result.Merged != 1 || len(result.Skipped) != 1 {
+		t.Errorf("dry run = %+v", result)
+	}
+	if fund, _ := st.Funds.Get(ctx, 1); fund.Label != "" || fund.OriginalID != nil {
+		t.Errorf("dry run changed fund 1: %+v", fund)
+	}
+
+	if _, err := st.Labels.Import(ctx, append(rows, crawler.LabelRow{Line: 5, FundID: 4, Label: " "}), "curator", false); !errors.Is(err, ErrInvalidLabels) {
+		t.Errorf("Import() with an empty label = %v, want ErrInvalidLabels", err)
+	}
+	if result, err = st.Labels.Import(ctx, rows, "curator", false); err != nil {
+		t.Fatal(err)
+	}
+	if fund, _ := st.Funds.Get(ctx, 1); fund.Label != "House : Alpha" || fund.OriginalID == nil || *fund.OriginalID != 2 || !fund.IsHidden {
+		t.Errorf("fund 1 after import = %+v, want merged into 2", fund)
+	}
+	if result, _ = st.Labels.Import(ctx, rows, "curator", false); result.Unchanged != 3 {
+		t.Errorf("second import = %+v, want every row unchanged", result)
+	}
+
+	exported, err := st.Labels.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 3 || exported[0].FundID != 2 || !exported[0].Canonical || exported[1].FundID != 1 || exported[1].Canonical {
+		t.Errorf("Export() = %+v", exported)
+	}
+
+	if _, err := st.Labels.Set(ctx, 1, LabelUpdate{Label: "Other"}, "curator"); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Set() on a merged fund = %v, want ErrInvalidLabel", err)
+	}
+	if _, err := st.Labels.Set(ctx, 2, LabelUpdate{Label: " House :  Alpha Prime"}, "curator"); err != nil {
+		t.Fatal(err)
+	}
+	if fund, _ := st.Funds.Get(ctx, 1); fund.Label != "House : Alpha Prime" {
+		t.Errorf("label of the merged fund = %q, want the canonical label", fund.Label)
+	}
+	changes, err := st.Labels.History(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Label != "House : Alpha Prime" || changes[1].OriginalID == nil || *changes[1].OriginalID != 2 {
+		t.Errorf("History() = %+v", changes)
+	}
+
+	unlabelled, err := st.Labels.Unlabelled(ctx, UnlabelledFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unlabelled) != 1 || unlabelled[0].ID != 4 || len(unlabelled[0].FundManagers) != 1 {
+		t.Errorf("Unlabelled() = %+v, want fund 4", unlabelled)
+	}
+}
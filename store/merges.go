@@ -0,0 +1,239 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidMerge is returned when merging a fund into itself or into a fund
+	// that is itself merged.
+	ErrInvalidMerge = errors.New("fund cannot be merged into the target")
+	// ErrAlreadyMerged is returned when merging a fund that is already merged.
+	ErrAlreadyMerged = errors.New("fund already merged")
+	// ErrNotMerged is returned when unmerging a fund that is not merged.
+	ErrNotMerged = errors.New("fund not merged")
+)
+
+// MergeRepository merges duplicate funds. The admin API and the CLI both merge
+// through it, so every merge is recorded and can be undone.
+type MergeRepository interface {
+	// Preview returns what merging the fund into the target would do, without
+	// changing anything.
+	Preview(ctx context.Context, fundID, targetID uint64) (*crawler.MergePreview, error)
+	// Commit merges the fund into the target and records the merge. Funds merged
+	// into the fund are merged into the target instead, and recorded as moved.
+	Commit(ctx context.Context, fundID, targetID uint64, by string) (*crawler.MergeEvent, error)
+	// Unmerge undoes the merge of a fund: the reports copied from it are removed and
+	// the fund is restored as it was before the merge. Funds the merge moved to the
+	// target are merged back into the fund.
+	Unmerge(ctx context.Context, fundID uint64, by string) (*crawler.MergeEvent, error)
+	// ForFund returns the merges of the fund into another or of others into it,
+	// newest first.
+	ForFund(ctx context.Context, fundID uint64) ([]crawler.MergeEvent, error)
+}
+
+type gormMergeRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormMergeRepository) Preview(ctx context.Context, fundID, targetID uint64) (*crawler.MergePreview, error) {
+	db := r.db.WithContext(ctx)
+	fund, target, err := mergeFunds(db, fundID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := mergedReports(db, fundID)
+	if err != nil {
+		return nil, err
+	}
+	targetReports, err := effectiveForFund(db, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return crawler.CompareReports(fund, target, reports, targetReports), nil
+}
+
+func (r *gormMergeRepository) Commit(ctx context.Context, fundID, targetID uint64, by string) (*crawler.MergeEvent, error) {
+	var event *crawler.MergeEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fund, target, err := mergeFunds(tx, fundID, targetID)
+		if err != nil {
+			return err
+		}
+		if fund.OriginalID != nil {
+			return ErrAlreadyMerged
+		}
+
+		event = &crawler.MergeEvent{
+			FundID:             fund.ID,
+			TargetID:           target.ID,
+			MergedBy:           by,
+			PreviousOriginalID: fund.OriginalID,
+			PreviousLabel:      fund.Label,
+			PreviousIsHidden:   fund.IsHidden,
+		}
+		// Funds merged into the fund are moved to the target, each through its own
+		// unmerge and merge events, so no merge ever points at a merged fund.
+		duplicates := []*crawler.Fund{}
+		if err := tx.Where("original_id = ?", fund.ID).Order("id").Find(&duplicates).Error; err != nil {
+			return err
+		}
+		merges := New(tx).Merges
+		for _, duplicate := range duplicates {
+			if _, err := merges.Unmerge(ctx, duplicate.ID, by); err != nil {
+				return err
+			}
+			if _, err := merges.Commit(ctx, duplicate.ID, target.ID, by); err != nil {
+				return err
+			}
+			event.MovedFundIDs = append(event.MovedFundIDs, duplicate.ID)
+		}
+
+		reports, err := mergedReports(tx, fund.ID)
+		if err != nil {
+			return err
+		}
+		for _, report := range reports {
+			report.ID = 0
+			report.FundID = target.ID
+			report.MarkMerged(fund.ID)
+			if err := upsertReport(tx, report); err != nil {
+				return err
+			}
+		}
+		event.ReportsCopied = len(reports)
+
+		fund.OriginalID = &target.ID
+		fund.Label = target.Label
+		fund.IsHidden = true
+		if err := tx.Save(fund).Error; err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *gormMergeRepository) Unmerge(ctx context.Context, fundID uint64, by string) (*crawler.MergeEvent, error) {
+	var event *crawler.MergeEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fund := &crawler.Fund{}
+		if err := tx.First(fund, fundID).Error; err != nil {
+			return notFound(err)
+		}
+		if fund.OriginalID == nil {
+			return ErrNotMerged
+		}
+
+		var events []*crawler.MergeEvent
+		err := tx.Where("fund_id = ? AND target_id = ? AND unmerged_at IS NULL", fundID, *fund.OriginalID).
+			Order("id DESC").Limit(1).Find(&events).Error
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			event = events[0]
+			fund.OriginalID = event.PreviousOriginalID
+			fund.Label = event.PreviousLabel
+			fund.IsHidden = event.PreviousIsHidden
+		} else {
+			// Merged before merges were recorded: there is no prior state to restore.
+			event = &crawler.MergeEvent{FundID: fundID, TargetID: *fund.OriginalID}
+			fund.OriginalID = nil
+			fund.IsHidden = false
+		}
+
+		if err := tx.Where("merged_id = ?", fundID).Delete(&crawler.FundReport{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(fund).Error; err != nil {
+			return err
+		}
+		if err := moveBack(ctx, tx, event, fund, by); err != nil {
+			return err
+		}
+		now := time.Now()
+		event.UnmergedBy = by
+		event.UnmergedAt = &now
+		return tx.Save(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *gormMergeRepository) ForFund(ctx context.Context, fundID uint64) ([]crawler.MergeEvent, error) {
+	events := []crawler.MergeEvent{}
+	err := r.db.WithContext(ctx).
+		Where("fund_id = ? OR target_id = ?", fundID, fundID).
+		Order("id DESC").
+		Find(&events).Error
+	return events, err
+}
+
+// moveBack merges the funds the merge of the event moved to its target back into
+// the unmerged fund. Funds since unmerged or merged elsewhere are left alone.
+func moveBack(ctx context.Context, tx *gorm.DB, event *crawler.MergeEvent, fund *crawler.Fund, by string) error {
+	if len(event.MovedFundIDs) == 0 || fund.OriginalID != nil {
+		return nil
+	}
+	moved := []*crawler.Fund{}
+	if err := tx.Where("id IN ? AND original_id = ?", event.MovedFundIDs, event.TargetID).Order("id").Find(&moved).Error; err != nil {
+		return err
+	}
+	merges := New(tx).Merges
+	for _, duplicate := range moved {
+		if _, err := merges.Unmerge(ctx, duplicate.ID, by); err != nil {
+			return err
+		}
+		if _, err := merges.Commit(ctx, duplicate.ID, fund.ID, by); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFunds returns the fund and the target of a merge, checking the target can
+// take the fund.
+func mergeFunds(tx *gorm.DB, fundID, targetID uint64) (*crawler.Fund, *crawler.Fund, error) {
+	if fundID == targetID {
+		return nil, nil, ErrInvalidMerge
+	}
+	fund, target := &crawler.Fund{}, &crawler.Fund{}
+	if err := tx.First(fund, fundID).Error; err != nil {
+		return nil, nil, notFound(err)
+	}
+	if err := tx.First(target, targetID).Error; err != nil {
+		return nil, nil, notFound(err)
+	}
+	if target.OriginalID != nil {
+		return nil, nil, ErrInvalidMerge
+	}
+	return fund, target, nil
+}
+
+// mergedReports returns the effective reports a merge of the fund copies: its own,
+// not those copied from funds merged into it.
+func mergedReports(tx *gorm.DB, fundID uint64) ([]*crawler.FundReport, error) {
+	reports := []*crawler.FundReport{}
+	err := tx.Scopes(EffectiveReports).
+		Where("fund_id = ? AND provenance <> ?", fundID, crawler.ProvenanceMerged).
+		Order("report_date").
+		Find(&reports).Error
+	return reports, err
+}
+
+func effectiveForFund(tx *gorm.DB, fundID uint64) ([]*crawler.FundReport, error) {
+	reports := []*crawler.FundReport{}
+	err := tx.Scopes(EffectiveReports).Where("fund_id = ?", fundID).Order("report_date").Find(&reports).Error
+	return reports, err
+}
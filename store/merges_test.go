@@ -0,0 +1,142 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMergesCommitAndUnmerge(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	for _, fund := range []*crawler.Fund{
+		{ID: 1, Name: "Alpha Growth", Type: "PMF", Label: "Alpha Growth"},
+		{ID: 2, Name: "Alpha  Growth", Type: "PMF", Label: "old label"},
+	} {
+		if err := st.Funds.Save(ctx, fund); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports := []*crawler.FundReport{
+		{FundID: 1, ReportDate: date(2024, time.June, 1), Month1Returns: float(1)},
+		{FundID: 2, ReportDate: date(2024, time.May, 1), Month1Returns: float(2)},
+		{FundID: 2, ReportDate: date(2024, time.June, 1), Month1Returns: float(3)},
+	}
+	for _, report := range reports {
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	preview, err := st.Merges.Preview(ctx, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Filled) != 1 || len(preview.Overlapping) != 1 {
+		t.Errorf("preview fills %v and overlaps %v", preview.Filled, preview.Overlapping)
+	}
+	if _, err := st.Merges.Commit(ctx, 1, 1, "curator"); !errors.Is(err, ErrInvalidMerge) {
+		t.Errorf("Commit() into itself = %v, want ErrInvalidMerge", err)
+	}
+
+	event, err := st.Merges.Commit(ctx, 2, 1, "curator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ReportsCopied != 2 || event.PreviousLabel != "old label" {
+		t.Errorf("merge event = %+v", event)
+	}
+	merged, _ := st.Funds.Get(ctx, 2)
+	if !merged.IsHidden || merged.OriginalID == nil || *merged.OriginalID != 1 || merged.Label != "Alpha Growth" {
+		t.Errorf("merged fund = %+v", merged)
+	}
+	target, _ := st.Reports.ForFund(ctx, 1)
+	if len(target) != 2 || *target[0].Month1Returns != 2 || *target[1].Month1Returns != 1 {
+		t.Errorf("target has %d reports after merge", len(target))
+	}
+	if _, err := st.Merges.Commit(ctx, 2, 1, "curator"); !errors.Is(err, ErrAlreadyMerged) {
+		t.Errorf("second Commit() = %v, want ErrAlreadyMerged", err)
+	}
+
+	if _, err := st.Merges.Unmerge(ctx, 2, "curator"); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := st.Funds.Get(ctx, 2)
+	if restored.IsHidden || restored.OriginalID != nil || restored.Label != "old label" {
+		t.Errorf("unmerged fund = %+v", restored)
+	}
+	if all, _ := st.Reports.AllForFund(ctx, 1); len(all) != 1 {
+		t.Errorf("target has %d reports after unmerge, want 1", len(all))
+	}
+	if _, err := st.Merges.Unmerge(ctx, 2, "curator"); !errors.Is(err, ErrNotMerged) {
+		t.Errorf("second Unmerge() = %v, want ErrNotMerged", err)
+	}
+	events, _ := st.Merges.ForFund(ctx, 1)
+	if len(events) != 1 || events[0].UnmergedAt == nil || events[0].UnmergedBy != "curator" {
+		t.Errorf("merge events = %+v", events)
+	}
+}
+
+func TestMergesCommitMovesMergedFunds(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	for _, fund := range []*crawler.Fund{
+		{ID: 1, Name: "Alpha Growth", Type: "PMF", Label: "Alpha Growth"},
+		{ID: 2, Name: "Alpha Growth Old", Type: "PMF"},
+		{ID: 3, Name: "Alpha Growth Older", Type: "PMF"},
+	} {
+		if err := st.Funds.Save(ctx, fund); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, report := range []*crawler.FundReport{
+		{FundID: 2, ReportDate: date(2024, time.May, 1), Month1Returns: float(2)},
+		{FundID: 3, ReportDate: date(2024, time.April, 1), Month1Returns: float(3)},
+	} {
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Merges.Commit(ctx, 3, 2, "curator"); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := st.Merges.Commit(ctx, 2, 1, "curator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ReportsCopied != 1 {
+		t.Errorf("merge copied %d reports, want 1", event.ReportsCopied)
+	}
+	moved, _ := st.Funds.Get(ctx, 3)
+	if moved.OriginalID == nil || *moved.OriginalID != 1 || moved.Label != "Alpha Growth" {
+		t.Errorf("fund merged into the merged fund = %+v", moved)
+	}
+	if all, _ := st.Reports.AllForFund(ctx, 2); len(all) != 1 {
+		t.Errorf("merged fund has %d reports, want its own 1", len(all))
+	}
+
+	if len(event.MovedFundIDs) != 1 || event.MovedFundIDs[0] != 3 {
+		t.Errorf("merge moved funds %v, want [3]", event.MovedFundIDs)
+	}
+
+	if _, err := st.Merges.Unmerge(ctx, 2, "curator"); err != nil {
+		t.Fatal(err)
+	}
+	if target, _ := st.Reports.AllForFund(ctx, 1); len(target) != 0 {
+		t.Errorf("target keeps %d reports after unmerge, want 0", len(target))
+	}
+	moved, _ = st.Funds.Get(ctx, 3)
+	if moved.OriginalID == nil || *moved.OriginalID != 2 {
+		t.Errorf("moved fund after unmerge = %+v, want merged into 2", moved)
+	}
+	restored, _ := st.Reports.AllForFund(ctx, 2)
+	if len(restored) != 2 {
+		t.Errorf("unmerged fund has %d reports, want its own and fund 3's", len(restored))
+	}
+}
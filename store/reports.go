@@ -0,0 +1,279 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExploreQuery selects fund reports for the explore page. SortBy is one of the keys
+// of exploreSortColumns; unknown keys sort by fund name. A zero Limit selects every
+// report.
+type ExploreQuery struct {
+	Type     string // Fund type, PMF when empty
+	From, To time.Time
+	// CollectionID limits the reports to the funds of a collection, or the funds
+	// they were merged into.
+	CollectionID uint64
+	FundHouseID  uint64
+	Search       string
+	SortBy       string
+	Desc         bool
+	Limit        int
+	Offset       int
+}
+
+type sortColumn struct {
+	column  string
+	notNull bool
+}
+
+var exploreSortColumns = map[string]sortColumn{
+	"aum":         {column: "fund_reports.aum"},
+	"threeMonth":  {column: "fund_reports.month3_returns", notNull: true},
+	"sixMonth":    {column: "fund_reports.month6_returns", notNull: true},
+	"oneYear":     {column: "fund_reports.yr1_returns", notNull: true},
+	"twoYear":     {column: "fund_reports.yr2_returns", notNull: true},
+	"threeYear":   {column: "fund_reports.yr3_returns", notNull: true},
+	"fiveYear":    {column: "fund_reports.yr5_returns", notNull: true},
+	"ytd":         {column: "fund_reports.over_all_returns", notNull: true},
+	"sharpeRatio": {column: "funds.sharpe_ratio3_yrs", notNull: true},
+	"maxDrawdown": {column: "funds.max_drawdown3_yrs", notNull: true},
+}
+
+// precedenceSQL returns the precedence of the report in table, per crawler.Precedence.
+func precedenceSQL(table string) string {
+	var cases strings.Builder
+	for _, provenance := range []string{crawler.ProvenanceCrawled, crawler.ProvenanceManual, crawler.ProvenanceMerged} {
+		fmt.Fprintf(&cases, " WHEN '%s' THEN %d", provenance, crawler.Precedence(provenance))
+	}
+	return fmt.Sprintf("CASE %s.provenance%s ELSE 0 END", table, cases.String())
+}
+
+// effectiveReportSQL is the condition on fund_reports selecting the effective report
+// of each fund and month: no other report of the month outranks it.
+var effectiveReportSQL = fmt.Sprintf(`NOT EXISTS (
+	SELECT 1 FROM fund_reports better
+	WHERE better.fund_id = fund_reports.fund_id AND better.report_date = fund_reports.report_date
+	AND (%[1]s > %[2]s OR (%[1]s = %[2]s AND better.id > fund_reports.id)))`,
+	precedenceSQL("better"), precedenceSQL("fund_reports"))
+
+// EffectiveReports is a scope limiting a query on fund_reports to the effective
+// report of each fund and month, as crawler.ResolveReports does in Go. Every read of
+// reports shown or computed on goes through it.
+func EffectiveReports(tx *gorm.DB) *gorm.DB {
+	return tx.Where(effectiveReportSQL)
+}
+
+type ReportRepository interface {
+	// MonthEnd returns the last report of each month between start and end.
+	MonthEnd(ctx context.Context, fundID uint64, start, end time.Time) ([]*crawler.FundReport, error)
+	// ForMonthEachYear returns the latest report of the given calendar month for every year.
+	ForMonthEachYear(ctx context.Context, fundID uint64, month time.Month) ([]crawler.FundReport, error)
+	// LatestBetween returns the latest report in [from, to), or ErrNotFound.
+	LatestBetween(ctx context.Context, fundID uint64, from, to time.Time) (*crawler.FundReport, error)
+	// LatestForFunds returns the most recent report across the given funds, or ErrNotFound.
+	LatestForFunds(ctx context.Context, fundIDs []uint64) (*crawler.FundReport, error)
+	Explore(ctx context.Context, q ExploreQuery) ([]crawler.FundReport, int64, error)
+	// AUMSeries returns the reports of a fund in date order with only the date and AUM loaded.
+	AUMSeries(ctx context.Context, fundID uint64) ([]crawler.FundReport, error)
+	ForFund(ctx context.Context, fundID uint64) ([]*crawler.FundReport, error)
+	// AllForFund returns every report of a fund, effective or not, by date then ID.
+	AllForFund(ctx context.Context, fundID uint64) ([]*crawler.FundReport, error)
+	// Since returns the reports of a fund dated after since.
+	Since(ctx context.Context, fundID uint64, since time.Time) ([]crawler.FundReport, error)
+	// RecentMonthly returns up to limit reports with a 1 month return, newest first,
+	// dated on or after since unless since is zero.
+	RecentMonthly(ctx context.Context, fundID uint64, limit int, since time.Time) ([]crawler.FundReport, error)
+	// Upsert saves the report, replacing the one of the same fund, date and origin.
+	Upsert(ctx context.Context, report *crawler.FundReport) error
+}
+
+type gormReportRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReportRepository) MonthEnd(ctx context.Context, fundID uint64, start, end time.Time) ([]*crawler.FundReport, error) {
+	d := DialectOf(r.db)
+	var reports []*crawler.FundReport
+	err := r.db.WithContext(ctx).
+		Raw(d.FirstPerGroup("fund_reports", "fund_id = ? AND report_date BETWEEN ? AND ? AND "+effectiveReportSQL,
+			[]string{"fund_id", d.MonthStart("report_date")}, "report_date DESC"), fundID, start, end).
+		Scan(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) ForMonthEachYear(ctx context.Context, fundID uint64, month time.Month) ([]crawler.FundReport, error) {
+	d := DialectOf(r.db)
+	var reports []crawler.FundReport
+	err := r.db.WithContext(ctx).
+		Raw(d.FirstPerGroup("fund_reports", d.Month("report_date")+" = ? AND fund_id = ? AND "+effectiveReportSQL,
+			[]string{d.Year("report_date")}, "report_date DESC"), int(month), fundID).
+		Scan(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) LatestBetween(ctx context.Context, fundID uint64, from, to time.Time) (*crawler.FundReport, error) {
+	report := &crawler.FundReport{}
+	err := r.db.WithContext(ctx).Scopes(EffectiveReports).
+		Where("fund_id = ?", fundID).
+		Where("report_date >= ? AND report_date < ?", from, to).
+		Order("report_date DESC").
+		First(report).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return report, nil
+}
+
+func (r *gormReportRepository) LatestForFunds(ctx context.Context, fundIDs []uint64) (*crawler.FundReport, error) {
+	report := &crawler.FundReport{}
+	err := r.db.WithContext(ctx).Scopes(EffectiveReports).
+		Where("fund_id in ?", fundIDs).
+		Order("report_date DESC").
+		First(report).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return report, nil
+}
+
+func (r *gormReportRepository) Explore(ctx context.Context, q ExploreQuery) ([]crawler.FundReport, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&crawler.FundReport{}).Scopes(EffectiveReports).
+		Joins("JOIN funds ON funds.id = fund_reports.fund_id").
+		Where("report_date BETWEEN ? AND ?", q.From, q.To).
+		Where("funds.name != '' and  funds.type = ? and funds.is_hidden = false", cmp.Or(q.Type, "PMF"))
+	if q.CollectionID != 0 {
+		tx = tx.Where(`funds.id in (SELECT COALESCE(listed.original_id, listed.id) FROM collection_funds
+			JOIN funds listed ON listed.id = collection_funds.fund_id WHERE collection_funds.collection_id = ?)`, q.CollectionID)
+	}
+	if q.FundHouseID != 0 {
+		tx = tx.Where("funds.id in (SELECT fund_id FROM fund_x_fund_managers WHERE fund_manager_id = ?)", q.FundHouseID)
+	}
+	if q.Search != "" {
+		tx = tx.Where("similarity(funds.name, ?) > 0.1", q.Search)
+	}
+
+	sort, ok := exploreSortColumns[q.SortBy]
+	if !ok {
+		sort = sortColumn{column: "funds.name"}
+	}
+	if sort.notNull {
+		tx = tx.Where(sort.column + " IS NOT NULL")
+	}
+	tx = tx.Order(clause.OrderBy{
+		Columns: []clause.OrderByColumn{{
+			Column: clause.Column{Name: sort.column},
+			Desc:   q.Desc,
+		}},
+	})
+
+	page := tx.Session(&gorm.Session{})
+	if q.Limit > 0 {
+		page = page.Limit(q.Limit).Offset(q.Offset)
+	}
+	reports := []crawler.FundReport{}
+	if err := page.Find(&reports).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+func (r *gormReportRepository) AUMSeries(ctx context.Context, fundID uint64) ([]crawler.FundReport, error) {
+	var reports []crawler.FundReport
+	err := r.db.WithContext(ctx).Model(&crawler.FundReport{}).Scopes(EffectiveReports).
+		Select("id", "report_date", "aum").
+		Where("fund_id = ?", fundID).
+		Order("report_date").
+		Find(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) ForFund(ctx context.Context, fundID uint64) ([]*crawler.FundReport, error) {
+	reports := []*crawler.FundReport{}
+	err := r.db.WithContext(ctx).Scopes(EffectiveReports).Where("fund_id = ?", fundID).Order("report_date").Find(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) AllForFund(ctx context.Context, fundID uint64) ([]*crawler.FundReport, error) {
+	reports := []*crawler.FundReport{}
+	err := r.db.WithContext(ctx).Where("fund_id = ?", fundID).Order("report_date, id").Find(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) Since(ctx context.Context, fundID uint64, since time.Time) ([]crawler.FundReport, error) {
+	reports := []crawler.FundReport{}
+	err := r.db.WithContext(ctx).Scopes(EffectiveReports).
+		Where("fund_id = ?", fundID).
+		Where("report_date > ?", since).
+		Find(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) RecentMonthly(ctx context.Context, fundID uint64, limit int, since time.Time) ([]crawler.FundReport, error) {
+	tx := r.db.WithContext(ctx).Scopes(EffectiveReports).
+		Where("fund_id = ? AND month1_returns IS NOT NULL", fundID)
+	if !since.IsZero() {
+		tx = tx.Where("report_date >= ?", since)
+	}
+
+	var reports []crawler.FundReport
+	err := tx.Order("report_date desc").Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+func (r *gormReportRepository) Upsert(ctx context.Context, report *crawler.FundReport) error {
+	return upsertReport(r.db.WithContext(ctx), report)
+}
+
+type quietKey struct{}
+
+// withoutAlerts returns a context under which upsertReport does not alert on
+// restatements, for changes that are rolled back such as a dry run.
+func withoutAlerts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietKey{}, true)
+}
+
+// upsertReport saves the report, replacing the one of the same fund, date and origin.
+// A change to the figures of a saved report is kept as a crawler.ReportRevision, and
+// restatements are alerted on unless the context of tx is from withoutAlerts.
+func upsertReport(tx *gorm.DB, report *crawler.FundReport) error {
+	var previous []*crawler.FundReport
+	err := tx.Where("fund_id = ? AND report_date = ? AND origin = ?",
+		report.FundID, report.ReportDate, cmp.Or(report.Origin, crawler.ProvenanceCrawled)).
+		Limit(1).Find(&previous).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&crawler.FundReport{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fund_id"}, {Name: "report_date"}, {Name: "origin"}},
+		UpdateAll: true,
+	}).Create(report).Error
+	if err != nil || len(previous) == 0 {
+		return err
+	}
+
+	revision := crawler.NewReportRevision(previous[0], report, crawler.RestatementThreshold())
+	if revision == nil {
+		return nil
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return err
+	}
+	if revision.Restatement && tx.Statement.Context.Value(quietKey{}) == nil {
+		revision.Alert()
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReportsMonthEnd(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	reports := []*crawler.FundReport{
+		{FundID: 1, ReportDate: date(2024, time.January, 10)},
+		{FundID: 1, ReportDate: date(2024, time.January, 31)},
+		{FundID: 1, ReportDate: date(2024, time.February, 29)},
+		{FundID: 2, ReportDate: date(2024, time.January, 31)},
+	}
+	for _, report := range reports {
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := st.Reports.MonthEnd(ctx, 1, *date(2024, time.January, 1), *date(2024, time.December, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*time.Time{date(2024, time.January, 31), date(2024, time.February, 29)}
+	if len(got) != len(want) {
+		t.Fatalf("MonthEnd() returned %d reports, want %d", len(got), len(want))
+	}
+	for i, report := range got {
+		if !report.ReportDate.Equal(*want[i]) {
+			t.Errorf("MonthEnd()[%d] is dated %v, want %v", i, report.ReportDate, want[i])
+		}
+	}
+}
+
+func TestReportsForMonthEachYear(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	reports := []*crawler.FundReport{
+		{FundID: 1, ReportDate: date(2023, time.March, 1)},
+		{FundID: 1, ReportDate: date(2023, time.March, 31)},
+		{FundID: 1, ReportDate: date(2023, time.April, 30)},
+		{FundID: 1, ReportDate: date(2024, time.March, 31)},
+	}
+	for _, report := range reports {
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := st.Reports.ForMonthEachYear(ctx, 1, time.March)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*time.Time{date(2023, time.March, 31), date(2024, time.March, 31)}
+	if len(got) != len(want) {
+		t.Fatalf("ForMonthEachYear() returned %d reports, want %d", len(got), len(want))
+	}
+	for i, report := range got {
+		if !report.ReportDate.Equal(*want[i]) {
+			t.Errorf("ForMonthEachYear()[%d] is dated %v, want %v", i, report.ReportDate, want[i])
+		}
+	}
+}
+
+func TestReportsReadEffectiveReports(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	fund := &crawler.Fund{
+		ID: 1, Name: "Alpha Growth", Type: "PMF",
+		FundManagers: []*crawler.FundManager{{ID: 1, RegisterNumber: "INP1"}},
+		FundReports:  []*crawler.FundReport{{ReportDate: date(2024, time.June, 1), Month1Returns: float(1.0)}},
+	}
+	if err := st.Funds.SaveCrawled(ctx, fund, *date(2024, time.June, 1)); err != nil {
+		t.Fatal(err)
+	}
+	for _, month := range []time.Month{time.May, time.June} {
+		report := &crawler.FundReport{FundID: 1, ReportDate: date(2024, month, 1), Month1Returns: float(2.0)}
+		report.MarkMerged(2)
+		if err := st.Reports.Upsert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Resyncing the merged fund again replaces its copies instead of adding more.
+	again := &crawler.FundReport{FundID: 1, ReportDate: date(2024, time.May, 1), Month1Returns: float(3.0)}
+	again.MarkMerged(2)
+	if err := st.Reports.Upsert(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := st.Reports.AllForFund(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("AllForFund() returned %d reports, want 3", len(all))
+	}
+
+	want := "[merged 3 crawled 1]"
+	reports, err := st.Reports.ForFund(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	for _, report := range reports {
+		got = append(got, report.Provenance, *report.Month1Returns)
+	}
+	if fmt.Sprint(got) != want {
+		t.Errorf("ForFund() = %v, want %v", got, want)
+	}
+
+	monthEnd, err := st.Reports.MonthEnd(ctx, 1, *date(2024, time.January, 1), *date(2024, time.December, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, report := range monthEnd {
+		got = append(got, report.Provenance, *report.Month1Returns)
+	}
+	if fmt.Sprint(got) != want {
+		t.Errorf("MonthEnd() = %v, want %v", got, want)
+	}
+}
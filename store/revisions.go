@@ -0,0 +1,36 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RevisionRepository interface {
+	// ForFund returns the revisions of the reports of a fund, newest first.
+	ForFund(ctx context.Context, fundID uint64) ([]crawler.ReportRevision, error)
+	// Restatements returns the revisions marked as restatements since the given time,
+	// newest first.
+	Restatements(ctx context.Context, since time.Time) ([]crawler.ReportRevision, error)
+}
+
+type gormRevisionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormRevisionRepository) ForFund(ctx context.Context, fundID uint64) ([]crawler.ReportRevision, error) {
+	revisions := []crawler.ReportRevision{}
+	err := r.db.WithContext(ctx).Where("fund_id = ?", fundID).Order("id DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *gormRevisionRepository) Restatements(ctx context.Context, since time.Time) ([]crawler.ReportRevision, error) {
+	revisions := []crawler.ReportRevision{}
+	err := r.db.WithContext(ctx).
+		Where("restatement = ? AND created_at >= ?", true, since).
+		Order("id DESC").
+		Find(&revisions).Error
+	return revisions, err
+}
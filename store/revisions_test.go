@@ -0,0 +1,51 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReportsKeepRevisions(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	crawl := func(month1 float64) {
+		t.Helper()
+		fund := &crawler.Fund{
+			ID: 1, Name: "Alpha Growth", Type: "PMF",
+			FundManagers: []*crawler.FundManager{{ID: 1, RegisterNumber: "INP1"}},
+			FundReports:  []*crawler.FundReport{{ReportDate: date(2024, time.May, 1), Month1Returns: float(month1)}},
+		}
+		if err := st.Funds.SaveCrawled(ctx, fund, *date(2024, time.May, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crawl(1.0)
+	crawl(1.0)
+	crawl(1.5)
+	crawl(4.0)
+
+	revisions, err := st.Revisions.ForFund(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("ForFund() returned %d revisions, want 2", len(revisions))
+	}
+	latest := revisions[0]
+	if *latest.Previous.Month1Returns != 1.5 || *latest.Current.Month1Returns != 4.0 || !latest.Restatement ||
+		revisions[1].Restatement {
+		t.Errorf("revisions = %+v", revisions)
+	}
+
+	restatements, err := st.Revisions.Restatements(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restatements) != 1 || restatements[0].ID != latest.ID {
+		t.Errorf("Restatements() = %+v, want only the latest revision", restatements)
+	}
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by repository lookups that match no row.
+var ErrNotFound = errors.New("record not found")
+
+// Store groups the repositories used by the API handlers and jobs.
+type Store struct {
+	Funds       FundRepository
+	FundHouses  FundHouseRepository
+	Reports     ReportRepository
+	Images      ImageRepository
+	Events      EventRepository
+	Jobs        JobRepository
+	Uploads     UploadRepository
+	Revisions   RevisionRepository
+	Merges      MergeRepository
+	Duplicates  DuplicateRepository
+	Collections CollectionRepository
+	Labels      LabelRepository
+
+	db *gorm.DB
+}
+
+// New returns a Store backed by the given gorm connection.
+func New(db *gorm.DB) *Store {
+	return &Store{
+		Funds:       &gormFundRepository{db: db},
+		FundHouses:  &gormFundHouseRepository{db: db},
+		Reports:     &gormReportRepository{db: db},
+		Images:      &gormImageRepository{db: db},
+		Events:      &gormEventRepository{db: db},
+		Jobs:        &gormJobRepository{db: db},
+		Uploads:     &gormUploadRepository{db: db},
+		Revisions:   &gormRevisionRepository{db: db},
+		Merges:      &gormMergeRepository{db: db},
+		Duplicates:  &gormDuplicateRepository{db: db},
+		Collections: &gormCollectionRepository{db: db},
+		Labels:      &gormLabelRepository{db: db},
+		db:          db,
+	}
+}
+
+// DB returns the underlying connection bound to ctx, for packages that own tables
+// outside the repositories (the job queue and the MF crawler).
+func (s *Store) DB(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+// Dialect returns the SQL dialect of the underlying connection.
+func (s *Store) Dialect() Dialect {
+	return DialectOf(s.db)
+}
+
+// Transaction runs fn with a Store whose repositories share a single transaction.
+func (s *Store) Transaction(ctx context.Context, fn func(tx *Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := st.Transaction(ctx, func(tx *Store) error {
+		if err := tx.Funds.Save(ctx, &crawler.Fund{ID: 1, Name: "Alpha Growth", Type: "PMF"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transaction() returned %v, want %v", err, errAbort)
+	}
+
+	if _, err := st.Funds.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("fund saved in a rolled back transaction is visible: %v", err)
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrReviewed is returned when approving or rejecting an upload that is no longer
+// pending.
+var ErrReviewed = errors.New("upload already reviewed")
+
+type UploadRepository interface {
+	// Create saves a pending upload with its reports.
+	Create(ctx context.Context, upload *crawler.ReportUpload) error
+	// Get returns an upload with its reports in month order.
+	Get(ctx context.Context, id uint64) (*crawler.ReportUpload, error)
+	// List returns the uploads with the given status, or all, newest first.
+	List(ctx context.Context, status string) ([]crawler.ReportUpload, error)
+	// Approve saves the reports of a pending upload as manual FundReports of its fund,
+	// replacing the manual reports of earlier uploads. Months where another report
+	// takes precedence, such as a crawled one, are marked Skipped.
+	Approve(ctx context.Context, id uint64, reviewer string) (*crawler.ReportUpload, error)
+	// Reject marks a pending upload rejected with the reason in note.
+	Reject(ctx context.Context, id uint64, reviewer, note string) (*crawler.ReportUpload, error)
+}
+
+type gormUploadRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUploadRepository) Create(ctx context.Context, upload *crawler.ReportUpload) error {
+	upload.Status = crawler.UploadPending
+	return r.db.WithContext(ctx).Create(upload).Error
+}
+
+func (r *gormUploadRepository) Get(ctx context.Context, id uint64) (*crawler.ReportUpload, error) {
+	upload := &crawler.ReportUpload{}
+	err := r.db.WithContext(ctx).
+		Preload("Reports", func(tx *gorm.DB) *gorm.DB { return tx.Order("report_date") }).
+		First(upload, id).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return upload, nil
+}
+
+func (r *gormUploadRepository) List(ctx context.Context, status string) ([]crawler.ReportUpload, error) {
+	tx := r.db.WithContext(ctx).Model(&crawler.ReportUpload{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	uploads := []crawler.ReportUpload{}
+	err := tx.Order("created_at DESC").Find(&uploads).Error
+	return uploads, err
+}
+
+func (r *gormUploadRepository) Approve(ctx context.Context, id uint64, reviewer string) (*crawler.ReportUpload, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		upload, err := review(tx, id, crawler.UploadApproved, reviewer, "")
+		if err != nil {
+			return err
+		}
+		for _, uploaded := range upload.Reports {
+			report := uploaded.FundReport(upload.FundID)
+			report.UploadID = &upload.ID
+			if err := upsertReport(tx, report); err != nil {
+				return err
+			}
+			effective := &crawler.FundReport{}
+			err := tx.Scopes(EffectiveReports).
+				Where("fund_id = ? AND report_date = ?", upload.FundID, report.ReportDate).
+				First(effective).Error
+			if err != nil {
+				return err
+			}
+			if effective.Origin != crawler.ProvenanceManual {
+				if err := tx.Model(uploaded).Update("skipped", true).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *gormUploadRepository) Reject(ctx context.Context, id uint64, reviewer, note string) (*crawler.ReportUpload, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := review(tx, id, crawler.UploadRejected, reviewer, note)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// review moves a pending upload to status and returns it with its reports. The
+// status is only changed from pending, so an upload is reviewed once.
+func review(tx *gorm.DB, id uint64, status, reviewer, note string) (*crawler.ReportUpload, error) {
+	upload := &crawler.ReportUpload{}
+	if err := tx.Preload("Reports").First(upload, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	reviewed := tx.Model(&crawler.ReportUpload{}).
+		Where("id = ? AND status = ?", id, crawler.UploadPending).
+		Updates(map[string]any{
+			"status":      status,
+			"reviewed_by": reviewer,
+			"reviewed_at": time.Now(),
+			"note":        note,
+		})
+	if reviewed.Error != nil {
+		return nil, reviewed.Error
+	}
+	if reviewed.RowsAffected == 0 {
+		return nil, ErrReviewed
+	}
+	return upload, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"alpha2/crawler"
+	"alpha2/migrations/migratetest"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUploadsApproveKeepsCrawledReports(t *testing.T) {
+	st := New(migratetest.Open(t))
+	ctx := context.Background()
+
+	crawled := &crawler.Fund{
+		ID: 1, Name: "Alpha Growth", Type: "PMF",
+		FundManagers: []*crawler.FundManager{{ID: 1, RegisterNumber: "INP1"}},
+		FundReports:  []*crawler.FundReport{{ReportDate: date(2024, time.May, 1), Month1Returns: float(1.0)}},
+	}
+	if err := st.Funds.SaveCrawled(ctx, crawled, *date(2024, time.May, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(returns float64) *crawler.ReportUpload {
+		t.Helper()
+		upload := &crawler.ReportUpload{FundID: 1, Reports: []*crawler.UploadedReport{
+			{ReportDate: *date(2024, time.May, 1), Month1Returns: float(returns)},
+			{ReportDate: *date(2024, time.June, 1), Month1Returns: float(returns)},
+		}}
+		if err := st.Uploads.Create(ctx, upload); err != nil {
+			t.Fatal(err)
+		}
+		return upload
+	}
+	month1Returns := func() map[time.Month]string {
+		t.Helper()
+		reports, err := st.Reports.ForFund(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[time.Month]string)
+		for _, report := range reports {
+			got[report.ReportDate.Month()] = fmt.Sprint(report.Provenance, " ", *report.Month1Returns)
+		}
+		return got
+	}
+
+	first := upload(2)
+	if got := month1Returns(); len(got) != 1 {
+		t.Fatalf("reports before approval = %v, want only the crawled one", got)
+	}
+	approved, err := st.Uploads.Approve(ctx, first.ID, "curator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Status != crawler.UploadApproved || approved.ReviewedBy != "curator" || !approved.Reports[0].Skipped || approved.Reports[1].Skipped {
+		t.Errorf("approved upload = %s by %q, skipped %v %v", approved.Status, approved.ReviewedBy,
+			approved.Reports[0].Skipped, approved.Reports[1].Skipped)
+	}
+	if _, err := st.Uploads.Approve(ctx, first.ID, "curator"); !errors.Is(err, ErrReviewed) {
+		t.Errorf("second Approve() error = %v, want ErrReviewed", err)
+	}
+
+	// A later upload corrects the manual month but still not the crawled one.
+	if _, err := st.Uploads.Approve(ctx, upload(3).ID, "curator"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[time.Month]string{time.May: "crawled 1", time.June: "manual 3"}
+	if got := month1Returns(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reports after approvals = %v, want %v", got, want)
+	}
+
+	// A crawl of the manual month replaces it.
+	crawled.FundReports = []*crawler.FundReport{{ReportDate: date(2024, time.June, 1), Month1Returns: float(4.0)}}
+	if err := st.Funds.SaveCrawled(ctx, crawled, *date(2024, time.June, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := month1Returns()[time.June]; got != "crawled 4" {
+		t.Errorf("June after crawl = %q, want crawled 4", got)
+	}
+
+	rejected, err := st.Uploads.Reject(ctx, upload(5).ID, "curator", "wrong fund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Status != crawler.UploadRejected || rejected.Note != "wrong fund" {
+		t.Errorf("rejected upload = %s %q", rejected.Status, rejected.Note)
+	}
+	if pending, _ := st.Uploads.List(ctx, crawler.UploadPending); len(pending) != 0 {
+		t.Errorf("%d uploads still pending", len(pending))
+	}
+}